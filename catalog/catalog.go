@@ -0,0 +1,174 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package catalog generates a catalog of the checks of a repo from their
+// manifests, optionally enriched with the info of their latest images in the
+// registry.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adevinta/vulcan-checks-bsys/lint"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+)
+
+// Formats supported by Write.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+// Image contains the info of the latest image of a check in the registry.
+type Image struct {
+	Tag          string    `json:"tag"`
+	Commit       string    `json:"commit,omitempty"`
+	SDKVersion   string    `json:"sdk_version,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Check is an entry of the catalog.
+type Check struct {
+	Name                 string   `json:"name"`
+	Path                 string   `json:"path"`
+	Description          string   `json:"description,omitempty"`
+	AssetTypes           []string `json:"asset_types,omitempty"`
+	Options              string   `json:"options,omitempty"`
+	RequiredVars         []string `json:"required_vars,omitempty"`
+	QueueName            string   `json:"queue_name,omitempty"`
+	Timeout              int      `json:"timeout,omitempty"`
+	Owner                string   `json:"owner,omitempty"`
+	Contact              string   `json:"contact,omitempty"`
+	Tags                 []string `json:"tags,omitempty"`
+	DocsURL              string   `json:"docs_url,omitempty"`
+	Version              string   `json:"version,omitempty"`
+	Deprecated           bool     `json:"deprecated,omitempty"`
+	VulnerabilityClasses []string `json:"vulnerability_classes,omitempty"`
+	MinSeverity          string   `json:"min_severity,omitempty"`
+	MaxSeverity          string   `json:"max_severity,omitempty"`
+	// Image is nil if the catalog was generated offline or the check has
+	// no images in the registry.
+	Image *Image `json:"image,omitempty"`
+	// Error contains the error found reading the manifest of the check, if
+	// any.
+	Error string `json:"error,omitempty"`
+}
+
+// Catalog contains the checks of a repo sorted by name.
+type Catalog struct {
+	Checks []Check `json:"checks"`
+}
+
+// ImageLookup returns the info of the latest image of a check in the
+// registry, or nil if the check has no images.
+type ImageLookup func(check string) (*Image, error)
+
+// Build generates the catalog of the checks under root. If lookup is nil the
+// catalog doesn't include the info of the images of the checks.
+func Build(root string, lookup ImageLookup) (Catalog, error) {
+	dirs, err := lint.CheckDirs(root)
+	if err != nil {
+		return Catalog{}, err
+	}
+	c := Catalog{Checks: []Check{}}
+	for _, dir := range dirs {
+		check := newCheck(dir, root)
+		if lookup != nil {
+			img, err := lookup(check.Name)
+			if err != nil {
+				return Catalog{}, fmt.Errorf("error fetching the image info of the check %s: %w", check.Name, err)
+			}
+			check.Image = img
+		}
+		c.Checks = append(c.Checks, check)
+	}
+	sort.SliceStable(c.Checks, func(i, j int) bool {
+		return c.Checks[i].Name < c.Checks[j].Name
+	})
+	return c, nil
+}
+
+func newCheck(dir, root string) Check {
+	check := Check{Name: filepath.Base(dir), Path: dir}
+	if rel, err := filepath.Rel(root, dir); err == nil {
+		check.Path = filepath.ToSlash(rel)
+	}
+	m, err := manifest.Read(filepath.Join(dir, lint.ManifestFile))
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	assetTypes, err := m.AssetTypes.Strings()
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Description = m.Description
+	check.AssetTypes = assetTypes
+	check.Options = m.Options
+	check.RequiredVars = m.AllRequiredVars()
+	check.QueueName = m.QueueName
+	check.Timeout = m.Timeout
+	check.Owner = m.Owner
+	check.Contact = m.Contact
+	check.Tags = m.Tags
+	check.DocsURL = m.DocsURL
+	check.Version = m.Version
+	check.Deprecated = m.Deprecated
+	check.VulnerabilityClasses = m.VulnerabilityClasses
+	check.MinSeverity = m.MinSeverity
+	check.MaxSeverity = m.MaxSeverity
+	return check
+}
+
+// Write writes the catalog in the given format.
+func (c Catalog) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return c.WriteMarkdown(w)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, c)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	return fmt.Errorf("invalid catalog format %q, valid formats are: %s", format,
+		strings.Join([]string{FormatMarkdown, FormatHTML, FormatJSON}, ", "))
+}
+
+// SeverityRange returns the range of severities of the vulnerabilities the
+// check can report, e.g.: LOW-HIGH, or an empty string if it's not defined.
+func (c Check) SeverityRange() string {
+	if c.MinSeverity == "" && c.MaxSeverity == "" {
+		return ""
+	}
+	min, max := c.MinSeverity, c.MaxSeverity
+	if min == "" {
+		min = manifest.Severities[0]
+	}
+	if max == "" {
+		max = manifest.Severities[len(manifest.Severities)-1]
+	}
+	if min == max {
+		return min
+	}
+	return min + "-" + max
+}
+
+// LatestTag returns the tag of the latest image of the check, or an empty
+// string if it's unknown.
+func (c Check) LatestTag() string {
+	if c.Image == nil {
+		return ""
+	}
+	return c.Image.Tag
+}
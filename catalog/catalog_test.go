@@ -0,0 +1,121 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func testLookup(check string) (*Image, error) {
+	if check != "vulcan-tls" {
+		return nil, nil
+	}
+	return &Image{
+		Tag:          "12",
+		Commit:       "01234a",
+		SDKVersion:   "8e938a5",
+		LastModified: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+func TestBuild(t *testing.T) {
+	c, err := Build("testdata/checks", testLookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, check := range c.Checks {
+		names = append(names, check.Name)
+	}
+	want := []string{"vulcan-broken", "vulcan-exposed-http", "vulcan-tls"}
+	if len(names) != len(want) {
+		t.Fatalf("Build() got checks %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Build() got checks %v, want %v", names, want)
+		}
+	}
+	if c.Checks[0].Error == "" {
+		t.Errorf("Build() expected error in the check %s", c.Checks[0].Name)
+	}
+	if c.Checks[1].Image != nil {
+		t.Errorf("Build() got image %+v for check %s, want nil", c.Checks[1].Image, c.Checks[1].Name)
+	}
+	if got := c.Checks[2].LatestTag(); got != "12" {
+		t.Errorf("LatestTag() got %s, want 12", got)
+	}
+}
+
+func TestBuildLookupError(t *testing.T) {
+	lookup := func(string) (*Image, error) { return nil, errors.New("registry unavailable") }
+	if _, err := Build("testdata/checks", lookup); err == nil {
+		t.Error("Build() expected error, got nil")
+	}
+}
+
+func TestCatalogWrite(t *testing.T) {
+	c, err := Build("testdata/checks", testLookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format string
+		golden string
+	}{
+		{format: FormatMarkdown, golden: "catalog.md"},
+		{format: FormatHTML, golden: "catalog.html"},
+		{format: FormatJSON, golden: "catalog.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := c.Write(&buf, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatalf("Error writing golden file %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("Write() got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckSeverityRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+		want  string
+	}{
+		{name: "Undefined", check: Check{}, want: ""},
+		{name: "Range", check: Check{MinSeverity: "LOW", MaxSeverity: "HIGH"}, want: "LOW-HIGH"},
+		{name: "OnlyMin", check: Check{MinSeverity: "MEDIUM"}, want: "MEDIUM-CRITICAL"},
+		{name: "Single", check: Check{MinSeverity: "INFO", MaxSeverity: "INFO"}, want: "INFO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.check.SeverityRange(); got != tt.want {
+				t.Errorf("SeverityRange() got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
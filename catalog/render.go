@@ -0,0 +1,172 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package catalog
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the catalog as a markdown document with an index
+// table and a section per check.
+func (c Catalog) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Checks catalog\n\n")
+	if len(c.Checks) == 0 {
+		b.WriteString("No checks found.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	b.WriteString("| Check | Asset types | Queue | Owner | Latest tag |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, check := range c.Checks {
+		name := fmt.Sprintf("[%s](#%s)", check.Name, check.Name)
+		if check.Deprecated {
+			name += " (deprecated)"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", name, strings.Join(check.AssetTypes, ", "),
+			escapeCell(check.QueueName), escapeCell(check.Owner), check.LatestTag())
+	}
+	for _, check := range c.Checks {
+		fmt.Fprintf(&b, "\n## %s\n\n", check.Name)
+		if check.Error != "" {
+			fmt.Fprintf(&b, "Invalid manifest: %s\n", check.Error)
+			continue
+		}
+		if check.Deprecated {
+			b.WriteString("**Deprecated.**\n\n")
+		}
+		fmt.Fprintf(&b, "%s\n\n", check.Description)
+		field := func(name, value string) {
+			if value != "" {
+				fmt.Fprintf(&b, "- %s: %s\n", name, value)
+			}
+		}
+		field("Path", "`"+check.Path+"`")
+		field("Asset types", strings.Join(check.AssetTypes, ", "))
+		if check.Timeout > 0 {
+			field("Timeout", fmt.Sprintf("%ds", check.Timeout))
+		}
+		field("Queue", check.QueueName)
+		field("Required vars", strings.Join(check.RequiredVars, ", "))
+		field("Owner", check.Owner)
+		field("Contact", check.Contact)
+		field("Tags", strings.Join(check.Tags, ", "))
+		field("Version", check.Version)
+		field("Vulnerability classes", strings.Join(check.VulnerabilityClasses, ", "))
+		field("Severity range", check.SeverityRange())
+		if check.DocsURL != "" {
+			field("Docs", fmt.Sprintf("<%s>", check.DocsURL))
+		}
+		if check.Image != nil {
+			img := fmt.Sprintf("tag %s", check.Image.Tag)
+			if check.Image.Commit != "" {
+				img += fmt.Sprintf(", commit %s", check.Image.Commit)
+			}
+			if !check.Image.LastModified.IsZero() {
+				img += fmt.Sprintf(", pushed on %s", check.Image.LastModified.UTC().Format("2006-01-02"))
+			}
+			field("Latest image", img)
+		}
+		if check.Options != "" {
+			fmt.Fprintf(&b, "\nDefault options:\n\n```json\n%s\n```\n", check.Options)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+var htmlTemplate = template.Must(template.New("catalog").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Checks catalog</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.deprecated { color: #888; text-decoration: line-through; }
+.error { color: #c00; }
+pre { background: #f4f4f4; padding: 8px; }
+</style>
+</head>
+<body>
+<h1>Checks catalog</h1>
+{{- if not .Checks}}
+<p>No checks found.</p>
+{{- else}}
+<table>
+<tr><th>Check</th><th>Asset types</th><th>Queue</th><th>Owner</th><th>Latest tag</th></tr>
+{{- range .Checks}}
+<tr><td><a href="#{{.Name}}"{{if .Deprecated}} class="deprecated"{{end}}>{{.Name}}</a></td><td>{{join .AssetTypes ", "}}</td><td>{{.QueueName}}</td><td>{{.Owner}}</td><td>{{.LatestTag}}</td></tr>
+{{- end}}
+</table>
+{{- range .Checks}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+{{- if .Error}}
+<p class="error">Invalid manifest: {{.Error}}</p>
+{{- else}}
+{{- if .Deprecated}}
+<p><strong>Deprecated.</strong></p>
+{{- end}}
+<p>{{.Description}}</p>
+<dl>
+<dt>Path</dt><dd><code>{{.Path}}</code></dd>
+{{- if .AssetTypes}}
+<dt>Asset types</dt><dd>{{join .AssetTypes ", "}}</dd>
+{{- end}}
+{{- if .Timeout}}
+<dt>Timeout</dt><dd>{{.Timeout}}s</dd>
+{{- end}}
+{{- if .QueueName}}
+<dt>Queue</dt><dd>{{.QueueName}}</dd>
+{{- end}}
+{{- if .RequiredVars}}
+<dt>Required vars</dt><dd>{{join .RequiredVars ", "}}</dd>
+{{- end}}
+{{- if .Owner}}
+<dt>Owner</dt><dd>{{.Owner}}</dd>
+{{- end}}
+{{- if .Contact}}
+<dt>Contact</dt><dd>{{.Contact}}</dd>
+{{- end}}
+{{- if .Tags}}
+<dt>Tags</dt><dd>{{join .Tags ", "}}</dd>
+{{- end}}
+{{- if .Version}}
+<dt>Version</dt><dd>{{.Version}}</dd>
+{{- end}}
+{{- if .VulnerabilityClasses}}
+<dt>Vulnerability classes</dt><dd>{{join .VulnerabilityClasses ", "}}</dd>
+{{- end}}
+{{- if .SeverityRange}}
+<dt>Severity range</dt><dd>{{.SeverityRange}}</dd>
+{{- end}}
+{{- if .DocsURL}}
+<dt>Docs</dt><dd><a href="{{.DocsURL}}">{{.DocsURL}}</a></dd>
+{{- end}}
+{{- with .Image}}
+<dt>Latest image</dt><dd>tag {{.Tag}}{{if .Commit}}, commit {{.Commit}}{{end}}{{if not .LastModified.IsZero}}, pushed on {{.LastModified.UTC.Format "2006-01-02"}}{{end}}</dd>
+{{- end}}
+</dl>
+{{- if .Options}}
+<p>Default options:</p>
+<pre>{{.Options}}</pre>
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
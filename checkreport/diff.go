@@ -0,0 +1,148 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package checkreport compares and renders the reports generated by the
+// checks.
+package checkreport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	report "github.com/adevinta/vulcan-report"
+)
+
+// Vulnerability contains the fields of a vulnerability of a report relevant
+// to compare two reports.
+type Vulnerability struct {
+	Summary          string  `json:"summary"`
+	Score            float32 `json:"score"`
+	AffectedResource string  `json:"affected_resource,omitempty"`
+}
+
+// VulnerabilityChange is a vulnerability present in both reports with
+// different score or resources.
+type VulnerabilityChange struct {
+	Summary          string  `json:"summary"`
+	AffectedResource string  `json:"affected_resource,omitempty"`
+	OldScore         float32 `json:"old_score"`
+	NewScore         float32 `json:"new_score"`
+	// AddedResources and RemovedResources contain the rows of the resources
+	// groups of the vulnerability added and removed, in the form
+	// group: value1, value2.
+	AddedResources   []string `json:"added_resources,omitempty"`
+	RemovedResources []string `json:"removed_resources,omitempty"`
+}
+
+// Diff contains the differences between the reports generated by two
+// versions of a check for the same target.
+type Diff struct {
+	Target    string                `json:"target"`
+	Options   string                `json:"options,omitempty"`
+	OldImage  string                `json:"old_image"`
+	NewImage  string                `json:"new_image"`
+	OldStatus string                `json:"old_status"`
+	NewStatus string                `json:"new_status"`
+	Added     []Vulnerability       `json:"added,omitempty"`
+	Removed   []Vulnerability       `json:"removed,omitempty"`
+	Changed   []VulnerabilityChange `json:"changed,omitempty"`
+}
+
+// Empty returns true if the two reports are equivalent.
+func (d Diff) Empty() bool {
+	return d.OldStatus == d.NewStatus && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Compare returns the differences between the old and the new report of a
+// check. The vulnerabilities are matched by summary and affected resource.
+func Compare(old, new report.Report) Diff {
+	d := Diff{
+		Target:    new.Target,
+		Options:   new.Options,
+		OldStatus: old.Status,
+		NewStatus: new.Status,
+	}
+	oldVulns := indexVulnerabilities(old.Vulnerabilities)
+	newVulns := indexVulnerabilities(new.Vulnerabilities)
+	for _, k := range sortedKeys(newVulns) {
+		nv := newVulns[k]
+		ov, ok := oldVulns[k]
+		if !ok {
+			d.Added = append(d.Added, vulnerability(nv))
+			continue
+		}
+		added, removed := diffResources(ov.Resources, nv.Resources)
+		if ov.Score == nv.Score && len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		d.Changed = append(d.Changed, VulnerabilityChange{
+			Summary:          nv.Summary,
+			AffectedResource: nv.AffectedResource,
+			OldScore:         ov.Score,
+			NewScore:         nv.Score,
+			AddedResources:   added,
+			RemovedResources: removed,
+		})
+	}
+	for _, k := range sortedKeys(oldVulns) {
+		if _, ok := newVulns[k]; !ok {
+			d.Removed = append(d.Removed, vulnerability(oldVulns[k]))
+		}
+	}
+	return d
+}
+
+func vulnerability(v report.Vulnerability) Vulnerability {
+	return Vulnerability{Summary: v.Summary, Score: v.Score, AffectedResource: v.AffectedResource}
+}
+
+func indexVulnerabilities(vulns []report.Vulnerability) map[string]report.Vulnerability {
+	idx := make(map[string]report.Vulnerability)
+	for _, v := range vulns {
+		idx[v.Summary+"\x00"+v.AffectedResource] = v
+	}
+	return idx
+}
+
+func sortedKeys(m map[string]report.Vulnerability) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffResources returns the rows of the resources groups added and removed.
+func diffResources(old, new []report.ResourcesGroup) (added, removed []string) {
+	oldRows, newRows := resourceRows(old), resourceRows(new)
+	for r := range newRows {
+		if !oldRows[r] {
+			added = append(added, r)
+		}
+	}
+	for r := range oldRows {
+		if !newRows[r] {
+			removed = append(removed, r)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+func resourceRows(groups []report.ResourcesGroup) map[string]bool {
+	rows := make(map[string]bool)
+	for _, g := range groups {
+		for _, row := range g.Rows {
+			var values []string
+			for _, h := range g.Header {
+				values = append(values, row[h])
+			}
+			rows[fmt.Sprintf("%s: %s", g.Name, strings.Join(values, ", "))] = true
+		}
+	}
+	return rows
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package checkreport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	report "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompare(t *testing.T) {
+	old := report.Report{
+		CheckData: report.CheckData{Status: "FINISHED", Target: "example.com"},
+		ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+			{Summary: "Unchanged", Score: 1},
+			{Summary: "Removed", Score: 5, AffectedResource: "443/tcp"},
+			{Summary: "Rescored", Score: 3.9},
+			{
+				Summary: "Resources",
+				Score:   0,
+				Resources: []report.ResourcesGroup{
+					{Name: "Ports", Header: []string{"Port", "Service"}, Rows: []map[string]string{
+						{"Port": "80", "Service": "http"},
+						{"Port": "22", "Service": "ssh"},
+					}},
+				},
+			},
+		}},
+	}
+	new := report.Report{
+		CheckData: report.CheckData{Status: "FINISHED", Target: "example.com"},
+		ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+			{Summary: "Added", Score: 8.9, AffectedResource: "80/tcp"},
+			{Summary: "Rescored", Score: 6.9},
+			{
+				Summary: "Resources",
+				Score:   0,
+				Resources: []report.ResourcesGroup{
+					{Name: "Ports", Header: []string{"Port", "Service"}, Rows: []map[string]string{
+						{"Port": "80", "Service": "http"},
+						{"Port": "443", "Service": "https"},
+					}},
+				},
+			},
+			{Summary: "Unchanged", Score: 1},
+		}},
+	}
+	want := Diff{
+		Target:    "example.com",
+		OldStatus: "FINISHED",
+		NewStatus: "FINISHED",
+		Added:     []Vulnerability{{Summary: "Added", Score: 8.9, AffectedResource: "80/tcp"}},
+		Removed:   []Vulnerability{{Summary: "Removed", Score: 5, AffectedResource: "443/tcp"}},
+		Changed: []VulnerabilityChange{
+			{Summary: "Rescored", OldScore: 3.9, NewScore: 6.9},
+			{
+				Summary:          "Resources",
+				AddedResources:   []string{"Ports: 443, https"},
+				RemovedResources: []string{"Ports: 22, ssh"},
+			},
+		},
+	}
+	got := Compare(old, new)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Compare() mismatch (-want +got):\n%s", diff)
+	}
+	if got.Empty() {
+		t.Error("Empty() = true, want false")
+	}
+	if d := Compare(old, old); !d.Empty() {
+		t.Errorf("Compare() of the same report = %+v, want empty", d)
+	}
+}
+
+func TestWriteMarkdownDiffs(t *testing.T) {
+	diffs := []Diff{
+		{
+			Target:    "example.com",
+			OldImage:  "vulcan-check:3",
+			NewImage:  "vulcan-check-experimental",
+			OldStatus: "FINISHED",
+			NewStatus: "FAILED",
+			Added:     []Vulnerability{{Summary: "A | B", Score: 8.9}},
+			Changed:   []VulnerabilityChange{{Summary: "C", OldScore: 3.9, NewScore: 6.9}},
+		},
+		{
+			Target:    "example.org",
+			OldStatus: "FINISHED",
+			NewStatus: "FINISHED",
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteMarkdownDiffs(&buf, diffs); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"### Target `example.com`",
+		"Status changed: **FINISHED** → **FAILED**",
+		`| added | A \| B |  | 8.9 |`,
+		"| changed | C |  | 3.9 → 6.9 |",
+		"### Target `example.org`",
+		"No differences.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteMarkdownDiffs() output doesn't contain %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package checkreport
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdownDiffs writes the differences between the reports of two
+// versions of a check as markdown, suitable for a pull request comment.
+func WriteMarkdownDiffs(w io.Writer, diffs []Diff) error {
+	var b strings.Builder
+	b.WriteString("## Check report comparison\n")
+	for _, d := range diffs {
+		fmt.Fprintf(&b, "\n### Target `%s`\n\n", d.Target)
+		if d.Options != "" {
+			fmt.Fprintf(&b, "Options: `%s`\n\n", d.Options)
+		}
+		fmt.Fprintf(&b, "`%s` → `%s`\n\n", d.OldImage, d.NewImage)
+		if d.Empty() {
+			b.WriteString("No differences.\n")
+			continue
+		}
+		if d.OldStatus != d.NewStatus {
+			fmt.Fprintf(&b, "Status changed: **%s** → **%s**\n\n", d.OldStatus, d.NewStatus)
+		}
+		if len(d.Added)+len(d.Removed)+len(d.Changed) == 0 {
+			continue
+		}
+		b.WriteString("| Change | Vulnerability | Affected resource | Score |\n")
+		b.WriteString("|---|---|---|---|\n")
+		for _, v := range d.Added {
+			fmt.Fprintf(&b, "| added | %s | %s | %.1f |\n", escapeCell(v.Summary), escapeCell(v.AffectedResource), v.Score)
+		}
+		for _, v := range d.Removed {
+			fmt.Fprintf(&b, "| removed | %s | %s | %.1f |\n", escapeCell(v.Summary), escapeCell(v.AffectedResource), v.Score)
+		}
+		for _, v := range d.Changed {
+			score := fmt.Sprintf("%.1f", v.NewScore)
+			if v.OldScore != v.NewScore {
+				score = fmt.Sprintf("%.1f → %.1f", v.OldScore, v.NewScore)
+			}
+			var res []string
+			for _, r := range v.AddedResources {
+				res = append(res, "+"+r)
+			}
+			for _, r := range v.RemovedResources {
+				res = append(res, "-"+r)
+			}
+			change := "changed"
+			if len(res) > 0 {
+				change = "changed: " + strings.Join(res, "<br>")
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", escapeCell(change), escapeCell(v.Summary), escapeCell(v.AffectedResource), score)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
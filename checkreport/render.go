@@ -0,0 +1,340 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package checkreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	report "github.com/adevinta/vulcan-report"
+
+	"github.com/adevinta/vulcan-checks-bsys/junit"
+	"github.com/adevinta/vulcan-checks-bsys/sarif"
+)
+
+// Formats supported by Render.
+const (
+	FormatJSON     = "json"
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatSARIF    = "sarif"
+	FormatJUnit    = "junit"
+)
+
+// Formats returns the formats supported by Render.
+func Formats() []string {
+	return []string{FormatJSON, FormatText, FormatMarkdown, FormatHTML, FormatSARIF, FormatJUnit}
+}
+
+// ValidateFormat returns an error if the format is not supported by Render.
+func ValidateFormat(format string) error {
+	for _, f := range Formats() {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q, valid formats are: %s", format, strings.Join(Formats(), ", "))
+}
+
+// Render writes a report in the given format.
+func Render(w io.Writer, format string, r report.Report) error {
+	switch format {
+	case FormatJSON:
+		content, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	case FormatText:
+		return renderText(w, r)
+	case FormatMarkdown:
+		return renderMarkdown(w, r)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, newReportView(r))
+	case FormatSARIF:
+		return SARIF(r).Write(w)
+	case FormatJUnit:
+		return JUnit(r).Write(w)
+	}
+	return ValidateFormat(format)
+}
+
+// SeverityName returns the name of the severity corresponding to a score.
+func SeverityName(score float32) string {
+	switch report.RankSeverity(score) {
+	case report.SeverityLow:
+		return "LOW"
+	case report.SeverityMedium:
+		return "MEDIUM"
+	case report.SeverityHigh:
+		return "HIGH"
+	case report.SeverityCritical:
+		return "CRITICAL"
+	}
+	return "INFO"
+}
+
+// reportView contains the fields of a report shown by the renderers, with
+// the vulnerabilities sorted by score.
+type reportView struct {
+	report.Report
+	Counts          []severityCount
+	Vulnerabilities []report.Vulnerability
+}
+
+type severityCount struct {
+	Severity string
+	Count    int
+}
+
+func newReportView(r report.Report) reportView {
+	vulns := append([]report.Vulnerability(nil), r.Vulnerabilities...)
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if vulns[i].Score != vulns[j].Score {
+			return vulns[i].Score > vulns[j].Score
+		}
+		return vulns[i].Summary < vulns[j].Summary
+	})
+	counts := make(map[string]int)
+	for _, v := range vulns {
+		counts[SeverityName(v.Score)]++
+	}
+	var sc []severityCount
+	for _, s := range []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "INFO"} {
+		if counts[s] > 0 {
+			sc = append(sc, severityCount{Severity: s, Count: counts[s]})
+		}
+	}
+	return reportView{Report: r, Counts: sc, Vulnerabilities: vulns}
+}
+
+func renderText(w io.Writer, r report.Report) error {
+	v := newReportView(r)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Check:  %s\n", r.ChecktypeName)
+	fmt.Fprintf(&b, "Target: %s\n", r.Target)
+	fmt.Fprintf(&b, "Status: %s\n", r.Status)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "Error:  %s\n", r.Error)
+	}
+	var counts []string
+	for _, c := range v.Counts {
+		counts = append(counts, fmt.Sprintf("%d %s", c.Count, c.Severity))
+	}
+	fmt.Fprintf(&b, "Vulnerabilities: %d", len(v.Vulnerabilities))
+	if len(counts) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(counts, ", "))
+	}
+	b.WriteString("\n")
+	for _, vuln := range v.Vulnerabilities {
+		fmt.Fprintf(&b, "\n[%s %.1f] %s\n", SeverityName(vuln.Score), vuln.Score, vuln.Summary)
+		if res := affectedResource(vuln); res != "" {
+			fmt.Fprintf(&b, "  Resource: %s\n", res)
+		}
+		if vuln.Details != "" {
+			fmt.Fprintf(&b, "  Details: %s\n", strings.ReplaceAll(strings.TrimSpace(vuln.Details), "\n", "\n    "))
+		}
+		for _, rec := range vuln.Recommendations {
+			fmt.Fprintf(&b, "  Recommendation: %s\n", rec)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderMarkdown(w io.Writer, r report.Report) error {
+	v := newReportView(r)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s report for `%s`\n\n", r.ChecktypeName, r.Target)
+	fmt.Fprintf(&b, "Status: **%s**\n", r.Status)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "\nError: %s\n", r.Error)
+	}
+	if len(v.Vulnerabilities) == 0 {
+		b.WriteString("\nNo vulnerabilities found.\n")
+	} else {
+		b.WriteString("\n| Severity | Score | Vulnerability | Affected resource |\n")
+		b.WriteString("|---|---|---|---|\n")
+		for _, vuln := range v.Vulnerabilities {
+			fmt.Fprintf(&b, "| %s | %.1f | %s | %s |\n", SeverityName(vuln.Score), vuln.Score,
+				escapeCell(vuln.Summary), escapeCell(affectedResource(vuln)))
+		}
+		for _, vuln := range v.Vulnerabilities {
+			fmt.Fprintf(&b, "\n## %s\n", vuln.Summary)
+			if vuln.Description != "" {
+				fmt.Fprintf(&b, "\n%s\n", vuln.Description)
+			}
+			if vuln.Details != "" {
+				fmt.Fprintf(&b, "\n```\n%s\n```\n", strings.TrimSpace(vuln.Details))
+			}
+			if len(vuln.Recommendations) > 0 {
+				b.WriteString("\nRecommendations:\n\n")
+				for _, rec := range vuln.Recommendations {
+					fmt.Fprintf(&b, "- %s\n", rec)
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"severity": SeverityName,
+	"resource": affectedResource,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.ChecktypeName}} report for {{.Target}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.CRITICAL { color: #7b0000; } .HIGH { color: #c00; } .MEDIUM { color: #d68000; } .LOW { color: #b5a000; } .INFO { color: #555; }
+pre { background: #f4f4f4; padding: 8px; }
+</style>
+</head>
+<body>
+<h1>{{.ChecktypeName}} report for {{.Target}}</h1>
+<p>Status: <strong>{{.Status}}</strong></p>
+{{- if .Error}}
+<p>Error: {{.Error}}</p>
+{{- end}}
+{{- if .Vulnerabilities}}
+<table>
+<tr><th>Severity</th><th>Score</th><th>Vulnerability</th><th>Affected resource</th></tr>
+{{- range .Vulnerabilities}}
+<tr><td class="{{severity .Score}}">{{severity .Score}}</td><td>{{printf "%.1f" .Score}}</td><td>{{.Summary}}</td><td>{{resource .}}</td></tr>
+{{- end}}
+</table>
+{{- range .Vulnerabilities}}
+<h2 class="{{severity .Score}}">{{.Summary}}</h2>
+{{- if .Description}}
+<p>{{.Description}}</p>
+{{- end}}
+{{- if .Details}}
+<pre>{{.Details}}</pre>
+{{- end}}
+{{- if .Recommendations}}
+<ul>
+{{- range .Recommendations}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- end}}
+{{- else}}
+<p>No vulnerabilities found.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// SARIF returns the vulnerabilities of a report as SARIF results. There is a
+// rule for each different summary, and the level of the results depends on
+// the score of the vulnerabilities.
+func SARIF(r report.Report) sarif.Log {
+	v := newReportView(r)
+	var (
+		rules   []sarif.Rule
+		results []sarif.Result
+		seen    = make(map[string]bool)
+	)
+	for _, vuln := range v.Vulnerabilities {
+		id := ruleID(vuln.Summary)
+		if !seen[id] {
+			seen[id] = true
+			rules = append(rules, sarif.Rule{ID: id, ShortDescription: &sarif.Message{Text: vuln.Summary}})
+		}
+		msg := vuln.Summary
+		if res := affectedResource(vuln); res != "" {
+			msg = fmt.Sprintf("%s: %s", vuln.Summary, res)
+		}
+		results = append(results, sarif.Result{
+			RuleID:    id,
+			Level:     sarifLevel(vuln.Score),
+			Message:   sarif.Message{Text: msg},
+			Locations: []sarif.Location{sarif.NewLocation(r.Target, 0)},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	name := r.ChecktypeName
+	if name == "" {
+		name = "vulcan-check"
+	}
+	return sarif.New(sarif.Driver{Name: name, Rules: rules}, results)
+}
+
+func sarifLevel(score float32) string {
+	switch report.RankSeverity(score) {
+	case report.SeverityHigh, report.SeverityCritical:
+		return sarif.LevelError
+	case report.SeverityMedium:
+		return sarif.LevelWarning
+	case report.SeverityLow:
+		return sarif.LevelNote
+	}
+	return sarif.LevelNone
+}
+
+var nonAlnumRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ruleID returns a rule ID derived from the summary of a vulnerability, e.g.:
+// address-accepting-connections.
+func ruleID(summary string) string {
+	return strings.Trim(nonAlnumRegexp.ReplaceAllString(strings.ToLower(summary), "-"), "-")
+}
+
+// JUnit returns a report as a JUnit test suite, with a test case for each
+// vulnerability. The vulnerabilities with a score greater than zero are
+// reported as failures, and a failed check as a failed test case.
+func JUnit(r report.Report) junit.TestSuites {
+	v := newReportView(r)
+	name := fmt.Sprintf("%s %s", r.ChecktypeName, r.Target)
+	suite := junit.TestSuite{Name: strings.TrimSpace(name), Time: fmt.Sprintf("%.3f", r.EndTime.Sub(r.StartTime).Seconds())}
+	if r.Status != "" && r.Status != "FINISHED" {
+		suite.TestCases = append(suite.TestCases, junit.TestCase{
+			Name:      "check status",
+			Time:      "0.000",
+			Classname: r.ChecktypeName,
+			Failure:   &junit.Failure{Message: fmt.Sprintf("check finished with status %s", r.Status), Output: r.Error},
+		})
+	}
+	for _, vuln := range v.Vulnerabilities {
+		tc := junit.TestCase{Name: vuln.Summary, Classname: r.ChecktypeName, Time: "0.000"}
+		if res := affectedResource(vuln); res != "" {
+			tc.Name = fmt.Sprintf("%s (%s)", vuln.Summary, res)
+		}
+		if vuln.Score > 0 {
+			tc.Failure = &junit.Failure{
+				Message: fmt.Sprintf("%s %.1f", SeverityName(vuln.Score), vuln.Score),
+				Output:  vuln.Details,
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	for _, tc := range suite.TestCases {
+		suite.Tests++
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+	return junit.TestSuites{Tests: suite.Tests, Failures: suite.Failures, Suites: []junit.TestSuite{suite}}
+}
+
+func affectedResource(v report.Vulnerability) string {
+	if v.AffectedResourceString != "" {
+		return v.AffectedResourceString
+	}
+	return v.AffectedResource
+}
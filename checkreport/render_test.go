@@ -0,0 +1,127 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package checkreport
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	report "github.com/adevinta/vulcan-report"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var testReport = report.Report{
+	CheckData: report.CheckData{
+		CheckID:       "a1b2c3d4",
+		ChecktypeName: "vulcan-exposed-http",
+		Status:        "FINISHED",
+		Target:        "example.com",
+		StartTime:     time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2024, time.June, 1, 10, 0, 30, 0, time.UTC),
+	},
+	ResultData: report.ResultData{
+		Vulnerabilities: []report.Vulnerability{
+			{
+				Summary:          "Exposed HTTP Port",
+				Score:            0,
+				AffectedResource: "80/tcp",
+			},
+			{
+				Summary:          "Outdated <TLS> Version",
+				Score:            7.5,
+				AffectedResource: "443/tcp",
+				Description:      "The server supports TLS 1.0.",
+				Details:          "TLSv1.0 accepted",
+				Recommendations:  []string{"Disable TLS 1.0 and 1.1."},
+			},
+			{
+				Summary: "Missing | Security Headers",
+				Score:   3.9,
+			},
+		},
+	},
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format string
+		golden string
+	}{
+		{format: FormatJSON, golden: "report.json"},
+		{format: FormatText, golden: "report.txt"},
+		{format: FormatMarkdown, golden: "report.md"},
+		{format: FormatHTML, golden: "report.html"},
+		{format: FormatSARIF, golden: "report.sarif"},
+		{format: FormatJUnit, golden: "report.junit.xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Render(&buf, tt.format, testReport); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatalf("Error writing golden file %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("Render() got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRenderInvalidFormat(t *testing.T) {
+	if err := Render(&bytes.Buffer{}, "pdf", testReport); err == nil {
+		t.Error("Render() expected error, got nil")
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	tests := []struct {
+		name         string
+		report       report.Report
+		wantTests    int
+		wantFailures int
+	}{
+		{
+			name:         "Finished",
+			report:       testReport,
+			wantTests:    3,
+			wantFailures: 2,
+		},
+		{
+			name: "Failed",
+			report: report.Report{
+				CheckData: report.CheckData{ChecktypeName: "vulcan-exposed-http", Status: "FAILED"},
+				ResultData: report.ResultData{
+					Vulnerabilities: []report.Vulnerability{{Summary: "Exposed HTTP Port"}},
+					Error:           "connection refused",
+				},
+			},
+			wantTests:    2,
+			wantFailures: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := JUnit(tt.report)
+			if got.Tests != tt.wantTests || got.Failures != tt.wantFailures {
+				t.Errorf("JUnit() got %d tests and %d failures, want %d and %d", got.Tests, got.Failures, tt.wantTests, tt.wantFailures)
+			}
+		})
+	}
+}
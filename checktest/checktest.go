@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package checktest defines the test cases of a check, that run the check
+// against a fixture target and assert on the report it generates.
+package checktest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	report "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// File is the name of the file of a check dir that contains its test cases.
+const File = "testcases.toml"
+
+// DefaultStatus is the status expected when a test case doesn't define it.
+const DefaultStatus = "FINISHED"
+
+// Case is a test case of a check. Example:
+//
+//	[[case]]
+//	name = "Open port"
+//	target = "127.0.0.1:8080"
+//	asset_type = "IP"
+//	options = '{"port": 8080}'
+//	status = "FINISHED"
+//
+//	[[case.vulnerabilities]]
+//	summary = "Address accepting connections"
+//	score = 0.0
+type Case struct {
+	Name      string `toml:"name"`
+	Target    string `toml:"target"`
+	AssetType string `toml:"asset_type"`
+	Options   string `toml:"options"`
+	// RequiredVars are added to the required vars defined in the local.toml
+	// of the check, if any.
+	RequiredVars    map[string]string `toml:"required_vars"`
+	Status          string            `toml:"status"`
+	Vulnerabilities []Vulnerability   `toml:"vulnerabilities"`
+}
+
+// Vulnerability is a vulnerability expected in the report of a test case.
+type Vulnerability struct {
+	Summary string  `toml:"summary"`
+	Score   float32 `toml:"score"`
+}
+
+// Read reads the test cases from a file.
+func Read(path string) ([]Case, error) {
+	var f struct {
+		Cases []Case `toml:"case"`
+	}
+	if _, err := toml.DecodeFile(path, &f); err != nil {
+		return nil, err
+	}
+	if len(f.Cases) == 0 {
+		return nil, fmt.Errorf("no test cases defined in %s", path)
+	}
+	names := make(map[string]bool)
+	for n, c := range f.Cases {
+		if c.Name == "" || c.Target == "" {
+			return nil, fmt.Errorf("test case %d of %s: name and target are mandatory", n+1, path)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicated test case %q in %s", c.Name, path)
+		}
+		names[c.Name] = true
+		if c.Status == "" {
+			f.Cases[n].Status = DefaultStatus
+		}
+	}
+	return f.Cases, nil
+}
+
+// result contains the fields of a report asserted by the test cases.
+type result struct {
+	Status          string
+	Vulnerabilities []Vulnerability
+}
+
+// Diff compares the report generated by the check with the expected result
+// of the test case. It returns an empty string if they match, or a
+// human-readable report of the differences in the form (-want +got). The
+// order of the vulnerabilities is ignored.
+func (c Case) Diff(r report.Report) string {
+	want := result{Status: c.Status, Vulnerabilities: c.Vulnerabilities}
+	got := result{Status: r.Status}
+	for _, v := range r.Vulnerabilities {
+		got.Vulnerabilities = append(got.Vulnerabilities, Vulnerability{Summary: v.Summary, Score: v.Score})
+	}
+	return cmp.Diff(want, got,
+		cmpopts.EquateEmpty(),
+		cmpopts.EquateApprox(0, 0.01),
+		cmpopts.SortSlices(func(a, b Vulnerability) bool {
+			if a.Summary != b.Summary {
+				return a.Summary < b.Summary
+			}
+			return a.Score < b.Score
+		}),
+	)
+}
+
+// Result is the result of running a test case.
+type Result struct {
+	Case string
+	// Diff contains the differences between the expected and the actual
+	// result.
+	Diff string
+	// Err contains the error running the check, if any.
+	Err error
+}
+
+// Passed returns true if the test case passed.
+func (r Result) Passed() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// Failed returns the names of the test cases that didn't pass, sorted.
+func Failed(results []Result) []string {
+	var failed []string
+	for _, r := range results {
+		if !r.Passed() {
+			failed = append(failed, r.Case)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
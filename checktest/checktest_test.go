@@ -0,0 +1,152 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package checktest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	report "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRead(t *testing.T) {
+	got, err := Read("testdata/testcases.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Case{
+		{
+			Name:      "Open port",
+			Target:    "127.0.0.1:8080",
+			AssetType: "IP",
+			Status:    DefaultStatus,
+			Vulnerabilities: []Vulnerability{
+				{Summary: "Address accepting connections"},
+			},
+		},
+		{
+			Name:         "Closed port",
+			Target:       "127.0.0.1:8081",
+			Options:      `{"timeout": 1}`,
+			Status:       "FAILED",
+			RequiredVars: map[string]string{"API_KEY": "test"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "NoCases",
+			content: "",
+		},
+		{
+			name:    "NoTarget",
+			content: "[[case]]\nname = \"a\"\n",
+		},
+		{
+			name:    "Duplicated",
+			content: "[[case]]\nname = \"a\"\ntarget = \"t\"\n[[case]]\nname = \"a\"\ntarget = \"t\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), File)
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Read(file); err == nil {
+				t.Error("Read() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCaseDiff(t *testing.T) {
+	c := Case{
+		Status: DefaultStatus,
+		Vulnerabilities: []Vulnerability{
+			{Summary: "A", Score: 3.9},
+			{Summary: "B", Score: 0},
+		},
+	}
+	tests := []struct {
+		name     string
+		report   report.Report
+		wantDiff bool
+	}{
+		{
+			name: "SameInOtherOrder",
+			report: report.Report{
+				CheckData: report.CheckData{Status: "FINISHED"},
+				ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+					{Summary: "B", Details: "ignored"},
+					{Summary: "A", Score: 3.9},
+				}},
+			},
+		},
+		{
+			name: "OtherScore",
+			report: report.Report{
+				CheckData: report.CheckData{Status: "FINISHED"},
+				ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+					{Summary: "A", Score: 6.9},
+					{Summary: "B"},
+				}},
+			},
+			wantDiff: true,
+		},
+		{
+			name: "MissingVulnerability",
+			report: report.Report{
+				CheckData: report.CheckData{Status: "FINISHED"},
+				ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+					{Summary: "A", Score: 3.9},
+				}},
+			},
+			wantDiff: true,
+		},
+		{
+			name: "OtherStatus",
+			report: report.Report{
+				CheckData: report.CheckData{Status: "FAILED"},
+				ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+					{Summary: "A", Score: 3.9},
+					{Summary: "B"},
+				}},
+			},
+			wantDiff: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := c.Diff(tt.report)
+			if (diff != "") != tt.wantDiff {
+				t.Errorf("Diff() = %q, wantDiff %v", diff, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestFailed(t *testing.T) {
+	results := []Result{
+		{Case: "c"},
+		{Case: "b", Diff: "-A\n+B"},
+		{Case: "a", Err: os.ErrNotExist},
+	}
+	want := []string{"a", "b"}
+	if got := Failed(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("Failed() = %v, want %v", got, want)
+	}
+}
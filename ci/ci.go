@@ -0,0 +1,142 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package ci detects the branch, the commit and the pull request being built
+// from the env vars of the CI provider running the build, or from the git
+// repo when the build doesn't run in a known CI provider.
+package ci
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultProductionBranch is the production branch used when none is
+// configured.
+const DefaultProductionBranch = "master"
+
+// CI providers detected.
+const (
+	GitHubActions = "github-actions"
+	GitLabCI      = "gitlab-ci"
+	Jenkins       = "jenkins"
+	Travis        = "travis"
+	Git           = "git"
+)
+
+// Env contains the info about the build.
+type Env struct {
+	Provider string
+	Branch   string
+	Commit   string
+	// PullRequest is the number of the pull request being built, empty if
+	// the build is not of a pull request.
+	PullRequest string
+	// Actor is the user that triggered the build, empty if the provider
+	// doesn't define it.
+	Actor string
+}
+
+// IsProduction returns true if the build is of the production branch and
+// not of a pull request. An empty prodBranch is the DefaultProductionBranch.
+func (e Env) IsProduction(prodBranch string) bool {
+	if prodBranch == "" {
+		prodBranch = DefaultProductionBranch
+	}
+	return e.PullRequest == "" && e.Branch == prodBranch
+}
+
+func (e Env) String() string {
+	s := fmt.Sprintf("provider: %s, branch: %s, commit: %s", e.Provider, e.Branch, e.Commit)
+	if e.PullRequest != "" {
+		s += ", pull request: " + e.PullRequest
+	}
+	return s
+}
+
+// Detect returns the info about the build from the env vars of the process
+// and the git repo of the working directory. The fields that can't be
+// detected are empty, e.g. the branch of a local build outside a git repo.
+func Detect() Env {
+	return detect(os.Getenv, runGit)
+}
+
+func runGit(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("error running git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// detect returns the info about the build using the env vars of the first CI
+// provider detected. If no provider is detected the branch is taken from the
+// git repo, as well as the commit if the provider doesn't define it.
+func detect(getenv func(string) string, git func(args ...string) (string, error)) Env {
+	var e Env
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		e = Env{Provider: GitHubActions, Commit: getenv("GITHUB_SHA"), Actor: getenv("GITHUB_ACTOR")}
+		if head := getenv("GITHUB_HEAD_REF"); head != "" {
+			e.Branch = head
+			// The ref of the pull requests is refs/pull/<number>/merge.
+			e.PullRequest = strings.TrimSuffix(strings.TrimPrefix(getenv("GITHUB_REF"), "refs/pull/"), "/merge")
+		} else if b, ok := strings.CutPrefix(getenv("GITHUB_REF"), "refs/heads/"); ok {
+			e.Branch = b
+		}
+	case getenv("GITLAB_CI") == "true":
+		e = Env{
+			Provider:    GitLabCI,
+			Branch:      firstNonEmpty(getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"), getenv("CI_COMMIT_BRANCH")),
+			Commit:      getenv("CI_COMMIT_SHA"),
+			PullRequest: getenv("CI_MERGE_REQUEST_IID"),
+			Actor:       getenv("GITLAB_USER_LOGIN"),
+		}
+	case getenv("JENKINS_URL") != "":
+		e = Env{
+			Provider:    Jenkins,
+			Branch:      firstNonEmpty(getenv("CHANGE_BRANCH"), getenv("BRANCH_NAME"), strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/")),
+			Commit:      getenv("GIT_COMMIT"),
+			PullRequest: getenv("CHANGE_ID"),
+			// Set by the build user vars plugin.
+			Actor: getenv("BUILD_USER_ID"),
+		}
+	// TRAVIS_BRANCH alone is also accepted, as it was the way of setting the
+	// branch of local builds.
+	case getenv("TRAVIS") == "true" || getenv("TRAVIS_BRANCH") != "":
+		e = Env{
+			Provider: Travis,
+			Branch:   firstNonEmpty(getenv("TRAVIS_PULL_REQUEST_BRANCH"), getenv("TRAVIS_BRANCH")),
+			Commit:   getenv("TRAVIS_COMMIT"),
+		}
+		if pr := getenv("TRAVIS_PULL_REQUEST"); pr != "" && pr != "false" {
+			e.PullRequest = pr
+		}
+	default:
+		e = Env{Provider: Git}
+	}
+	if e.Provider == Git {
+		// A detached HEAD has no branch.
+		if branch, err := git("rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+			e.Branch = branch
+		}
+	}
+	if e.Commit == "" {
+		if commit, err := git("rev-parse", "HEAD"); err == nil {
+			e.Commit = commit
+		}
+	}
+	return e
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
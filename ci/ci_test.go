@@ -0,0 +1,164 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package ci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetect(t *testing.T) {
+	gitRepo := func(args ...string) (string, error) {
+		if len(args) > 1 && args[1] == "--abbrev-ref" {
+			return "feature/x", nil
+		}
+		return "a1b2c3d", nil
+	}
+	detachedHead := func(args ...string) (string, error) {
+		if len(args) > 1 && args[1] == "--abbrev-ref" {
+			return "HEAD", nil
+		}
+		return "a1b2c3d", nil
+	}
+	noRepo := func(args ...string) (string, error) {
+		return "", errors.New("not a git repository")
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		git  func(args ...string) (string, error)
+		want Env
+	}{
+		{
+			name: "GitHubActionsPush",
+			env:  map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REF": "refs/heads/main", "GITHUB_SHA": "f00", "GITHUB_ACTOR": "jdoe"},
+			git:  noRepo,
+			want: Env{Provider: GitHubActions, Branch: "main", Commit: "f00", Actor: "jdoe"},
+		},
+		{
+			name: "GitHubActionsPullRequest",
+			env: map[string]string{
+				"GITHUB_ACTIONS":  "true",
+				"GITHUB_REF":      "refs/pull/42/merge",
+				"GITHUB_HEAD_REF": "feature/x",
+				"GITHUB_SHA":      "f00",
+			},
+			git:  noRepo,
+			want: Env{Provider: GitHubActions, Branch: "feature/x", Commit: "f00", PullRequest: "42"},
+		},
+		{
+			name: "GitHubActionsTag",
+			env:  map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REF": "refs/tags/v1.0.0", "GITHUB_SHA": "f00"},
+			git:  gitRepo,
+			want: Env{Provider: GitHubActions, Commit: "f00"},
+		},
+		{
+			name: "GitLabCIMergeRequest",
+			env: map[string]string{
+				"GITLAB_CI":                           "true",
+				"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME": "feature/x",
+				"CI_MERGE_REQUEST_IID":                "7",
+				"CI_COMMIT_SHA":                       "f00",
+			},
+			git:  noRepo,
+			want: Env{Provider: GitLabCI, Branch: "feature/x", Commit: "f00", PullRequest: "7"},
+		},
+		{
+			name: "GitLabCIBranch",
+			env:  map[string]string{"GITLAB_CI": "true", "CI_COMMIT_BRANCH": "main", "CI_COMMIT_SHA": "f00", "GITLAB_USER_LOGIN": "jdoe"},
+			git:  noRepo,
+			want: Env{Provider: GitLabCI, Branch: "main", Commit: "f00", Actor: "jdoe"},
+		},
+		{
+			name: "JenkinsGitBranch",
+			env:  map[string]string{"JENKINS_URL": "https://jenkins.example.com", "GIT_BRANCH": "origin/main", "GIT_COMMIT": "f00"},
+			git:  noRepo,
+			want: Env{Provider: Jenkins, Branch: "main", Commit: "f00"},
+		},
+		{
+			name: "JenkinsChangeRequest",
+			env: map[string]string{
+				"JENKINS_URL":   "https://jenkins.example.com",
+				"BRANCH_NAME":   "PR-3",
+				"CHANGE_BRANCH": "feature/x",
+				"CHANGE_ID":     "3",
+			},
+			git:  gitRepo,
+			want: Env{Provider: Jenkins, Branch: "feature/x", Commit: "a1b2c3d", PullRequest: "3"},
+		},
+		{
+			name: "TravisPullRequest",
+			env: map[string]string{
+				"TRAVIS":                     "true",
+				"TRAVIS_BRANCH":              "master",
+				"TRAVIS_PULL_REQUEST_BRANCH": "feature/x",
+				"TRAVIS_PULL_REQUEST":        "12",
+				"TRAVIS_COMMIT":              "f00",
+			},
+			git:  noRepo,
+			want: Env{Provider: Travis, Branch: "feature/x", Commit: "f00", PullRequest: "12"},
+		},
+		{
+			name: "TravisPush",
+			env:  map[string]string{"TRAVIS": "true", "TRAVIS_BRANCH": "master", "TRAVIS_PULL_REQUEST": "false", "TRAVIS_COMMIT": "f00"},
+			git:  noRepo,
+			want: Env{Provider: Travis, Branch: "master", Commit: "f00"},
+		},
+		{
+			name: "TravisBranchOverride",
+			env:  map[string]string{"TRAVIS_BRANCH": "nessus"},
+			git:  gitRepo,
+			want: Env{Provider: Travis, Branch: "nessus", Commit: "a1b2c3d"},
+		},
+		{
+			name: "Git",
+			git:  gitRepo,
+			want: Env{Provider: Git, Branch: "feature/x", Commit: "a1b2c3d"},
+		},
+		{
+			name: "GitDetachedHead",
+			git:  detachedHead,
+			want: Env{Provider: Git, Commit: "a1b2c3d"},
+		},
+		{
+			name: "NoGitRepo",
+			git:  noRepo,
+			want: Env{Provider: Git},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			got := detect(getenv, tt.git)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("env mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestEnv_IsProduction(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        Env
+		prodBranch string
+		want       bool
+	}{
+		{name: "DefaultBranch", env: Env{Branch: "master"}, want: true},
+		{name: "ConfiguredBranch", env: Env{Branch: "main"}, prodBranch: "main", want: true},
+		{name: "OtherBranch", env: Env{Branch: "master"}, prodBranch: "main"},
+		{name: "PullRequest", env: Env{Branch: "main", PullRequest: "42"}, prodBranch: "main"},
+		{name: "NoBranch", env: Env{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.env.IsProduction(tt.prodBranch); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/adevinta/vulcan-checks-bsys/catalog"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// generateCatalog writes the catalog of the checks under root, in the format
+// specified in the catalog-format flag, markdown by default, to the output
+// file or to stdout. Unless the catalog-offline flag is specified, the
+// catalog includes the info of the latest image of each check in the
+// registry.
+func generateCatalog(root string) error {
+	var lookup catalog.ImageLookup
+	if !offline {
+		lookup = fetchLatestImage
+	}
+	c, err := catalog.Build(root, lookup)
+	if err != nil {
+		return err
+	}
+
+	f := catalogFormat
+	if f == "" {
+		f = catalog.FormatMarkdown
+	}
+	err = writeOutput(func(w io.Writer) error {
+		return c.Write(w, f)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Catalog generated", "dir", root, "checks", len(c.Checks))
+	return nil
+}
+
+// fetchLatestImage returns the info of the image with the latest tag of a
+// check in the registry.
+func fetchLatestImage(check string) (*catalog.Image, error) {
+	info, err := util.FetchImagesInfo(check)
+	if err != nil {
+		return nil, err
+	}
+	tag, found := latestTag(info.Tags)
+	if !found {
+		return nil, nil
+	}
+	tagInfo, err := util.FetchImageTagInfo(check, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &catalog.Image{
+		Tag:          tag,
+		Commit:       tagInfo.Commit,
+		SDKVersion:   tagInfo.SDKVersion,
+		LastModified: tagInfo.LastModified,
+	}, nil
+}
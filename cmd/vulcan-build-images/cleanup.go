@@ -0,0 +1,230 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/lint"
+	"github.com/adevinta/vulcan-checks-bsys/naming"
+	"github.com/adevinta/vulcan-checks-bsys/persistence"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// branchLeftover is an image or a checktype of a check built from a branch
+// that doesn't exist anymore.
+type branchLeftover struct {
+	name  string
+	check string
+	slug  string
+	// endpoint and id are the persistence service and the id of the
+	// checktype, empty for the images.
+	endpoint string
+	id       string
+	deleted  bool
+}
+
+func (l branchLeftover) String() string {
+	s := l.entry()
+	if l.deleted {
+		s += ", deleted"
+	}
+	return s
+}
+
+// entry returns the line that describes the leftover in the list written by
+// the cleanup command.
+func (l branchLeftover) entry() string {
+	what := "image in the registry"
+	if l.endpoint != "" {
+		what = "checktype in " + l.endpoint
+	}
+	return fmt.Sprintf("%s (check %s, branch %s): %s", l.name, l.check, l.slug, what)
+}
+
+// cleanupBranches finds the images and the checktypes, in the persistence
+// services of the dev envs, of the checks under root built from branches
+// that don't exist anymore in the remote. They are written to the output
+// file or to stdout. If the cleanup-delete flag is specified, only the ones
+// found that are in the list of the file of the flag, written by a previous
+// run without it and reviewed, are deleted. It requires a dev name template
+// that contains the branch.
+func cleanupBranches(root string) error {
+	var reviewed []byte
+	if cleanupDelete != "" {
+		var err error
+		if reviewed, err = os.ReadFile(cleanupDelete); err != nil {
+			return err
+		}
+	}
+	leftovers, err := findLeftovers(root)
+	if err != nil {
+		return err
+	}
+	if cleanupDelete != "" {
+		selected, err := reviewedLeftovers(leftovers, reviewed)
+		if err != nil {
+			return fmt.Errorf("invalid list of leftovers %s: %w", cleanupDelete, err)
+		}
+		for _, l := range selected {
+			logger.Info("Deleting leftover of a deleted branch", "leftover", l.entry())
+		}
+		for _, l := range selected {
+			if err := deleteLeftover(l); err != nil {
+				return err
+			}
+			l.deleted = true
+		}
+	}
+	return writeOutput(func(w io.Writer) error {
+		return writeLeftovers(w, leftovers)
+	})
+}
+
+// findLeftovers returns the images and the checktypes, in the persistence
+// services of the dev envs, of the checks under root built from branches
+// that don't exist anymore in the remote.
+func findLeftovers(root string) ([]branchLeftover, error) {
+	dirs, err := lint.CheckDirs(root)
+	if err != nil {
+		return nil, err
+	}
+	var checks []string
+	for _, dir := range dirs {
+		checks = append(checks, filepath.Base(dir))
+	}
+	parser, err := naming.NewParser(config.Cfg.DevNameTemplate, checks)
+	if err != nil {
+		return nil, fmt.Errorf("can not find the branch of the images: %w", err)
+	}
+	live, err := util.ListRemoteBranches(remote, "")
+	if err != nil {
+		return nil, err
+	}
+	past, err := util.ListPastBranches(remote, "")
+	if err != nil {
+		return nil, err
+	}
+	leftover := leftoverMatcher(parser, live, past)
+
+	var leftovers []branchLeftover
+	repos, err := util.FetchRepositories()
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		name, ok := strings.CutPrefix(repo, config.Cfg.VulcanChecksRepo+"/")
+		if !ok {
+			continue
+		}
+		if l, ok := leftover(name); ok {
+			leftovers = append(leftovers, l)
+		}
+	}
+	for _, env := range uniqueEnvs(config.Cfg.PrimaryDevBranchEnvs, config.Cfg.SecondaryDevBranchEnvs) {
+		checktypes, err := persistence.NewClient(env).ListChecktypes()
+		if err != nil {
+			return nil, fmt.Errorf("error listing the checktypes of %s: %w", env, err)
+		}
+		for _, ct := range checktypes {
+			if l, ok := leftover(ct.Name); ok {
+				l.endpoint, l.id = env, ct.ID
+				leftovers = append(leftovers, l)
+			}
+		}
+	}
+	return leftovers, nil
+}
+
+// leftoverMatcher returns a function that returns the leftover of a name
+// generated by the dev name template for a branch that existed in the past
+// but doesn't exist anymore. The names whose slug is not the one of a past
+// branch are not leftovers, as they can be the names of the production
+// images of removed checks, like vulcan-tls-old, that are parsed as the
+// branch old of vulcan-tls.
+func leftoverMatcher(parser *naming.Parser, live, past []string) func(name string) (branchLeftover, bool) {
+	slugs := func(branches []string) map[string]bool {
+		res := make(map[string]bool)
+		for _, b := range branches {
+			res[naming.Slug(b)] = true
+		}
+		return res
+	}
+	liveSlugs, pastSlugs := slugs(live), slugs(past)
+	return func(name string) (branchLeftover, bool) {
+		check, slug, ok := parser.Parse(name)
+		if !ok || liveSlugs[slug] {
+			return branchLeftover{}, false
+		}
+		if !pastSlugs[slug] {
+			logger.Debug("Name of an unknown branch not cleaned up", "name", name, "branch", slug)
+			return branchLeftover{}, false
+		}
+		return branchLeftover{name: name, check: check, slug: slug}, true
+	}
+}
+
+// reviewedLeftovers returns the leftovers found that are in the reviewed
+// list, with the format written by writeLeftovers. The ones of the list that
+// were not found are ignored. It returns an error if the list is empty or
+// none of its leftovers was found, so nothing is deleted without review.
+func reviewedLeftovers(leftovers []branchLeftover, reviewed []byte) ([]*branchLeftover, error) {
+	entries := make(map[string]bool)
+	for _, line := range strings.Split(string(reviewed), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries[strings.TrimSuffix(line, ", deleted")] = true
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("the list is empty")
+	}
+	var selected []*branchLeftover
+	for i := range leftovers {
+		if entries[leftovers[i].entry()] {
+			selected = append(selected, &leftovers[i])
+			delete(entries, leftovers[i].entry())
+		}
+	}
+	for e := range entries {
+		logger.Warn("Leftover of the list not found", "leftover", e)
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("none of the leftovers of the list was found")
+	}
+	return selected, nil
+}
+
+// deleteLeftover deletes an image from the registry or a checktype from its
+// persistence service.
+func deleteLeftover(l *branchLeftover) error {
+	if l.endpoint == "" {
+		if err := util.DeleteImage(l.name); err != nil {
+			return fmt.Errorf("error deleting the image %s: %w", l.name, err)
+		}
+		return nil
+	}
+	if err := persistence.NewClient(l.endpoint).DeleteChecktype(l.id); err != nil {
+		return fmt.Errorf("error deleting the checktype %s of %s: %w", l.name, l.endpoint, err)
+	}
+	return nil
+}
+
+func writeLeftovers(w io.Writer, leftovers []branchLeftover) error {
+	var b strings.Builder
+	for _, l := range leftovers {
+		fmt.Fprintln(&b, l)
+	}
+	if len(leftovers) == 0 {
+		b.WriteString("No images or checktypes of deleted branches found.\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/adevinta/vulcan-checks-bsys/naming"
+)
+
+func Test_writeLeftovers(t *testing.T) {
+	leftovers := []branchLeftover{
+		{name: "vulcan-tls-feature-x", check: "vulcan-tls", slug: "feature-x", deleted: true},
+		{name: "vulcan-tls-feature-x", check: "vulcan-tls", slug: "feature-x", endpoint: "https://dev.example.com"},
+	}
+	var b strings.Builder
+	if err := writeLeftovers(&b, leftovers); err != nil {
+		t.Fatal(err)
+	}
+	want := `vulcan-tls-feature-x (check vulcan-tls, branch feature-x): image in the registry, deleted
+vulcan-tls-feature-x (check vulcan-tls, branch feature-x): checktype in https://dev.example.com
+`
+	if b.String() != want {
+		t.Errorf("writeLeftovers() got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func Test_reviewedLeftovers(t *testing.T) {
+	image := branchLeftover{name: "vulcan-tls-feature-x", check: "vulcan-tls", slug: "feature-x"}
+	checktype := branchLeftover{name: "vulcan-tls-feature-x", check: "vulcan-tls", slug: "feature-x",
+		endpoint: "https://dev.example.com", id: "1"}
+	other := branchLeftover{name: "vulcan-nessus-feature-y", check: "vulcan-nessus", slug: "feature-y"}
+	tests := []struct {
+		name     string
+		reviewed string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "Reviewed",
+			reviewed: "vulcan-tls-feature-x (check vulcan-tls, branch feature-x): image in the registry\n" +
+				"vulcan-tls-feature-x (check vulcan-tls, branch feature-x): checktype in https://dev.example.com\n",
+			want: []string{image.entry(), checktype.entry()},
+		},
+		{
+			name: "NotFoundIgnored",
+			reviewed: "vulcan-tls-feature-x (check vulcan-tls, branch feature-x): image in the registry, deleted\n\n" +
+				"vulcan-tls-feature-z (check vulcan-tls, branch feature-z): image in the registry\n",
+			want: []string{image.entry()},
+		},
+		{
+			name:     "Empty",
+			reviewed: "\n",
+			wantErr:  true,
+		},
+		{
+			name:     "NoneFound",
+			reviewed: "vulcan-tls-feature-z (check vulcan-tls, branch feature-z): image in the registry\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := reviewedLeftovers([]branchLeftover{image, checktype, other}, []byte(tt.reviewed))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reviewedLeftovers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, l := range selected {
+				got = append(got, l.entry())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("reviewedLeftovers() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func Test_leftoverMatcher(t *testing.T) {
+	parser, err := naming.NewParser("{check}-{branch-slug}", []string{"vulcan-tls", "vulcan-nessus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leftover := leftoverMatcher(parser, []string{"master", "feature/live"}, []string{"feature/live", "feature/x"})
+	tests := []struct {
+		name  string
+		image string
+		want  branchLeftover
+		found bool
+	}{
+		{
+			name:  "DeletedBranch",
+			image: "vulcan-tls-feature-x",
+			want:  branchLeftover{name: "vulcan-tls-feature-x", check: "vulcan-tls", slug: "feature-x"},
+			found: true,
+		},
+		{name: "LiveBranch", image: "vulcan-nessus-feature-live"},
+		{name: "ProductionImage", image: "vulcan-tls"},
+		// The production image of a removed check looks like the image of
+		// the branch old of vulcan-tls, but that branch never existed.
+		{name: "RemovedCheck", image: "vulcan-tls-old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := leftover(tt.image)
+			if found != tt.found {
+				t.Fatalf("leftover() found = %v, want %v", found, tt.found)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(branchLeftover{})); diff != "" {
+				t.Errorf("leftover() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// command is a mode of the build system, selected by the flag with its name,
+// whose value is the argument of the command.
+type command struct {
+	name string
+	arg  *string
+	run  func(arg string) error
+	// options are the names of the flags that only apply to the command.
+	options []string
+	// build is true if the command builds or promotes images, so it
+	// depends on the branch the build runs for.
+	build bool
+}
+
+// commands returns the commands of the build system. Only one of them can be
+// specified in each run.
+func commands() []command {
+	return []command{
+		{name: "f", arg: &force, run: func(p string) error {
+			_, err := forceBuild(p)
+			return err
+		}, build: true},
+		{name: "r", arg: &run, run: func(p string) error {
+			if output == "" && reportFormat == "" {
+				return forceRun(p)
+			}
+			return forceRunReport(p, output)
+		}, options: []string{"report-format"}, build: true},
+		{name: "p", arg: &publish, run: publishChecks},
+		{name: "i", arg: &imagesFile, run: buildImages, options: []string{"summary"}, build: true},
+		{name: "verify", arg: &verify, run: verifyImageRef},
+		{name: "lint", arg: &lintDir, run: lintChecks, options: []string{"lint-format"}},
+		{name: "test", arg: &testDir, run: runTestCases, build: true},
+		{name: "compare", arg: &compare, run: compareCheck, options: []string{"compare-format"}, build: true},
+		{name: "catalog", arg: &catalogDir, run: generateCatalog, options: []string{"catalog-format", "catalog-offline"}},
+		{name: "manifest-fmt", arg: &manifestFmt, run: formatManifests},
+		{name: "manifest-export", arg: &manifestExport, run: exportManifest, options: []string{"export-format"}},
+		{name: "manifest-diff", arg: &manifestDiff, run: diffManifest, options: []string{"diff-against"}},
+		{name: "drift", arg: &driftDir, run: detectDrift, options: []string{"drift-format", "drift-reconcile"}},
+		{name: "cleanup", arg: &cleanupDir, run: cleanupBranches, options: []string{"cleanup-delete", "cleanup-remote"}},
+		{name: "promote", arg: &promote, run: promoteImage, options: []string{"promote-as"}, build: true},
+		{name: "rollback", arg: &rollback, run: rollbackCheck, options: []string{"rollback-to"}},
+	}
+}
+
+// errNoCommand is returned by selectCommand when no command is specified.
+var errNoCommand = errors.New("no command specified")
+
+// selectCommand returns the only command of cmds whose flag is specified. It
+// returns an error if there are more, or if any of the flags in set, the ones
+// specified, is an option of another command.
+func selectCommand(cmds []command, set []string) (command, error) {
+	var (
+		selected []command
+		names    []string
+	)
+	owners := map[string]string{}
+	for _, c := range cmds {
+		if *c.arg != "" {
+			selected = append(selected, c)
+			names = append(names, "-"+c.name)
+		}
+		for _, o := range c.options {
+			owners[o] = c.name
+		}
+	}
+	switch len(selected) {
+	case 0:
+		return command{}, errNoCommand
+	case 1:
+	default:
+		return command{}, fmt.Errorf("the flags %s can not be used together", strings.Join(names, ", "))
+	}
+	c := selected[0]
+	for _, f := range set {
+		if owner, ok := owners[f]; ok && owner != c.name {
+			return command{}, fmt.Errorf("the -%s flag can only be used with the -%s flag", f, owner)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import "testing"
+
+func Test_selectCommand(t *testing.T) {
+	var lint, catalog, drift string
+	cmds := []command{
+		{name: "lint", arg: &lint, options: []string{"lint-format"}},
+		{name: "catalog", arg: &catalog, options: []string{"catalog-format", "catalog-offline"}},
+		{name: "drift", arg: &drift, options: []string{"drift-format"}},
+	}
+	tests := []struct {
+		name    string
+		lint    string
+		catalog string
+		set     []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Command",
+			lint: "cmd",
+			set:  []string{"lint", "lint-format", "o"},
+			want: "lint",
+		},
+		{
+			name:    "OtherCommandOption",
+			catalog: "cmd",
+			set:     []string{"catalog", "drift-format"},
+			wantErr: true,
+		},
+		{
+			name:    "TwoCommands",
+			lint:    "cmd",
+			catalog: "cmd",
+			set:     []string{"lint", "catalog"},
+			wantErr: true,
+		},
+		{
+			name:    "NoCommand",
+			set:     []string{"catalog-offline"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lint, catalog = tt.lint, tt.catalog
+			got, err := selectCommand(cmds, tt.set)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.name != tt.want {
+				t.Errorf("selectCommand() = %v, want %v", got.name, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/adevinta/vulcan-checks-bsys/checkreport"
+	"github.com/adevinta/vulcan-checks-bsys/checktest"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// compareCheck runs the production image of the check in imagePath and a new
+// build of it against the targets of the test cases of the check, and writes
+// the differences between the reports generated, in the format specified in
+// the compare-format flag, to the output file or to stdout.
+func compareCheck(imagePath string) error {
+	cases, err := checktest.Read(path.Join(imagePath, checktest.File))
+	if err != nil {
+		return err
+	}
+	m, err := manifest.Read(path.Join(imagePath, manifestFileName))
+	if err != nil {
+		return err
+	}
+	local, err := loadLocalConfig(imagePath)
+	if err != nil {
+		return err
+	}
+
+	name := path.Base(imagePath)
+	info, err := util.FetchImagesInfo(name)
+	if err != nil {
+		return err
+	}
+	tag, found := latestTag(info.Tags)
+	if !found {
+		return fmt.Errorf("no production image found for the check %s", name)
+	}
+	prodImage := buildImageName(name, tag)
+	logger.Info("Pulling production image", "check", name, "image", prodImage)
+	if err = util.PullImage(prodImage, logger); err != nil {
+		return err
+	}
+	newImage, err := forceBuild(imagePath)
+	if err != nil {
+		return err
+	}
+
+	var diffs []checkreport.Diff
+	for _, tc := range cases {
+		logger.Info("Comparing the reports", "check", name, "old_image", prodImage, "new_image", newImage, "target", tc.Target)
+		c, timeout, err := testCaseConfig(tc, m, local)
+		if err != nil {
+			return fmt.Errorf("test case %s: %w", tc.Name, err)
+		}
+		oldReport, err := runCheckReport(prodImage, c, timeout)
+		if err != nil {
+			return fmt.Errorf("error running %s: %w", prodImage, err)
+		}
+		c, timeout, err = testCaseConfig(tc, m, local)
+		if err != nil {
+			return fmt.Errorf("test case %s: %w", tc.Name, err)
+		}
+		newReport, err := runCheckReport(newImage, c, timeout)
+		if err != nil {
+			return fmt.Errorf("error running %s: %w", newImage, err)
+		}
+		d := checkreport.Compare(oldReport, newReport)
+		d.Target = tc.Target
+		d.Options = tc.Options
+		d.OldImage = prodImage
+		d.NewImage = newImage
+		if !d.Empty() {
+			logger.Info("Reports differ", "check", name, "target", tc.Target, "added", len(d.Added), "removed", len(d.Removed),
+				"changed", len(d.Changed))
+		}
+		diffs = append(diffs, d)
+	}
+
+	f := compareFormat
+	if f == "" {
+		f = checkreport.FormatMarkdown
+	}
+	return writeOutput(func(w io.Writer) error {
+		return writeDiffs(w, f, diffs)
+	})
+}
+
+func writeDiffs(w io.Writer, format string, diffs []checkreport.Diff) error {
+	switch format {
+	case checkreport.FormatMarkdown:
+		return checkreport.WriteMarkdownDiffs(w, diffs)
+	case checkreport.FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diffs)
+	}
+	return fmt.Errorf("invalid format %q", format)
+}
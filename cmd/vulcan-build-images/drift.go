@@ -0,0 +1,163 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/drift"
+	"github.com/adevinta/vulcan-checks-bsys/lint"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/persistence"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// detectDrift compares, for each check under root, the manifest in the repo
+// with the one of its latest image in the registry, and the checktype that
+// publishing that image would create with the checktype published to each of
+// the persistence services of the config. The results are written, in the
+// format specified in the drift-format flag, text by default, to the output
+// file or to stdout. If the drift-reconcile flag is specified the checktypes
+// that differ are published again. It returns an error if any drift remains.
+func detectDrift(root string) error {
+	f := driftFormat
+	if f == "" {
+		f = "text"
+	}
+	if f != "text" && f != "json" {
+		return fmt.Errorf("invalid drift format %q, valid formats are: text, json", f)
+	}
+	dirs, err := lint.CheckDirs(root)
+	if err != nil {
+		return err
+	}
+	envs := persistenceEnvs()
+	published := make(map[string][]persistence.PublishChecktypeResult)
+	for _, env := range envs {
+		checktypes, err := persistence.NewClient(env).ListChecktypes()
+		if err != nil {
+			return fmt.Errorf("error listing the checktypes of %s: %w", env, err)
+		}
+		published[env] = checktypes
+	}
+
+	var checks []drift.Check
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		local, err := manifest.Read(filepath.Join(dir, manifestFileName))
+		if err != nil {
+			logger.Warn("Skipping check, invalid manifest", "check", name, "error", err)
+			continue
+		}
+		c, err := checkDrift(name, local, envs, published)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, c)
+	}
+
+	err = writeOutput(func(w io.Writer) error {
+		if f == "json" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(checks)
+		}
+		return drift.WriteText(w, checks)
+	})
+	if err != nil {
+		return err
+	}
+	drifted := 0
+	for _, c := range checks {
+		if c.Drifted() {
+			drifted++
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("drift found in %d checks", drifted)
+	}
+	return nil
+}
+
+func checkDrift(name string, local manifest.Data, envs []string, published map[string][]persistence.PublishChecktypeResult) (drift.Check, error) {
+	c := drift.Check{Name: name}
+	info, err := util.FetchImagesInfo(name)
+	if err != nil {
+		return c, err
+	}
+	tag, found := latestTag(info.Tags)
+	if !found {
+		return c, nil
+	}
+	tagInfo, err := util.FetchImageTagInfo(name, tag)
+	if err != nil {
+		return c, err
+	}
+	c.Image = buildImageName(name, tag)
+	c.Manifest = manifest.Diff(tagInfo.Manifest, local)
+
+	image := c.Image
+	if config.Cfg.PinImageDigest {
+		digest, err := util.FetchImageDigest(name, tag)
+		if err != nil {
+			return c, err
+		}
+		image = util.PinnedImageName(image, digest)
+	}
+	want, err := newPersistenceChecktype(name, image, tagInfo.Manifest)
+	if err != nil {
+		return c, err
+	}
+	for _, env := range envs {
+		e := drift.Env{Endpoint: env}
+		got, found := drift.FindChecktype(published[env], name)
+		if found {
+			e.Changes = drift.CompareChecktype(got, want)
+		} else {
+			e.Missing = true
+		}
+		if reconcile && e.Drifted() {
+			if _, err := persistence.NewClient(env).PublishChecktype(want); err != nil {
+				return c, fmt.Errorf("error reconciling the checktype %s in %s: %w", name, env, err)
+			}
+			logger.Info("Checktype reconciled", "check", name, "env", env)
+			e.Reconciled = true
+		}
+		c.Envs = append(c.Envs, e)
+	}
+	return c, nil
+}
+
+// persistenceEnvs returns the endpoints of the persistence services of all
+// the envs defined in the config.
+func persistenceEnvs() []string {
+	return uniqueEnvs(
+		config.Cfg.PrimaryMasterBranchEnvs,
+		config.Cfg.SecondaryMasterBranchEnvs,
+		config.Cfg.PrimaryDevBranchEnvs,
+		config.Cfg.SecondaryDevBranchEnvs,
+	)
+}
+
+// uniqueEnvs returns the non empty envs of the given groups without
+// duplicates.
+func uniqueEnvs(groups ...[]string) []string {
+	var envs []string
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		for _, env := range group {
+			if env == "" || seen[env] {
+				continue
+			}
+			seen[env] = true
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
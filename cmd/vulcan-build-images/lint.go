@@ -0,0 +1,100 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/lint"
+)
+
+const (
+	lintFormatText  = "text"
+	lintFormatJSON  = "json"
+	lintFormatSARIF = "sarif"
+)
+
+// lintChecks lints the check dirs under root and writes the results, in the
+// format specified in the lint-format flag, to the output file or to stdout.
+// It returns an error if any finding has severity error.
+func lintChecks(root string) error {
+	if err := validateLintFormat(lintFormat); err != nil {
+		return err
+	}
+	cfg, err := lintConfig()
+	if err != nil {
+		return err
+	}
+	findings, err := lint.Linter{Config: cfg}.LintRepo(root)
+	if err != nil {
+		return err
+	}
+
+	err = writeOutput(func(w io.Writer) error {
+		return writeLintFindings(w, lintFormat, findings)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Lint finished", "dir", root, "findings", len(findings))
+	if lint.HasErrors(findings) {
+		return errors.New("lint failed, there are findings with severity error")
+	}
+	return nil
+}
+
+// lintConfig returns the config of the linter defined in the config file. It
+// returns an error if a lint rule of the config doesn't exist or its severity
+// is not valid.
+func lintConfig() (lint.Config, error) {
+	cfg := lint.Config{
+		Severities: make(map[string]lint.Severity),
+		QueueNames: config.Cfg.LintQueueNames,
+	}
+	rules := make(map[string]bool)
+	for _, r := range lint.Rules() {
+		rules[r.ID] = true
+	}
+	for rule, s := range config.Cfg.LintRules {
+		if !rules[rule] {
+			return lint.Config{}, fmt.Errorf("unknown lint rule %s", rule)
+		}
+		sev, err := lint.ParseSeverity(s)
+		if err != nil {
+			return lint.Config{}, fmt.Errorf("invalid severity of the lint rule %s: %w", rule, err)
+		}
+		cfg.Severities[rule] = sev
+	}
+	return cfg, nil
+}
+
+func validateLintFormat(format string) error {
+	switch format {
+	case lintFormatText, lintFormatJSON, lintFormatSARIF:
+		return nil
+	}
+	return fmt.Errorf("invalid lint format %q", format)
+}
+
+func writeLintFindings(w io.Writer, format string, findings []lint.Finding) error {
+	switch format {
+	case lintFormatText:
+		return lint.WriteText(w, findings)
+	case lintFormatJSON:
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	case lintFormatSARIF:
+		return lint.SARIF(findings).Write(w)
+	}
+	return fmt.Errorf("invalid lint format %q", format)
+}
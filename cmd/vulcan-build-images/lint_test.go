@@ -0,0 +1,108 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/lint"
+	"github.com/adevinta/vulcan-checks-bsys/sarif"
+)
+
+func Test_lintChecksSARIFOutput(t *testing.T) {
+	defer func(c config.Config, l *slog.Logger, w io.Writer, o, f string) {
+		config.Cfg, logger, outputWriter, output, lintFormat = c, l, w, o, f
+	}(config.Cfg, logger, outputWriter, output, lintFormat)
+	config.Cfg = config.Config{}
+	var stdout, logs bytes.Buffer
+	logger = slog.New(slog.NewTextHandler(&logs, nil))
+	outputWriter = &stdout
+	output = ""
+	lintFormat = lintFormatSARIF
+
+	root := filepath.Join("..", "..", "lint", "testdata", "checks")
+	if err := lintChecks(root); err == nil {
+		t.Fatal("lintChecks() got no error, want an error for the findings with severity error")
+	}
+	var got sarif.Log
+	dec := json.NewDecoder(&stdout)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("stdout is not a SARIF log: %v", err)
+	}
+	if dec.More() {
+		t.Fatal("stdout contains more than the SARIF log")
+	}
+	if got.Version != sarif.Version || len(got.Runs) != 1 || len(got.Runs[0].Results) == 0 {
+		t.Errorf("got SARIF log %+v, want one run with results", got)
+	}
+	if !strings.Contains(logs.String(), "Lint finished") {
+		t.Errorf("got logs %q, want the lint finished record", logs.String())
+	}
+}
+
+func Test_lintChecksInvalidFormat(t *testing.T) {
+	defer func(c config.Config, o, f string) {
+		config.Cfg, output, lintFormat = c, o, f
+	}(config.Cfg, output, lintFormat)
+	config.Cfg = config.Config{}
+	output = filepath.Join(t.TempDir(), "findings.txt")
+	lintFormat = "xml"
+
+	root := filepath.Join("..", "..", "lint", "testdata", "checks")
+	if err := lintChecks(root); err == nil {
+		t.Fatal("lintChecks() got no error, want an error for the invalid format")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("lintChecks() created the output file, stat error = %v", err)
+	}
+}
+
+func Test_lintConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   map[string]string
+		want    map[string]lint.Severity
+		wantErr bool
+	}{
+		{
+			name:  "Valid",
+			rules: map[string]string{"manifest-missing": "warning"},
+			want:  map[string]lint.Severity{"manifest-missing": lint.SeverityWarning},
+		},
+		{
+			name:    "UnknownRule",
+			rules:   map[string]string{"manifest-mising": "warning"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidSeverity",
+			rules:   map[string]string{"manifest-missing": "fatal"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+			config.Cfg = config.Config{LintRules: tt.rules}
+			got, err := lintConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lintConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got.Severities, tt.want) {
+				t.Errorf("lintConfig() severities = %v, want %v", got.Severities, tt.want)
+			}
+		})
+	}
+}
@@ -5,11 +5,13 @@ Copyright 2019 Adevinta
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -20,20 +22,25 @@ import (
 	"time"
 
 	sdkconfig "github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-checks-bsys/checkreport"
+	"github.com/adevinta/vulcan-checks-bsys/ci"
 	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+	"github.com/adevinta/vulcan-checks-bsys/logging"
 	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/naming"
 	"github.com/adevinta/vulcan-checks-bsys/persistence"
 	"github.com/adevinta/vulcan-checks-bsys/queue"
+	"github.com/adevinta/vulcan-checks-bsys/sbom"
 	"github.com/adevinta/vulcan-checks-bsys/util"
+	"github.com/adevinta/vulcan-checks-bsys/vulngate"
+	report "github.com/adevinta/vulcan-report"
 	"github.com/google/uuid"
 )
 
 const (
-	usage             string = "usage: \n"
-	buildBranchEnvVar string = "TRAVIS_BRANCH"
-	prodBranchName    string = "master"
-	imgNameDevSuffix  string = "-experimental"
-	manifestFileName  string = "manifest.toml"
+	usage            string = "usage: \n"
+	manifestFileName string = "manifest.toml"
 
 	forceFlagUsage string = `Path to a directory of the repo that contains a check.
 Builds check docker image locally, without publishing it to the docker repository.
@@ -53,82 +60,278 @@ DOCKER_REGISTRY="docker-registry.example.com" vulcan-build-images -p https://vul
 	runFlagUsage = `Same as force flag but also runs resulting docker image
 with -t flag and sets env vars with values defined in the corresponding local.toml.`
 	outputFlagUsage = `Specifies the path of a file to store the report as json generated by the execution of a check when
-	also the r flag is specified.`
-	configFlagUsage = `Path to the configuration file, if it's not provided it defaults to ~/.vulcan-checks-bsys.toml`
+	also the r flag is specified, or the results of the lint, compare, catalog, manifest-export, manifest-diff, drift,
+	cleanup and rollback flags.`
+	configFlagUsage     = `Path to the configuration file, if it's not provided it defaults to ~/.vulcan-checks-bsys.toml`
+	multiStageFlagUsage = `Builds the check binaries inside a multi-stage docker build, using the go
+builder image defined in the config, instead of running go build in the host.`
+	summaryFlagUsage = `Path of a file to write, as json, the summary of the images built when the i flag is specified.`
+	verifyFlagUsage  = `Image, in the form name:tag, whose signature and provenance, pushed to the registry next to it,
+must be verified using the public key defined in the config. Example: vulcan-build-images -verify vulcan-nessus:3`
+	skipTestsFlagUsage = `Doesn't run the go tests of the checks before building their images.`
+	lintFlagUsage      = `Path to a directory of a checks repo. Lints all the check directories found under it and fails
+if any finding has severity error. The results are written to the file specified in the o flag, or to stdout.`
+	lintFormatFlagUsage = `Format of the results of the lint flag: text, json or sarif.`
+	testFlagUsage       = `Path to a directory of the repo that contains a check. Builds the check docker image locally and
+runs it for each test case defined in its testcases.toml file, comparing the reports generated with the expected ones.`
+	compareFlagUsage = `Path to a directory of the repo that contains a check. Runs the latest production image of the check
+and a local build of it against the targets of its testcases.toml file and writes the differences between their reports
+to the file specified in the o flag, or to stdout.`
+	reportFormatFlagUsage = `Format of the report generated with the r flag: json, the default, text, markdown, html,
+sarif or junit. If the o flag is not specified the report is written to stdout.`
+	compareFormatFlagUsage = `Format of the differences written by the compare flag: markdown, the default, or json.`
+	catalogFlagUsage       = `Path to a directory of a checks repo. Generates a catalog of all the checks found under it from their
+manifests and the latest images of the checks in the registry, and writes it to the file specified in the o flag, or to
+stdout.`
+	catalogFormatFlagUsage  = `Format of the catalog generated with the catalog flag: markdown, the default, html or json.`
+	catalogOfflineFlagUsage = `Doesn't query the registry when generating the catalog with the catalog flag.`
+	manifestFmtFlagUsage    = `Path to a manifest file, or to a directory of a checks repo. Rewrites the manifest, or all the
+manifests of the checks under the directory, in canonical form. The manifests with comments are not rewritten.`
+	manifestExportFlagUsage = `Path to a manifest file, in toml, json or yaml, or to a directory that contains a check. Writes the
+manifest in the format specified in the export-format flag to the file specified in the o flag, or to stdout.`
+	exportFormatFlagUsage = `Format of the manifest written by the manifest-export flag: json, the default, yaml or toml.`
+	manifestDiffFlagUsage = `Path to a manifest file or to a directory that contains a check. Compares the manifest with the
+one specified in the diff-against flag and writes the differences to the file specified in the o flag, or to stdout.`
+	diffAgainstFlagUsage = `Manifest file, or image of the registry in the form name:tag, compared by the manifest-diff flag. If
+it's not specified the manifest is compared with the one of the latest image of the check in the registry.`
+	driftFlagUsage = `Path to a directory of a checks repo. Compares the manifests of the checks with the ones of their latest
+images in the registry, and the checktypes of those images with the ones published to the persistence services of all
+the envs of the config. The differences are written to the file specified in the o flag, or to stdout.`
+	driftFormatFlagUsage    = `Format of the differences found by the drift flag: text, the default, or json.`
+	driftReconcileFlagUsage = `Publishes again, to the persistence services, the checktypes found by the drift flag that
+differ from the ones of the latest images of the checks.`
+	cleanupFlagUsage = `Path to a directory of a checks repo. Finds the images and the checktypes, in the persistence
+services of the dev envs, of the checks under it built from branches that don't exist anymore in the remote, according
+to the dev_name_template of the config. They are written to the file specified in the o flag, or to stdout.`
+	cleanupDeleteFlagUsage = `Path to the list of images and checktypes written by a previous run of the cleanup flag,
+after reviewing it. Deletes the ones of the list that the cleanup flag finds again.`
+	cleanupRemoteFlagUsage = `Git remote whose branches are considered alive by the cleanup flag.`
+	promoteFlagUsage       = `Image of a check built from a dev branch, in the form name:tag or name@sha256:digest. Republishes
+it, without rebuilding it, as the next version of the production image of the check and publishes its checktype to the
+master branch envs. Example: vulcan-build-images -promote vulcan-nessus-experimental:12`
+	promoteAsFlagUsage = `Check promoted by the promote flag. If it's not specified it's taken from the name of the image using
+the dev_name_template of the config.`
+	rollbackFlagUsage = `Name of the image of a check. Lists the versions of the image in the registry, with the commit and
+the sdk version they were built from, to the file specified in the o flag, or to stdout.`
+	rollbackToFlagUsage = `Version of the image specified in the rollback flag that is tagged again, in the registry, as the
+next version of the image, and whose checktype is published to the envs the check is published to when built. Example:
+vulcan-build-images -rollback vulcan-nessus -rollback-to 11`
+	logFormatFlagUsage = `Format of the logs: text, the default, or json.`
+	logLevelFlagUsage  = `Minimum level of the logs: debug, info, the default, warn or error. The output of docker is logged
+with debug level.`
+	quietFlagUsage   = `Only logs the warnings and the errors.`
+	sbomDirFlagUsage = `Directory where the CycloneDX SBOMs of the images built are written. It overrides the sbom_dir
+defined in the config.`
 )
 
 var (
-	logger      *log.Logger
-	logWriter   = os.Stdout
-	buildBranch = os.Getenv(buildBranchEnvVar)
-	imagesFile  string
-	force       string
-	publish     string
-	run         string
-	output      string
-	cfg         string
+	logger    *slog.Logger
+	logWriter = os.Stderr
+	// outputWriter is where the commands write their output when the o flag
+	// is not specified.
+	outputWriter  io.Writer = os.Stdout
+	buildEnv      ci.Env
+	imagesFile    string
+	force         string
+	publish       string
+	run           string
+	reportFormat  string
+	output        string
+	cfg           string
+	multiStage    bool
+	summary       string
+	verify        string
+	sbomDirFlag   string
+	skipTests     bool
+	lintDir       string
+	lintFormat    string
+	testDir       string
+	compare       string
+	compareFormat string
+	catalogDir    string
+	catalogFormat string
+	offline       bool
+
+	manifestFmt    string
+	manifestExport string
+	exportFormat   string
+	manifestDiff   string
+	against        string
+	driftDir       string
+	driftFormat    string
+	reconcile      bool
+
+	cleanupDir    string
+	cleanupDelete string
+	remote        string
+
+	promote   string
+	promoteAs string
+
+	rollback   string
+	rollbackTo string
+
+	logFormat string
+	logLevel  string
+	quiet     bool
 )
 
 func init() {
-	name := os.Args[0]
-	logger = log.New(
-		logWriter,
-		fmt.Sprintf("%s: ", name),
-		log.Lshortfile,
-	)
+	logger = slog.New(slog.NewTextHandler(logWriter, nil))
 }
 func main() {
-	mustParseFlags()
-	var err error
-	if force != "" {
-		_, err = forceBuild(force)
-	} else if run != "" && output == "" {
-		err = forceRun(run)
-	} else if run != "" && output != "" {
-		err = forceRunReport(run, output)
-	} else if publish != "" {
-		err = publishChecks(publish)
-	} else if imagesFile != "" {
-		err = buildImages(imagesFile)
-	} else {
-		err = errors.New("You must specify at least one flag")
-	}
+	c := mustParseFlags()
+	err := c.run(*c.arg)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal(logger, "Error running the build system", err)
 	}
 	err = config.LoadFrom(cfg)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal(logger, "Error loading the config", err)
 	}
 }
 
-func mustParseFlags() {
+func mustParseFlags() command {
 	// Allow setting the flag params in tests
 	if !flag.Parsed() {
 		flag.StringVar(&imagesFile, "i", "", imageFlagUsage)
 		flag.StringVar(&force, "f", "", forceFlagUsage)
 		flag.StringVar(&publish, "p", "", publishFlagUsage)
 		flag.StringVar(&run, "r", "", runFlagUsage)
+		flag.StringVar(&reportFormat, "report-format", "", reportFormatFlagUsage)
 		flag.StringVar(&output, "o", "", outputFlagUsage)
 		flag.StringVar(&cfg, "c", "", configFlagUsage)
+		flag.BoolVar(&multiStage, "multi-stage", false, multiStageFlagUsage)
+		flag.StringVar(&summary, "summary", "", summaryFlagUsage)
+		flag.StringVar(&verify, "verify", "", verifyFlagUsage)
+		flag.StringVar(&sbomDirFlag, "sbom-dir", "", sbomDirFlagUsage)
+		flag.BoolVar(&skipTests, "skip-tests", false, skipTestsFlagUsage)
+		flag.StringVar(&lintDir, "lint", "", lintFlagUsage)
+		flag.StringVar(&lintFormat, "lint-format", lintFormatText, lintFormatFlagUsage)
+		flag.StringVar(&testDir, "test", "", testFlagUsage)
+		flag.StringVar(&compare, "compare", "", compareFlagUsage)
+		flag.StringVar(&compareFormat, "compare-format", "", compareFormatFlagUsage)
+		flag.StringVar(&catalogDir, "catalog", "", catalogFlagUsage)
+		flag.StringVar(&catalogFormat, "catalog-format", "", catalogFormatFlagUsage)
+		flag.BoolVar(&offline, "catalog-offline", false, catalogOfflineFlagUsage)
+		flag.StringVar(&manifestFmt, "manifest-fmt", "", manifestFmtFlagUsage)
+		flag.StringVar(&manifestExport, "manifest-export", "", manifestExportFlagUsage)
+		flag.StringVar(&exportFormat, "export-format", "", exportFormatFlagUsage)
+		flag.StringVar(&manifestDiff, "manifest-diff", "", manifestDiffFlagUsage)
+		flag.StringVar(&against, "diff-against", "", diffAgainstFlagUsage)
+		flag.StringVar(&driftDir, "drift", "", driftFlagUsage)
+		flag.StringVar(&driftFormat, "drift-format", "", driftFormatFlagUsage)
+		flag.BoolVar(&reconcile, "drift-reconcile", false, driftReconcileFlagUsage)
+		flag.StringVar(&cleanupDir, "cleanup", "", cleanupFlagUsage)
+		flag.StringVar(&cleanupDelete, "cleanup-delete", "", cleanupDeleteFlagUsage)
+		flag.StringVar(&remote, "cleanup-remote", "origin", cleanupRemoteFlagUsage)
+		flag.StringVar(&promote, "promote", "", promoteFlagUsage)
+		flag.StringVar(&promoteAs, "promote-as", "", promoteAsFlagUsage)
+		flag.StringVar(&rollback, "rollback", "", rollbackFlagUsage)
+		flag.StringVar(&rollbackTo, "rollback-to", "", rollbackToFlagUsage)
+		flag.StringVar(&logFormat, "log-format", logging.FormatText, logFormatFlagUsage)
+		flag.StringVar(&logLevel, "log-level", "info", logLevelFlagUsage)
+		flag.BoolVar(&quiet, "quiet", false, quietFlagUsage)
 		flag.Parse()
 	}
 
-	if imagesFile == "" && force == "" && publish == "" && run == "" {
+	var set []string
+	flag.Visit(func(f *flag.Flag) { set = append(set, f.Name) })
+	c, err := selectCommand(commands(), set)
+	if err != nil {
+		if !errors.Is(err, errNoCommand) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		printHelp()
 		os.Exit(1)
 	}
 
-	if imagesFile != "" && force != "" {
-		printHelp()
-		os.Exit(1)
+	l, err := logging.New(logWriter, logging.Options{Format: logFormat, Level: logLevel, Quiet: quiet})
+	if err != nil {
+		logging.Fatal(logger, "Invalid log flags", err)
 	}
+	logger = l.With("cmd", "vulcan-build-images")
+	slog.SetDefault(logger)
 
-	err := config.LoadFrom(cfg)
+	err = config.LoadFrom(cfg)
 	if err != nil {
-		fmt.Printf("%+v", err)
-		os.Exit(1)
+		logging.Fatal(logger, "Error loading the config", err)
+	}
+	if err = registerAssetTypes(); err != nil {
+		logging.Fatal(logger, "Invalid asset type in the config", err)
+	}
+	if _, err = lintConfig(); err != nil {
+		logging.Fatal(logger, "Invalid lint config", err)
+	}
+	if err = imagetag.Validate(config.Cfg.TagStrategy, config.Cfg.ExtraTags); err != nil {
+		logging.Fatal(logger, "Invalid tag config", err)
+	}
+	if err = imagetag.ValidateCollisionPolicy(config.Cfg.TagCollision); err != nil {
+		logging.Fatal(logger, "Invalid tag collision policy", err)
+	}
+	if tagLeaseTTL, err = parseTagLeaseTTL(config.Cfg.TagLeaseTTL); err != nil {
+		logging.Fatal(logger, "Invalid tag lease TTL", err)
+	}
+	if config.Cfg.DevNameTemplate != "" {
+		if err = naming.ValidateTemplate(config.Cfg.DevNameTemplate); err != nil {
+			logging.Fatal(logger, "Invalid dev name template", err)
+		}
+	}
+	// Only the commands that build or promote images depend on the branch
+	// the build runs for.
+	if c.build {
+		detectBuildEnv()
+	}
+	return c
+}
+
+// detectBuildEnv detects the branch, the commit and the pull request of the
+// build from the env vars of the CI provider.
+func detectBuildEnv() {
+	buildEnv = ci.Detect()
+	logger.Info("Build detected", "provider", buildEnv.Provider, "branch", buildEnv.Branch, "commit", buildEnv.Commit,
+		"pull_request", buildEnv.PullRequest)
+}
+
+// prodBuild returns true if the build is of the production branch and not of
+// a pull request.
+func prodBuild() bool {
+	return buildEnv.IsProduction(config.Cfg.ProductionBranch)
+}
+
+// latestTag returns the latest version tag of the given ones according to
+// the tag strategy of the config.
+func latestTag(tags []string) (string, bool) {
+	return imagetag.Latest(config.Cfg.TagStrategy, tags)
+}
+
+// registerAssetTypes registers the asset types defined in the config so they
+// can be used in the manifests of the checks.
+func registerAssetTypes() error {
+	for _, a := range config.Cfg.AssetTypes {
+		if _, err := manifest.RegisterAssetTypePattern(a.Name, a.Description, a.TargetPattern); err != nil {
+			return fmt.Errorf("invalid asset type in the config: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateTarget returns an error if the target is not a valid asset of the
+// given asset type, according to the asset types registered. Targets without
+// asset type are not validated.
+func validateTarget(target, assetType string) error {
+	if assetType == "" {
+		return nil
+	}
+	a, ok := manifest.LookupAssetType(assetType)
+	if !ok {
+		var names []string
+		for _, info := range manifest.RegisteredAssetTypes() {
+			names = append(names, info.Name)
+		}
+		return fmt.Errorf("unknown asset type %s, the valid ones are: %s", assetType, strings.Join(names, ", "))
 	}
+	return a.ValidateTarget(target)
 }
 
 func printHelp() {
@@ -141,6 +344,91 @@ type checkImageInfo struct {
 	imagePath     string // e.g.: cmd/vulcan-wpscan
 	imageName     string // e.g.: container.example.com/vulcan-checks/vulcan-wpscan-experimental
 	manifest      manifest.Data
+	commit        string
+	contextDigest string // e.g.: sha256:0b8a4c...
+	imageID       string // e.g.: sha256:43ca6f...
+	digest        string // e.g.: sha256:7e2f1a..., digest of the manifest pushed.
+	tag           string
+	extraTags     []string // e.g.: latest, sha-a1b2c3d, moved to the image when pushed.
+	sdkVersion    string
+	buildPlan     string // e.g.: cmd/vulcan-wpscan:3:a1b2c3d, line of the images file.
+	builderImage  string // e.g.: golang:1.22-alpine, only for multi-stage builds.
+	sbom          *sbom.BOM
+	vulns         *vulngate.Result // nil if the vulnerability gate is disabled.
+	startedOn     time.Time
+	finishedOn    time.Time
+}
+
+// publishedImageName returns the image reference used in the checktype of the
+// image, pinned to the digest of the image pushed if configured.
+func (i checkImageInfo) publishedImageName() string {
+	if !config.Cfg.PinImageDigest {
+		return i.imageName
+	}
+	return util.PinnedImageName(i.imageName, i.digest)
+}
+
+// imageNames returns the name of the image with the version tag followed by
+// the names with the extra tags.
+func (i checkImageInfo) imageNames() []string {
+	names := []string{i.imageName}
+	for _, t := range i.extraTags {
+		names = append(names, i.repository()+":"+t)
+	}
+	return names
+}
+
+// log returns the logger with the fields of the image.
+func (i checkImageInfo) log() *slog.Logger {
+	return logger.With("check", i.checktypeName, "tag", i.tag)
+}
+
+// repository returns the name of the image without the tag.
+func (i checkImageInfo) repository() string {
+	// The registry of the image name can contain a port.
+	return i.imageName[:strings.LastIndex(i.imageName, ":")]
+}
+
+// buildSummary contains the info about an image built by the build system
+// that allows to verify that two builds of the same commit are identical.
+type buildSummary struct {
+	Checktype     string `json:"checktype"`
+	Image         string `json:"image"`
+	Commit        string `json:"commit"`
+	ContextDigest string `json:"context_digest"`
+	ImageID       string `json:"image_id"`
+	Digest        string `json:"digest"`
+
+	Vulnerabilities *vulngate.Result `json:"vulnerabilities,omitempty"`
+}
+
+// writeBuildSummary prints the summary of the images built and, if the summary
+// flag is set, writes it as json to the specified file.
+func writeBuildSummary(images []checkImageInfo) error {
+	var summaries []buildSummary
+	for _, i := range images {
+		s := buildSummary{
+			Checktype:     i.checktypeName,
+			Image:         i.imageName,
+			Commit:        i.commit,
+			ContextDigest: i.contextDigest,
+			ImageID:       i.imageID,
+			Digest:        i.digest,
+
+			Vulnerabilities: i.vulns,
+		}
+		i.log().Info("Build summary", "image", s.Image, "commit", s.Commit, "context_digest", s.ContextDigest,
+			"image_id", s.ImageID, "digest", s.Digest)
+		summaries = append(summaries, s)
+	}
+	if summary == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(summary, content, 0644)
 }
 
 func buildImages(imagesFilePath string) error {
@@ -150,17 +438,23 @@ func buildImages(imagesFilePath string) error {
 	}
 
 	if len(images) < 1 {
-		logger.Printf("No images to build")
+		logger.Info("No images to build")
 		return nil
 	}
 
-	logger.Printf("Number of images to build: %v", len(images))
+	logger.Info("Building images", "count", len(images))
 
 	imagesToPush, err := processImages(images)
 	if err != nil {
 		return err
 	}
-	return pushImagesAndChecktypes(imagesToPush)
+	if err = pushImagesAndChecktypes(imagesToPush); err != nil {
+		return err
+	}
+	if err = writeBuildSummary(imagesToPush); err != nil {
+		return err
+	}
+	return blockedImagesError(imagesToPush)
 }
 
 // NOTE: in some other areas we are using something like this to read lines.
@@ -205,11 +499,11 @@ func publishChecks(endpoint string) error {
 		if err != nil {
 			return err
 		}
-		tag, found := util.GetLatestTag(imgInfo.Tags)
+		tag, found := latestTag(imgInfo.Tags)
 		if !found {
 			// If the docker image in artifactory for the checks doesn't have
 			// a valid tag it shouldn't be published.
-			logger.Printf("Skiping image because not valid tag present. Image info:%v", imgInfo)
+			logger.Warn("Skipping image because it has no valid tag", "check", name, "tags", imgInfo.Tags)
 			continue
 		}
 
@@ -218,10 +512,24 @@ func publishChecks(endpoint string) error {
 		if err != nil {
 			return err
 		}
+		if config.Cfg.RequireSignature {
+			// An image that can not be verified must not be published
+			// nor silently skipped.
+			if err := verifyImage(name, tag); err != nil {
+				return fmt.Errorf("can not publish the image %s:%s: %w", name, tag, err)
+			}
+		}
+		if config.Cfg.PinImageDigest {
+			digest, err := util.FetchImageDigest(name, tag)
+			if err != nil {
+				return err
+			}
+			imageName = util.PinnedImageName(imageName, digest)
+		}
 		// Description is a mandatory field, if empty,
 		// means the image doesn't have yet the manifest info stored in artifactory.
 		if repoInfo.Manifest.Description == "" {
-			logger.Printf("There is no manifest info in artifactory for image:%s\n", name)
+			logger.Warn("There is no manifest info in artifactory for the image", "check", name, "tag", tag)
 		}
 		info := checkImageInfo{
 			checktypeName: name,
@@ -233,24 +541,17 @@ func publishChecks(endpoint string) error {
 	}
 	pClient := persistence.NewClient(endpoint)
 	for _, img := range imagesToPub {
-		assetsTypes, err := img.manifest.AssetTypes.Strings()
+		checktype, err := newPersistenceChecktype(img.checktypeName, img.imagePath, img.manifest)
 		if err != nil {
 			return err
 		}
-		resp, err := pClient.PublishChecktype(persistence.Checktype{
-			Name:         img.checktypeName,
-			Description:  img.manifest.Description,
-			Image:        img.imagePath,
-			Options:      img.manifest.Options,
-			RequiredVars: img.manifest.RequiredVars,
-			QueueName:    img.manifest.QueueName,
-			Timeout:      img.manifest.Timeout,
-			Assets:       assetsTypes,
-		})
+		resp, err := pClient.PublishChecktype(checktype)
 		if err != nil {
 			return err
 		}
-		logger.Printf("Checktype %v published to the persistence service. Checktype data returned:\n %+v", img.imageName, resp)
+		logger.Info("Checktype published to the persistence service", "check", img.checktypeName, "image", img.imagePath,
+			"env", endpoint)
+		logger.Debug("Checktype returned by the persistence service", "check", img.checktypeName, "checktype", resp)
 	}
 
 	return nil
@@ -278,48 +579,77 @@ func processImages(images []string) (imagesToPush []checkImageInfo, err error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error getting current sdk version.Details: %v", err)
 	}
-	env := ""
-	if buildBranch != prodBranchName {
-		env = imgNameDevSuffix
+	gate, err := newVulnGate()
+	if err != nil {
+		return nil, err
 	}
 	for _, image := range images {
 		imagePath, tag, commit := parseImgInfo(image)
-		imageName := buildImageNameWithEnvSuffix(path.Base(imagePath), tag)
+		checktypeName, err := branchImageName(path.Base(imagePath))
+		if err != nil {
+			return nil, err
+		}
 		m, err := manifest.Read(path.Join(imagePath, manifestFileName))
 		if err != nil {
 			return nil, err
 		}
 		i := checkImageInfo{
-			imageName:     imageName,
+			imageName:     buildImageName(checktypeName, tag),
 			imagePath:     imagePath,
-			checktypeName: path.Base(imagePath) + env,
+			checktypeName: checktypeName,
 			manifest:      m,
+			commit:        commit,
+			tag:           tag,
+			sdkVersion:    sdkVer,
+			buildPlan:     image,
+			builderImage:  builderImage(),
+			extraTags:     imagetag.Extra(config.Cfg.ExtraTags, commit, buildEnv.Branch),
+			startedOn:     time.Now(),
 		}
-		logger.Printf("Running go build for dir %s", imagePath)
-		if err = util.GoBuildDir(i.imagePath); err != nil {
+		if err = testCheck(i.imagePath); err != nil {
 			return nil, err
 		}
-		logger.Printf("Building image for dir %s", i.imagePath)
-		contents, err := util.BuildTarFromDir(i.imagePath)
+		contents, err := buildContext(i.imagePath)
 		if err != nil {
 			return nil, err
 		}
 		// Marshall manifest.
 		man, err := json.Marshal(i.manifest)
 		if err != nil {
+			contents.Close() // nolint: errcheck
 			return nil, err
 		}
-		logOutput, err := util.BuildImage(contents, []string{i.imageName}, map[string]string{
+		logOutput, imageID, err := util.BuildImage(contents, i.imageNames(), map[string]string{
 			"commit":      commit,
 			"sdk-version": sdkVer,
 			"manifest":    string(man),
-		})
+		}, i.log())
 		if err != nil {
-			logger.Printf("Output of the failed docker build: %s", logOutput)
+			contents.Close() // nolint: errcheck
+			logContextSize(i.imagePath, contents)
+			i.log().Error("Docker build failed", "output", util.RenderDockerOutput(logOutput))
 			return nil, err
 		}
+		i.contextDigest, err = finishContext(contents)
+		logContextSize(i.imagePath, contents)
+		if err != nil {
+			return nil, fmt.Errorf("build context digest of %s: %w", i.imagePath, err)
+		}
+		i.imageID = imageID
+		if sbomEnabled() {
+			bom, err := generateSBOM(i.imageName, i.imagePath, imageBaseName(i.imageName), i.tag)
+			if err != nil {
+				return nil, err
+			}
+			i.sbom = &bom
+		}
+		if gate != nil {
+			r := checkVulns(*gate, i)
+			i.vulns = &r
+		}
+		i.finishedOn = time.Now()
 
-		logger.Printf("Docker image built")
+		i.log().Info("Docker image built", "context_digest", i.contextDigest, "image_id", i.imageID)
 		imagesToPush = append(imagesToPush, i)
 	}
 
@@ -334,14 +664,23 @@ func parseImgInfo(imgInfo string) (path, tag, commit string) {
 	return
 }
 
-func buildImageNameWithEnvSuffix(imgName, tag string) string {
-	if buildBranch != prodBranchName {
-		imgName = imgName + imgNameDevSuffix
+// branchImageName returns the name of the image and the checktype of a check
+// built from the build branch: the name of the check for the production
+// branch, or the one generated by the dev name template of the config for
+// the other branches.
+func branchImageName(check string) (string, error) {
+	if prodBuild() {
+		return check, nil
 	}
-	return buildImageName(imgName, tag)
+	return naming.DevName(config.Cfg.DevNameTemplate, check, buildEnv.Branch)
 }
+
 func buildImageName(imgName, tag string) string {
-	return fmt.Sprintf("%s/%s/%s:%s", config.Cfg.DockerRegistry, config.Cfg.VulcanChecksRepo, imgName, tag)
+	return fmt.Sprintf("%s:%s", buildImageRepository(imgName), tag)
+}
+
+func buildImageRepository(imgName string) string {
+	return fmt.Sprintf("%s/%s/%s", config.Cfg.DockerRegistry, config.Cfg.VulcanChecksRepo, imgName)
 }
 func pubChecktypeToPersistence(checkName string, metadata manifest.Data, imagePath string, fail bool, envs ...string) error {
 	for _, persistenceEndPoint := range envs {
@@ -349,69 +688,118 @@ func pubChecktypeToPersistence(checkName string, metadata manifest.Data, imagePa
 		if persistenceEndPoint == "" {
 			continue
 		}
-		logger.Printf("Publishing image to a new checktype in: %v", persistenceEndPoint)
+		l := logger.With("check", checkName, "env", persistenceEndPoint)
+		l.Info("Publishing the checktype", "image", imagePath)
 		pClient := persistence.NewClient(persistenceEndPoint)
-		assetTypes, err := metadata.AssetTypes.Strings()
+		checktype, err := newPersistenceChecktype(checkName, imagePath, metadata)
 		if err != nil {
 			return err
 		}
-		resp, err := pClient.PublishChecktype(persistence.Checktype{
-			Name:         checkName,
-			Description:  metadata.Description,
-			Image:        imagePath,
-			Options:      metadata.Options,
-			RequiredVars: metadata.RequiredVars,
-			QueueName:    metadata.QueueName,
-			Timeout:      metadata.Timeout,
-			Assets:       assetTypes,
-		})
+		resp, err := pClient.PublishChecktype(checktype)
 		if err != nil && fail {
 			return err
 		}
 		if err != nil && !fail {
-			fmt.Printf("error pushing to secondary persistence:%+s, the process will continue", persistenceEndPoint)
+			l.Warn("Error publishing the checktype to a secondary env, the process will continue", "error", err)
 			continue
 		}
 
-		logger.Printf("Checktype %v published to the persistence service. Checktype data returned:\n %+v", imagePath, resp)
+		l.Info("Checktype published to the persistence service", "image", imagePath)
+		l.Debug("Checktype returned by the persistence service", "checktype", resp)
 	}
 	return nil
 }
 
+// newPersistenceChecktype returns the checktype published to the persistence
+// service for a check. As a checktype has only one set of required vars and
+// one timeout, the ones of the asset type overrides of the manifest are
+// flattened: the checktype requires all the vars and has the highest
+// timeout. The metadata of the manifest is only included if
+// publish_manifest_metadata is enabled in the config.
+func newPersistenceChecktype(checkName string, imagePath string, m manifest.Data) (persistence.Checktype, error) {
+	assetTypes, err := m.AssetTypes.Strings()
+	if err != nil {
+		return persistence.Checktype{}, err
+	}
+	assetTypeOptions, err := m.AssetTypeOptions()
+	if err != nil {
+		return persistence.Checktype{}, err
+	}
+	checktype := persistence.Checktype{
+		Name:             checkName,
+		Description:      m.Description,
+		Image:            imagePath,
+		Options:          m.Options,
+		RequiredVars:     m.AllRequiredVars(),
+		QueueName:        m.QueueName,
+		Timeout:          m.MaxTimeout(),
+		Assets:           assetTypes,
+		AssetTypeOptions: assetTypeOptions,
+	}
+	if config.Cfg.PublishManifestMetadata {
+		checktype.Owner = m.Owner
+		checktype.Contact = m.Contact
+		checktype.Tags = m.Tags
+		checktype.DocsURL = m.DocsURL
+		checktype.Version = m.Version
+		checktype.Deprecated = m.Deprecated
+		checktype.VulnerabilityClasses = m.VulnerabilityClasses
+		checktype.MinSeverity = m.MinSeverity
+		checktype.MaxSeverity = m.MaxSeverity
+	}
+	return checktype, nil
+}
+
 func pushImagesAndChecktypes(imagesToPush []checkImageInfo) error {
-	for _, i := range imagesToPush {
-		logger.Printf("Pushing image %s", i.imageName)
-		_, err := util.PushImage(i.imageName, nil)
-		if err != nil {
+	for n := range imagesToPush {
+		// Store the digest in the slice so it's included in the summary.
+		i := &imagesToPush[n]
+		if i.vulns != nil && !i.vulns.Passed() {
+			i.log().Warn("Not pushing image because of the vulnerabilities", "vulnerabilities", i.vulns.Blocking)
+			continue
+		}
+		if err := pushImage(i); err != nil {
 			return err
 		}
-		logger.Printf("Docker image %s pushed", i.imageName)
-		if buildBranch != prodBranchName {
-			// In feature branches only publish checktypes to dev envs. For the
-			// primary envs we fail if there is an error publising the check to
-			// any of them.
-			err = pubChecktypeToPersistence(i.checktypeName, i.manifest, i.imageName, true, config.Cfg.PrimaryDevBranchEnvs...)
-			if err == nil {
-				// For the primary envs we don't fail if there is an error
-				// publising the check to any of them.
-				err = pubChecktypeToPersistence(i.checktypeName, i.manifest, i.imageName, false, config.Cfg.SecondaryDevBranchEnvs...)
-			}
-		} else {
-			// In master branch publish checktypes to all the environments.
-			primaryEnvs := append(config.Cfg.PrimaryMasterBranchEnvs, config.Cfg.PrimaryDevBranchEnvs...)
-			err = pubChecktypeToPersistence(i.checktypeName, i.manifest, i.imageName, true, primaryEnvs...)
-			if err == nil {
-				secondaryEnvs := append(config.Cfg.SecondaryMasterBranchEnvs, config.Cfg.SecondaryDevBranchEnvs...)
-				err = pubChecktypeToPersistence(i.checktypeName, i.manifest, i.imageName, false, secondaryEnvs...)
-			}
+		if err := signImage(*i); err != nil {
+			return err
 		}
-		if err != nil {
+		if err := pushSBOM(*i); err != nil {
+			return err
+		}
+		if err := publishChecktype(i.checktypeName, i.manifest, i.publishedImageName(), prodBuild()); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// publishChecktype publishes the checktype of an image to the envs of the
+// dev branches or, if prod is true, to the envs of all the branches.
+func publishChecktype(checkName string, m manifest.Data, image string, prod bool) error {
+	var err error
+	if !prod {
+		// In feature branches only publish checktypes to dev envs. For the
+		// primary envs we fail if there is an error publising the check to
+		// any of them.
+		err = pubChecktypeToPersistence(checkName, m, image, true, config.Cfg.PrimaryDevBranchEnvs...)
+		if err == nil {
+			// For the primary envs we don't fail if there is an error
+			// publising the check to any of them.
+			err = pubChecktypeToPersistence(checkName, m, image, false, config.Cfg.SecondaryDevBranchEnvs...)
+		}
+	} else {
+		// In master branch publish checktypes to all the environments.
+		primaryEnvs := append(config.Cfg.PrimaryMasterBranchEnvs, config.Cfg.PrimaryDevBranchEnvs...)
+		err = pubChecktypeToPersistence(checkName, m, image, true, primaryEnvs...)
+		if err == nil {
+			secondaryEnvs := append(config.Cfg.SecondaryMasterBranchEnvs, config.Cfg.SecondaryDevBranchEnvs...)
+			err = pubChecktypeToPersistence(checkName, m, image, false, secondaryEnvs...)
+		}
+	}
+	return err
+}
+
 func forceRun(imagePath string) error {
 	var (
 		err       error
@@ -446,17 +834,26 @@ func forceRun(imagePath string) error {
 		for k, v := range c.RequiredVars {
 			env = append(env, fmt.Sprintf("%s=%s", k, v))
 		}
+		if err = validateTarget(c.Check.Target, c.Check.AssetType); err != nil {
+			return fmt.Errorf("invalid check target in %s: %w", cpath, err)
+		}
 	}
 
 	return util.RunCheckImage(imageName, env)
 }
 
 func forceRunReport(imagePath string, reportPath string) error {
+	f := reportFormat
+	if f == "" {
+		f = checkreport.FormatJSON
+	}
+	if err := checkreport.ValidateFormat(f); err != nil {
+		return err
+	}
 	imageName, err := forceBuild(imagePath)
 	if err != nil {
 		return err
 	}
-	var env []string
 	// Setup container env by reading, the local.toml file of the check.
 	// The file must exists.
 	cpath := path.Join(imagePath, "local.toml")
@@ -467,7 +864,30 @@ func forceRunReport(imagePath string, reportPath string) error {
 	if err != nil {
 		return err
 	}
+	if err = validateTarget(c.Check.Target, c.Check.AssetType); err != nil {
+		return fmt.Errorf("invalid check target in %s: %w", cpath, err)
+	}
+	r, err := runCheckReport(imageName, c, 0)
+	if err != nil {
+		return err
+	}
+	var content bytes.Buffer
+	if err = checkreport.Render(&content, f, r); err != nil {
+		return err
+	}
+	if reportPath == "" {
+		_, err = outputWriter.Write(content.Bytes())
+		return err
+	}
+	return os.WriteFile(reportPath, content.Bytes(), 0777)
+}
 
+// runCheckReport runs a check image with the target, options and required
+// vars defined in the given config, sending its messages to a local queue, and
+// returns the last report sent by the check. If timeout is greater than zero
+// the check is stopped if it doesn't finish in that time.
+func runCheckReport(imageName string, c *sdkconfig.Config, timeout time.Duration) (report.Report, error) {
+	var env []string
 	allowPrivateIPs := true
 	if c.AllowPrivateIPs != nil {
 		allowPrivateIPs = *c.AllowPrivateIPs
@@ -482,7 +902,7 @@ func forceRunReport(imagePath string, reportPath string) error {
 	// Create a queue to store the check messages in memory.
 	q, err := queue.NewSimpleMQClientServer()
 	if err != nil {
-		return err
+		return report.Report{}, err
 	}
 	var qdone = make(chan error)
 	go func() {
@@ -491,7 +911,7 @@ func forceRunReport(imagePath string, reportPath string) error {
 	}()
 	err = q.WaitStart(time.Duration(5) * time.Second)
 	if err != nil {
-		return err
+		return report.Report{}, err
 	}
 	var (
 		agentAddr string
@@ -506,6 +926,9 @@ func forceRunReport(imagePath string, reportPath string) error {
 	}
 	// NOTE: the name of the env vars should be read from public constants of the sdk.
 	env = append(env, "VULCAN_CHECK_TARGET="+c.Check.Target)
+	if c.Check.AssetType != "" {
+		env = append(env, "VULCAN_CHECK_ASSET_TYPE="+c.Check.AssetType)
+	}
 	env = append(env, "VULCAN_CHECK_OPTIONS="+c.Check.Opts)
 	env = append(env, "VULCAN_ALLOW_PRIVATE_IPS="+strconv.FormatBool(allowPrivateIPs))
 	env = append(env, fmt.Sprintf("%s=%s", "VULCAN_AGENT_ADDRESS", agentAddr))
@@ -514,23 +937,23 @@ func forceRunReport(imagePath string, reportPath string) error {
 	for k, v := range c.RequiredVars {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
-	fmt.Printf("env passed to docker %+v", env)
-	err = util.RunCheckReportImage(imageName, env, c.Check.Target, host)
+	logger.Debug("Env passed to docker", "env", env)
+	err = util.RunCheckReportImage(imageName, env, c.Check.Target, host, timeout)
 	if err != nil {
 		cerr := closeQueue(&q, qdone)
 		if cerr != nil {
 			err = fmt.Errorf("error: %s, and error closing the queue %w", err.Error(), cerr)
 
 		}
-		return err
+		return report.Report{}, err
 	}
-	// Get the last message and print the report out.
+	// Get the last message.
 	var finish bool
 	var last queue.Check
 	for !finish {
 		c, err := q.Dequeue()
 		if err != nil {
-			return err
+			return report.Report{}, err
 		}
 		if c == nil {
 			finish = true
@@ -540,13 +963,9 @@ func forceRunReport(imagePath string, reportPath string) error {
 	}
 	err = closeQueue(&q, qdone)
 	if err != nil {
-		return fmt.Errorf("error closing the queue: %w", err)
+		return report.Report{}, fmt.Errorf("error closing the queue: %w", err)
 	}
-	content, err := json.Marshal(last.State.Report)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(reportPath, content, 0777)
+	return last.State.Report, nil
 }
 
 func closeQueue(q *queue.SimpleMQClientServer, qdone chan error) error {
@@ -581,33 +1000,88 @@ func getAgentAddr(addr string) string {
 }
 
 func forceBuild(imagePath string) (string, error) {
-	env := ""
-	if buildBranch != prodBranchName {
-		env = imgNameDevSuffix
+	imageName, err := branchImageName(path.Base(imagePath))
+	if err != nil {
+		return "", err
 	}
-	logger.Printf("Reading manifest for dir %s", imagePath)
-	// Run go build in the check dir.
-	if err := goBuild(imagePath); err != nil {
+	if err := testCheck(imagePath); err != nil {
 		return "", err
 	}
-	// Build tar file with docker image contents.
-	logger.Printf("Building image for dir %s", imagePath)
-	contents, err := util.BuildTarFromDir(imagePath)
+	contents, err := buildContext(imagePath)
 	if err != nil {
 		return "", err
 	}
+	defer contents.Close() // nolint: errcheck
 
-	imageName := path.Base(imagePath)
-	imageName = fmt.Sprintf("%s%s", imageName, env)
-	r, err := util.BuildImage(contents, []string{imageName}, map[string]string{})
+	r, imageID, err := util.BuildImage(contents, []string{imageName}, map[string]string{}, logger)
 	if err != nil {
+		logContextSize(imagePath, contents)
 		return "", err
 	}
-	logger.Printf("Docker image built, build log:\n%s\nImage name %s:", r, imageName)
+	contextDigest, err := finishContext(contents)
+	logContextSize(imagePath, contents)
+	if err != nil {
+		return "", fmt.Errorf("build context digest of %s: %w", imagePath, err)
+	}
+	logger.Info("Docker image built", "image", imageName)
+	logger.Debug("Docker build output", "image", imageName, "output", util.RenderDockerOutput(r))
+	if sbomEnabled() {
+		if _, err = generateSBOM(imageName, imagePath, imageName, "latest"); err != nil {
+			return "", err
+		}
+	}
+	logger.Info("Local image ready", "image", imageName, "context_digest", contextDigest, "image_id", imageID)
 	return imageName, nil
 }
 
+// buildContext returns the docker build context of the check in imagePath.
+// When multi-stage builds are enabled the check binary is compiled inside the
+// docker build, otherwise go build is run in the host before building the
+// context.
+func buildContext(imagePath string) (*util.BuildContext, error) {
+	if builder := builderImage(); builder != "" {
+		logger.Info("Building multi-stage image", "dir", imagePath, "builder_image", builder)
+		return util.BuildMultiStageTarFromDir(imagePath, builder)
+	}
+	// Run go build in the check dir.
+	if err := goBuild(imagePath); err != nil {
+		return nil, err
+	}
+	// Stream a tar file with docker image contents.
+	logger.Info("Building image", "dir", imagePath)
+	return util.StreamTarFromDir(imagePath)
+}
+
+// builderImage returns the image used to build the check binaries, or an
+// empty string if multi-stage builds are not enabled.
+func builderImage() string {
+	if !multiStage && !config.Cfg.MultiStageBuild {
+		return ""
+	}
+	if config.Cfg.GoBuilderImage == "" {
+		return util.DefaultGoBuilderImage
+	}
+	return config.Cfg.GoBuilderImage
+}
+
+// finishContext reads the rest of the build context, in case docker stopped
+// reading it before the end, closes it and returns its digest.
+func finishContext(c *util.BuildContext) (string, error) {
+	if _, err := io.Copy(io.Discard, c); err != nil {
+		c.Close() // nolint: errcheck
+		return "", err
+	}
+	if err := c.Close(); err != nil {
+		return "", err
+	}
+	return c.Digest()
+}
+
+func logContextSize(imagePath string, c *util.BuildContext) {
+	logger.Info("Build context sent", "dir", imagePath, "entries", c.Files(), "bytes", c.Size())
+}
+
 func goBuild(imagePath string) error {
-	logger.Printf("Running go build for dir %s", imagePath)
+	logger.Info("Running go build", "dir", imagePath)
 	return util.GoBuildDir(imagePath)
 }
@@ -5,13 +5,26 @@ Copyright 2019 Adevinta
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	sdkconfig "github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-checks-bsys/checktest"
+	"github.com/adevinta/vulcan-checks-bsys/ci"
+	"github.com/adevinta/vulcan-checks-bsys/config"
 	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/persistence"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+	"github.com/adevinta/vulcan-checks-bsys/vulngate"
 )
 
 func TestMainFParam(t *testing.T) {
@@ -35,6 +48,9 @@ func TestMainFParam(t *testing.T) {
 			},
 		},
 	}
+	// The tests of the checks are covered by Test_testCheck.
+	skipTests = true
+	defer func() { skipTests = false }()
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
@@ -118,3 +134,407 @@ func Test_pubChecktypeToPersistence(t *testing.T) {
 		})
 	}
 }
+
+func Test_newPersistenceChecktype(t *testing.T) {
+	hostname, webAddress := manifest.Hostname, manifest.WebAddress
+	m := manifest.Data{
+		Description: "Description",
+		Timeout:     700,
+		AssetTypes:  manifest.AssetTypes{&hostname},
+		Owner:       "purple-team",
+		Tags:        []string{"tls"},
+		Deprecated:  true,
+		MaxSeverity: "HIGH",
+	}
+	tests := []struct {
+		name            string
+		manifest        manifest.Data
+		publishMetadata bool
+		want            persistence.Checktype
+	}{
+		{
+			name:     "WithoutMetadata",
+			manifest: m,
+			want: persistence.Checktype{
+				Name:        "vulcan-tls",
+				Description: "Description",
+				Timeout:     700,
+				Image:       "vulcan-tls:1",
+				Assets:      []string{"Hostname"},
+			},
+		},
+		{
+			name: "WithAssetTypeOverrides",
+			manifest: manifest.Data{
+				Description:  "Description",
+				Timeout:      700,
+				Options:      `{"port":443}`,
+				RequiredVars: []string{"API_KEY"},
+				AssetTypes:   manifest.AssetTypes{&hostname, &webAddress},
+				AssetTypeOverrides: map[string]manifest.AssetTypeOverride{
+					"WebAddress": {Options: `{"depth":3}`, RequiredVars: []string{"CRAWLER_TOKEN"}, Timeout: 900},
+				},
+			},
+			want: persistence.Checktype{
+				Name:             "vulcan-tls",
+				Description:      "Description",
+				Timeout:          900,
+				Image:            "vulcan-tls:1",
+				Options:          `{"port":443}`,
+				RequiredVars:     []string{"API_KEY", "CRAWLER_TOKEN"},
+				Assets:           []string{"Hostname", "WebAddress"},
+				AssetTypeOptions: map[string]string{"WebAddress": `{"depth":3,"port":443}`},
+			},
+		},
+		{
+			name:            "WithMetadata",
+			manifest:        m,
+			publishMetadata: true,
+			want: persistence.Checktype{
+				Name:        "vulcan-tls",
+				Description: "Description",
+				Timeout:     700,
+				Image:       "vulcan-tls:1",
+				Assets:      []string{"Hostname"},
+				Owner:       "purple-team",
+				Tags:        []string{"tls"},
+				Deprecated:  true,
+				MaxSeverity: "HIGH",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.PublishManifestMetadata = tt.publishMetadata
+			defer func() { config.Cfg.PublishManifestMetadata = false }()
+			got, err := newPersistenceChecktype("vulcan-tls", "vulcan-tls:1", tt.manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newPersistenceChecktype() got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkImageInfo_imageNames(t *testing.T) {
+	i := checkImageInfo{
+		imageName: "registry.example.com/vulcan-checks/vulcan-tls:1.2.0",
+		extraTags: []string{"latest", "sha-a1b2c3d"},
+	}
+	want := []string{
+		"registry.example.com/vulcan-checks/vulcan-tls:1.2.0",
+		"registry.example.com/vulcan-checks/vulcan-tls:latest",
+		"registry.example.com/vulcan-checks/vulcan-tls:sha-a1b2c3d",
+	}
+	if got := i.imageNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("imageNames() got %v, want %v", got, want)
+	}
+}
+
+func Test_branchImageName(t *testing.T) {
+	defer func(c config.Config, e ci.Env) { config.Cfg, buildEnv = c, e }(config.Cfg, buildEnv)
+	tests := []struct {
+		name     string
+		template string
+		env      ci.Env
+		want     string
+	}{
+		{name: "Master", template: "{check}-{branch-slug}", env: ci.Env{Branch: "master"}, want: "vulcan-tls"},
+		{name: "PullRequest", env: ci.Env{Branch: "master", PullRequest: "42"}, want: "vulcan-tls-experimental"},
+		{name: "DefaultTemplate", env: ci.Env{Branch: "feature/x"}, want: "vulcan-tls-experimental"},
+		{name: "BranchTemplate", template: "{check}-{branch-slug}", env: ci.Env{Branch: "feature/x"}, want: "vulcan-tls-feature-x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.DevNameTemplate = tt.template
+			buildEnv = tt.env
+			got, err := branchImageName("vulcan-tls")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("branchImageName() got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_blockedImagesError(t *testing.T) {
+	tests := []struct {
+		name    string
+		images  []checkImageInfo
+		wantErr bool
+	}{
+		{
+			name: "GateDisabled",
+			images: []checkImageInfo{
+				{imageName: "vulcan-nessus:3"},
+			},
+		},
+		{
+			name: "Passed",
+			images: []checkImageInfo{
+				{imageName: "vulcan-nessus:3", vulns: &vulngate.Result{}},
+			},
+		},
+		{
+			name: "Blocked",
+			images: []checkImageInfo{
+				{imageName: "vulcan-nessus:3", vulns: &vulngate.Result{}},
+				{imageName: "vulcan-zap:2", vulns: &vulngate.Result{Blocking: []string{"CVE-2023-0001"}}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := blockedImagesError(tt.images); (err != nil) != tt.wantErr {
+				t.Errorf("blockedImagesError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_testCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		test    string
+		policy  string
+		wantErr bool
+	}{
+		{
+			name: "Passed",
+			test: "func TestCheck(t *testing.T) {}",
+		},
+		{
+			name:    "Failed",
+			test:    `func TestCheck(t *testing.T) { t.Error("failed") }`,
+			wantErr: true,
+		},
+		{
+			name:   "FailedWarn",
+			test:   `func TestCheck(t *testing.T) { t.Error("failed") }`,
+			policy: testPolicyWarn,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			checkDir := filepath.Join(dir, "vulcan-check")
+			files := map[string]string{
+				"go.mod":       "module example.com/checks\n\ngo 1.22\n",
+				"main.go":      "package main\n\nfunc main() {}\n",
+				"main_test.go": "package main\n\nimport \"testing\"\n\n" + tt.test + "\n",
+			}
+			if err := os.Mkdir(checkDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(checkDir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			reportsDir := filepath.Join(dir, "reports")
+			oldCfg := config.Cfg
+			defer func() { config.Cfg = oldCfg }()
+			config.Cfg.TestPolicy = tt.policy
+			config.Cfg.TestReportsDir = reportsDir
+
+			err := testCheck(checkDir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("testCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(reportsDir, "vulcan-check.junit.xml")); err != nil {
+				t.Errorf("JUnit report not written: %v", err)
+			}
+		})
+	}
+}
+
+func Test_finishContext(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), bytes.Repeat([]byte("a"), 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	full, err := util.StreamTarFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := finishContext(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A context only partially read, as when docker stops reading it before
+	// the end, must have the same digest.
+	partial, err := util.StreamTarFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(partial, make([]byte, 512)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := finishContext(partial)
+	if err != nil {
+		t.Fatalf("finishContext() error = %v", err)
+	}
+	if got == "" || got != want {
+		t.Errorf("finishContext() = %q, want %q", got, want)
+	}
+}
+
+func Test_validateTarget(t *testing.T) {
+	if _, err := manifest.RegisterAssetTypePattern("TestSlackChannel", "A slack channel.", `^#[a-z0-9-]+$`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		target    string
+		assetType string
+		wantErr   bool
+	}{
+		{name: "NoAssetType", target: "anything"},
+		{name: "ValidBuiltin", target: "127.0.0.1", assetType: "IP"},
+		{name: "InvalidBuiltin", target: "example.com", assetType: "IP", wantErr: true},
+		{name: "ValidConfigPattern", target: "#security", assetType: "TestSlackChannel"},
+		{name: "InvalidConfigPattern", target: "security", assetType: "TestSlackChannel", wantErr: true},
+		{name: "UnknownAssetType", target: "127.0.0.1", assetType: "Unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTarget(tt.target, tt.assetType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_testCaseConfig(t *testing.T) {
+	ip, hostname := manifest.IP, manifest.Hostname
+	m := manifest.Data{
+		Description: "desc",
+		Timeout:     300,
+		Options:     `{"depth":1,"port":443}`,
+		AssetTypes:  manifest.AssetTypes{&ip, &hostname},
+		AssetTypeOverrides: map[string]manifest.AssetTypeOverride{
+			"IP": {Options: `{"depth":3}`, Timeout: 900},
+		},
+	}
+	local := &sdkconfig.Config{RequiredVars: map[string]string{"API_KEY": "local", "TOKEN": "local"}}
+	tests := []struct {
+		name        string
+		tc          checktest.Case
+		wantOpts    string
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{
+			name: "Override",
+			tc: checktest.Case{
+				Target:       "127.0.0.1:8080",
+				AssetType:    "IP",
+				Options:      `{"port":8080}`,
+				RequiredVars: map[string]string{"TOKEN": "case"},
+			},
+			wantOpts:    `{"depth":3,"port":8080}`,
+			wantTimeout: 900 * time.Second,
+		},
+		{
+			name: "NoOverride",
+			tc: checktest.Case{
+				Target:       "example.com",
+				AssetType:    "Hostname",
+				RequiredVars: map[string]string{"TOKEN": "case"},
+			},
+			wantOpts:    `{"depth":1,"port":443}`,
+			wantTimeout: 300 * time.Second,
+		},
+		{
+			name: "InvalidOptions",
+			tc: checktest.Case{
+				Target:    "127.0.0.1",
+				AssetType: "IP",
+				Options:   `{`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, timeout, err := testCaseConfig(tt.tc, m, local)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("testCaseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Check.Target != tt.tc.Target || got.Check.AssetType != tt.tc.AssetType {
+				t.Errorf("testCaseConfig() check = %+v, want target and asset type of the case", got.Check)
+			}
+			if got.Check.Opts != tt.wantOpts {
+				t.Errorf("testCaseConfig() options = %s, want %s", got.Check.Opts, tt.wantOpts)
+			}
+			if timeout != tt.wantTimeout {
+				t.Errorf("testCaseConfig() timeout = %v, want %v", timeout, tt.wantTimeout)
+			}
+			want := map[string]string{"API_KEY": "local", "TOKEN": "case"}
+			if !reflect.DeepEqual(got.RequiredVars, want) {
+				t.Errorf("testCaseConfig() required vars = %v, want %v", got.RequiredVars, want)
+			}
+		})
+	}
+}
+
+func Test_formatManifests(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "cmd", "vulcan-tls")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "Timeout=300\nDescription='TLS check'\nAssetTypes=['Hostname']\nOptions = \"{\\\"port\\\": 443}\"\n"
+	if err := os.WriteFile(filepath.Join(dir, manifestFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := formatManifests(root); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Description = \"TLS check\"\nTimeout = 300\nOptions = '{\"port\": 443}'\nAssetTypes = [\"Hostname\"]\n"
+	if string(got) != want {
+		t.Errorf("formatManifests() got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func Test_checkName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "cmd/vulcan-tls", want: "vulcan-tls"},
+		{path: "cmd/vulcan-tls/", want: "vulcan-tls"},
+		{path: "cmd/vulcan-tls/manifest.toml", want: "vulcan-tls"},
+	}
+	for _, tt := range tests {
+		if got := checkName(tt.path); got != tt.want {
+			t.Errorf("checkName(%q) got %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func Test_persistenceEnvs(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	config.Cfg.PrimaryMasterBranchEnvs = []string{"https://pro.example.com", ""}
+	config.Cfg.SecondaryMasterBranchEnvs = []string{"https://pro2.example.com"}
+	config.Cfg.PrimaryDevBranchEnvs = []string{"https://dev.example.com", "https://pro.example.com"}
+	config.Cfg.SecondaryDevBranchEnvs = nil
+	want := []string{"https://pro.example.com", "https://pro2.example.com", "https://dev.example.com"}
+	if got := persistenceEnvs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("persistenceEnvs() got %v, want %v", got, want)
+	}
+}
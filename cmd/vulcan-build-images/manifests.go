@@ -0,0 +1,203 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/adevinta/vulcan-checks-bsys/lint"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// formatManifests rewrites in canonical form the manifest in p, if it's a
+// file, or all the manifests of the checks under p, if it's a directory. The
+// manifests with comments are not rewritten, as the comments would be lost.
+func formatManifests(p string) error {
+	files, err := manifestFiles(p)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if manifest.HasComments(content) {
+			logger.Warn("Manifest with comments not formatted", "file", f)
+			continue
+		}
+		d, err := manifest.Decode(content, manifest.FormatTOML)
+		if err != nil {
+			return fmt.Errorf("error reading the manifest %s: %w", f, err)
+		}
+		formatted, err := manifest.Format(d)
+		if err != nil {
+			return fmt.Errorf("error formatting the manifest %s: %w", f, err)
+		}
+		if bytes.Equal(content, formatted) {
+			continue
+		}
+		if err = os.WriteFile(f, formatted, 0644); err != nil {
+			return err
+		}
+		logger.Info("Manifest formatted", "file", f)
+	}
+	return nil
+}
+
+// manifestFiles returns p if it's a file, or the paths of the manifests of
+// the checks under p if it's a directory.
+func manifestFiles(p string) ([]string, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+	dirs, err := lint.CheckDirs(p)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, dir := range dirs {
+		f := filepath.Join(dir, manifestFileName)
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// exportManifest writes the manifest in p, a manifest file or a check
+// directory, in the format specified in the export-format flag, json by
+// default, to the output file or to stdout.
+func exportManifest(p string) error {
+	d, err := readManifest(p)
+	if err != nil {
+		return err
+	}
+	f := exportFormat
+	if f == "" {
+		f = manifest.FormatJSON
+	}
+	return writeOutput(func(w io.Writer) error {
+		return manifest.Encode(w, d, f)
+	})
+}
+
+// diffManifest compares the manifest in p, a manifest file or a check
+// directory, with the manifest specified in the diff-against flag, which can
+// be a manifest file or an image of the registry in the form name:tag. If the
+// diff-against flag is not specified, the manifest is compared with the one
+// of the latest image of the check in the registry.
+func diffManifest(p string) error {
+	local, err := readManifest(p)
+	if err != nil {
+		return err
+	}
+	other, desc, err := againstManifest(p)
+	if err != nil {
+		return err
+	}
+	changes := manifest.Diff(other, local)
+	return writeOutput(func(w io.Writer) error {
+		if len(changes) == 0 {
+			_, err := fmt.Fprintf(w, "No differences between %s and %s\n", desc, p)
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "Differences between %s and %s:\n", desc, p); err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if _, err := fmt.Fprintf(w, "  %s\n", c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func againstManifest(p string) (manifest.Data, string, error) {
+	if against != "" {
+		if _, err := os.Stat(against); err == nil {
+			d, err := readManifest(against)
+			return d, against, err
+		}
+		name, tag, ok := strings.Cut(against, ":")
+		if !ok {
+			return manifest.Data{}, "", fmt.Errorf("%s is not a manifest file nor an image in the form name:tag", against)
+		}
+		return registryManifest(name, tag)
+	}
+	name := checkName(p)
+	info, err := util.FetchImagesInfo(name)
+	if err != nil {
+		return manifest.Data{}, "", err
+	}
+	tag, found := latestTag(info.Tags)
+	if !found {
+		return manifest.Data{}, "", fmt.Errorf("no image found in the registry for the check %s", name)
+	}
+	return registryManifest(name, tag)
+}
+
+func registryManifest(name, tag string) (manifest.Data, string, error) {
+	info, err := util.FetchImageTagInfo(name, tag)
+	if err != nil {
+		return manifest.Data{}, "", err
+	}
+	desc := fmt.Sprintf("%s:%s", name, tag)
+	if info.Manifest.Description == "" {
+		return manifest.Data{}, "", fmt.Errorf("the image %s has no manifest label", desc)
+	}
+	return info.Manifest, desc, nil
+}
+
+// readManifest reads the manifest in p, a manifest file or a check
+// directory.
+func readManifest(p string) (manifest.Data, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return manifest.Data{}, err
+	}
+	if info.IsDir() {
+		p = filepath.Join(p, manifestFileName)
+	}
+	return manifest.Read(p)
+}
+
+// checkName returns the name of the check of a manifest file or a check
+// directory.
+func checkName(p string) string {
+	if filepath.Base(p) == manifestFileName {
+		p = filepath.Dir(p)
+	}
+	return path.Base(filepath.ToSlash(filepath.Clean(p)))
+}
+
+// writeOutput calls write with the output file, or stdout if the o flag is
+// not specified.
+func writeOutput(write func(w io.Writer) error) error {
+	if output == "" {
+		return write(outputWriter)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close() // nolint: errcheck
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/adevinta/vulcan-checks-bsys/ci"
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/naming"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// Labels added to the promoted images.
+const (
+	promotedFromLabel = "promoted-from"
+	promotedByLabel   = "promoted-by"
+	promotedAtLabel   = "promoted-at"
+)
+
+// promotion is an image of a check built from a dev branch and the
+// production image it's promoted to.
+type promotion struct {
+	name   string // e.g.: vulcan-tls-experimental
+	digest string
+	// source is the dev image pinned to its digest, e.g.:
+	// container.example.com/vulcan-checks/vulcan-tls-experimental:4@sha256:...
+	source string
+	image  checkImageInfo
+}
+
+// promoteImage republishes an image of a check built from a dev branch as the
+// production image and checktype of the check, without rebuilding it. The
+// image, in the form name:tag or name@digest, is pulled from the registry,
+// labeled with who promoted it and when, tagged with the next version of the
+// production image and pushed. Then its checktype is published to the master
+// branch envs.
+func promoteImage(ref string) error {
+	p, err := planPromotion(ref)
+	if err != nil {
+		return err
+	}
+	i := p.image
+	i.log().Info("Promoting image", "source", p.source, "image", i.imageName)
+	if err = util.PullImage(p.source, logger); err != nil {
+		return fmt.Errorf("error pulling the image %s: %w", p.source, err)
+	}
+	// The labels only change the config of the pulled image, its layers are
+	// kept as they were tested. But the config is part of the manifest, so
+	// the promoted image doesn't have the digest of the tested one, which is
+	// kept in the promoted-from label.
+	i.imageID, err = util.AddImageLabels(p.source, i.imageNames(), map[string]string{
+		promotedFromLabel: p.source,
+		promotedByLabel:   promoter(),
+		promotedAtLabel:   time.Now().UTC().Format(time.RFC3339),
+	}, i.log())
+	if err != nil {
+		return err
+	}
+	i.finishedOn = time.Now()
+	if err = pushImage(&i); err != nil {
+		return err
+	}
+	if err = signImage(i); err != nil {
+		return err
+	}
+	if err = copySBOM(p.name, p.digest, i); err != nil {
+		return err
+	}
+	image := i.publishedImageName()
+	err = pubChecktypeToPersistence(i.checktypeName, i.manifest, image, true, config.Cfg.PrimaryMasterBranchEnvs...)
+	if err != nil {
+		return err
+	}
+	err = pubChecktypeToPersistence(i.checktypeName, i.manifest, image, false, config.Cfg.SecondaryMasterBranchEnvs...)
+	if err != nil {
+		return err
+	}
+	i.log().Info("Image promoted", "source", p.source, "image", image)
+	return nil
+}
+
+// planPromotion resolves, querying only the registry, the dev image to
+// promote, in the form name:tag or name@digest, its check and the tags of
+// the production image it's promoted to. The dev image must have a manifest
+// label and, if signatures are required, a valid signature.
+func planPromotion(ref string) (promotion, error) {
+	name, tag, digest, err := parseImageRef(ref)
+	if err != nil {
+		return promotion{}, err
+	}
+	if digest != "" {
+		if tag, err = digestTag(name, digest); err != nil {
+			return promotion{}, err
+		}
+	}
+	check, err := promotedCheck(name)
+	if err != nil {
+		return promotion{}, err
+	}
+	info, err := util.FetchImageTagInfo(name, tag)
+	if err != nil {
+		return promotion{}, err
+	}
+	if info.Manifest.Description == "" {
+		return promotion{}, fmt.Errorf("the image %s:%s has no manifest label", name, tag)
+	}
+	if config.Cfg.RequireSignature {
+		if err := verifyImage(name, tag); err != nil {
+			return promotion{}, fmt.Errorf("can not promote the image %s:%s: %w", name, tag, err)
+		}
+	}
+	if digest == "" {
+		if digest, err = util.FetchImageDigest(name, tag); err != nil {
+			return promotion{}, err
+		}
+	}
+	source := util.PinnedImageName(buildImageName(name, tag), digest)
+	prodInfo, err := util.FetchImagesInfo(check)
+	if err != nil {
+		return promotion{}, err
+	}
+	prodTag, err := promotedTag(tag, prodInfo.Tags, info.Manifest)
+	if err != nil {
+		return promotion{}, err
+	}
+	i := checkImageInfo{
+		checktypeName: check,
+		imageName:     buildImageName(check, prodTag),
+		manifest:      info.Manifest,
+		commit:        info.Commit,
+		tag:           prodTag,
+		sdkVersion:    info.SDKVersion,
+		buildPlan:     source,
+		extraTags:     imagetag.Extra(config.Cfg.ExtraTags, info.Commit, productionBranch()),
+		startedOn:     time.Now(),
+	}
+	return promotion{name: name, digest: digest, source: source, image: i}, nil
+}
+
+// parseImageRef parses an image in the form name:tag or name@digest.
+func parseImageRef(ref string) (name, tag, digest string, err error) {
+	if name, digest, ok := strings.Cut(ref, "@"); ok {
+		if name == "" || !strings.HasPrefix(digest, "sha256:") {
+			return "", "", "", fmt.Errorf("invalid image %q, it must be in the form name:tag or name@sha256:digest", ref)
+		}
+		return name, "", digest, nil
+	}
+	name, tag, ok := strings.Cut(ref, ":")
+	if !ok || name == "" || tag == "" {
+		return "", "", "", fmt.Errorf("invalid image %q, it must be in the form name:tag or name@sha256:digest", ref)
+	}
+	return name, tag, "", nil
+}
+
+// digestTag returns the version tag of an image that points to the given
+// digest.
+func digestTag(name, digest string) (string, error) {
+	info, err := util.FetchImagesInfo(name)
+	if err != nil {
+		return "", err
+	}
+	for _, tag := range info.Tags {
+		if !imagetag.IsVersion(tag) {
+			continue
+		}
+		d, err := util.FetchImageDigest(name, tag)
+		if err != nil {
+			return "", err
+		}
+		if d == digest {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("no version tag of the image %s points to %s", name, digest)
+}
+
+// promotedCheck returns the check of a dev image: the one specified in the
+// promote-as flag or the one found in its name using the dev name template of
+// the config and the images of the registry.
+func promotedCheck(name string) (string, error) {
+	if promoteAs != "" {
+		if promoteAs == name {
+			return "", fmt.Errorf("the image %s is already a production image", name)
+		}
+		return promoteAs, nil
+	}
+	checks, err := registryChecks()
+	if err != nil {
+		return "", err
+	}
+	check, ok := naming.Check(config.Cfg.DevNameTemplate, name, checks)
+	if !ok {
+		return "", fmt.Errorf("can not find the check of the image %s, specify it with the promote-as flag", name)
+	}
+	return check, nil
+}
+
+// registryChecks returns the names of the images of the checks repo of the
+// registry.
+func registryChecks() ([]string, error) {
+	repos, err := util.FetchRepositories()
+	if err != nil {
+		return nil, err
+	}
+	var checks []string
+	for _, repo := range repos {
+		if c, ok := strings.CutPrefix(repo, config.Cfg.VulcanChecksRepo+"/"); ok {
+			checks = append(checks, c)
+		}
+	}
+	return checks, nil
+}
+
+// promotedTag returns the tag of the production image of a promoted image.
+// With semantic versions the tag of the dev image is kept if it's higher than
+// the production ones, otherwise the latest production tag is incremented.
+func promotedTag(devTag string, prodTags []string, m manifest.Data) (string, error) {
+	latest, found := latestTag(prodTags)
+	if config.Cfg.TagStrategy != imagetag.StrategySemver {
+		return imagetag.NextInteger(latest)
+	}
+	if dev, err := imagetag.ParseVersion(devTag); err == nil {
+		last, err := imagetag.ParseVersion(latest)
+		if !found || (err == nil && dev.Compare(last) > 0) {
+			return devTag, nil
+		}
+	}
+	v, err := imagetag.NextVersion(latest, found, m.Version, nil)
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// productionBranch returns the production branch of the config.
+func productionBranch() string {
+	if config.Cfg.ProductionBranch == "" {
+		return ci.DefaultProductionBranch
+	}
+	return config.Cfg.ProductionBranch
+}
+
+// promoter returns the user that promotes an image: the one that triggered
+// the build in the CI provider or the current user.
+func promoter() string {
+	if buildEnv.Actor != "" {
+		return buildEnv.Actor
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+)
+
+func Test_parseImageRef(t *testing.T) {
+	tests := []struct {
+		ref        string
+		wantName   string
+		wantTag    string
+		wantDigest string
+		wantErr    bool
+	}{
+		{ref: "vulcan-tls-experimental:12", wantName: "vulcan-tls-experimental", wantTag: "12"},
+		{ref: "vulcan-tls-experimental@sha256:0b8a4c", wantName: "vulcan-tls-experimental", wantDigest: "sha256:0b8a4c"},
+		{ref: "vulcan-tls-experimental", wantErr: true},
+		{ref: "vulcan-tls-experimental:", wantErr: true},
+		{ref: "vulcan-tls-experimental@0b8a4c", wantErr: true},
+		{ref: "@sha256:0b8a4c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			name, tag, digest, err := parseImageRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if name != tt.wantName || tag != tt.wantTag || digest != tt.wantDigest {
+				t.Errorf("got %q, %q, %q, want %q, %q, %q", name, tag, digest, tt.wantName, tt.wantTag, tt.wantDigest)
+			}
+		})
+	}
+}
+
+func Test_promotedTag(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	tests := []struct {
+		name     string
+		strategy string
+		devTag   string
+		prodTags []string
+		manifest manifest.Data
+		want     string
+	}{
+		{
+			name:     "Integer",
+			strategy: imagetag.StrategyInteger,
+			devTag:   "40",
+			prodTags: []string{"6", "7", "latest"},
+			want:     "8",
+		},
+		{
+			name:   "IntegerFirstImage",
+			devTag: "3",
+			want:   "1",
+		},
+		{
+			name:     "SemverKeepsDevTag",
+			strategy: imagetag.StrategySemver,
+			devTag:   "1.3.0",
+			prodTags: []string{"1.2.0", "1.2.1"},
+			want:     "1.3.0",
+		},
+		{
+			name:     "SemverFirstImage",
+			strategy: imagetag.StrategySemver,
+			devTag:   "0.1.0",
+			want:     "0.1.0",
+		},
+		{
+			name:     "SemverDevTagNotHigher",
+			strategy: imagetag.StrategySemver,
+			devTag:   "1.2.0",
+			prodTags: []string{"1.2.0", "1.2.1"},
+			want:     "1.2.2",
+		},
+		{
+			name:     "SemverManifestVersion",
+			strategy: imagetag.StrategySemver,
+			devTag:   "12",
+			prodTags: []string{"1.2.1"},
+			manifest: manifest.Data{Version: "2.0.0"},
+			want:     "2.0.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.TagStrategy = tt.strategy
+			got, err := promotedTag(tt.devTag, tt.prodTags, tt.manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_planPromotion(t *testing.T) {
+	defer func(c config.Config, as string) { config.Cfg, promoteAs = c, as }(config.Cfg, promoteAs)
+	labels := map[string]map[string]string{
+		"4": {"commit": "a1b2c3d", "sdk-version": "f00", "manifest": `{"Description":"Checks TLS"}`},
+		"3": {"commit": "0f1e2d3"},
+	}
+	reg := newFakeRegistry([]string{"3", "4"}, labels)
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	source := "docker.example.com/vulcan-checks/vulcan-tls-experimental:4@" + reg.digest("4")
+	tests := []struct {
+		name       string
+		ref        string
+		as         string
+		wantSource string
+		wantImage  string
+		wantErr    bool
+	}{
+		{
+			name:       "Tag",
+			ref:        "vulcan-tls-experimental:4",
+			wantSource: source,
+			wantImage:  "docker.example.com/vulcan-checks/vulcan-tls:5",
+		},
+		{
+			name:       "Digest",
+			ref:        "vulcan-tls-experimental@" + reg.digest("4"),
+			wantSource: source,
+			wantImage:  "docker.example.com/vulcan-checks/vulcan-tls:5",
+		},
+		{
+			name:       "As",
+			ref:        "vulcan-tls-experimental:4",
+			as:         "vulcan-tls-1-3",
+			wantSource: source,
+			wantImage:  "docker.example.com/vulcan-checks/vulcan-tls-1-3:5",
+		},
+		{
+			name:    "NoManifest",
+			ref:     "vulcan-tls-experimental:3",
+			wantErr: true,
+		},
+		{
+			name:    "UnknownDigest",
+			ref:     "vulcan-tls-experimental@sha256:0b8a4c",
+			wantErr: true,
+		},
+		{
+			name:    "ProductionImage",
+			ref:     "vulcan-tls:4",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg = config.Config{
+				DockerRegistry:           "docker.example.com",
+				VulcanChecksRepo:         "vulcan-checks",
+				DockerAPIBaseURL:         s.URL,
+				DockerAPIBaseExtendedURL: s.URL,
+				DockerRegistryUser:       "user",
+				DockerRegistryPwd:        "pwd",
+				ExtraTags:                []string{imagetag.ExtraLatest},
+			}
+			promoteAs = tt.as
+			got, err := planPromotion(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planPromotion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.source != tt.wantSource || got.image.imageName != tt.wantImage {
+				t.Errorf("planPromotion() got %s promoted to %s, want %s promoted to %s", got.source, got.image.imageName,
+					tt.wantSource, tt.wantImage)
+			}
+			if got.image.commit != "a1b2c3d" || got.image.manifest.Description != "Checks TLS" {
+				t.Errorf("planPromotion() got commit %s and manifest %+v of the dev image", got.image.commit, got.image.manifest)
+			}
+			if !slices.Equal(got.image.extraTags, []string{"latest"}) {
+				t.Errorf("planPromotion() got extra tags %v, want [latest]", got.image.extraTags)
+			}
+		})
+	}
+}
+
+func Test_planPromotionRequireSignature(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	labels := map[string]map[string]string{"4": {"commit": "a1b2c3d", "manifest": `{"Description":"Checks TLS"}`}}
+	s := buildFakeRegistry([]string{"4"}, labels)
+	defer s.Close()
+	config.Cfg = config.Config{
+		DockerRegistry:           "docker.example.com",
+		VulcanChecksRepo:         "vulcan-checks",
+		DockerAPIBaseURL:         s.URL,
+		DockerAPIBaseExtendedURL: s.URL,
+		DockerRegistryUser:       "user",
+		DockerRegistryPwd:        "pwd",
+		RequireSignature:         true,
+	}
+	_, config.Cfg.SigningPublicKey = writeSigningKeys(t, t.TempDir())
+	if _, err := planPromotion("vulcan-tls-experimental:4"); err == nil {
+		t.Error("planPromotion() got no error, want an error for the image not signed")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+	"github.com/adevinta/vulcan-checks-bsys/naming"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// imageVersion contains the info about a version tag of the image of a
+// check.
+type imageVersion struct {
+	tag          string
+	commit       string
+	sdkVersion   string
+	lastModified time.Time
+	latest       bool
+}
+
+func (v imageVersion) String() string {
+	s := fmt.Sprintf("%s: commit %s, sdk version %s, pushed %s", v.tag, v.commit, v.sdkVersion, v.lastModified.Format(time.RFC3339))
+	if v.latest {
+		s += " (latest)"
+	}
+	return s
+}
+
+// rollbackCheck lists the versions of the image of a check, from the latest
+// to the oldest, with the commit and the sdk version they were built from.
+// If the rollback-to flag is specified the given version is republished as
+// the latest one.
+func rollbackCheck(check string) error {
+	info, err := util.FetchImagesInfo(check)
+	if err != nil {
+		return err
+	}
+	tags := imagetag.Sort(config.Cfg.TagStrategy, info.Tags)
+	if len(tags) == 0 {
+		return fmt.Errorf("no image found in the registry for the check %s", check)
+	}
+	if rollbackTo != "" {
+		return rollbackChecktype(check, tags)
+	}
+	var versions []imageVersion
+	for n, tag := range tags {
+		tagInfo, err := util.FetchImageTagInfo(check, tag)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, imageVersion{
+			tag:          tag,
+			commit:       tagInfo.Commit,
+			sdkVersion:   tagInfo.SDKVersion,
+			lastModified: tagInfo.LastModified,
+			latest:       n == 0,
+		})
+	}
+	return writeOutput(func(w io.Writer) error {
+		return writeImageVersions(w, versions)
+	})
+}
+
+func writeImageVersions(w io.Writer, versions []imageVersion) error {
+	var b strings.Builder
+	for _, v := range versions {
+		fmt.Fprintln(&b, v)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// rollbackChecktype republishes the version of the image of a check
+// specified in the rollback-to flag as the latest one. The image is tagged in
+// the registry, without pulling it, with the version that follows the latest
+// one and the extra tags, so it's the one published by the next publication
+// of the check, and its checktype is published, with the manifest stored in
+// the image, to the envs the check is published to when built.
+func rollbackChecktype(check string, tags []string) error {
+	found := false
+	for _, t := range tags {
+		found = found || t == rollbackTo
+	}
+	if !found {
+		return fmt.Errorf("the image of the check %s has no version %s", check, rollbackTo)
+	}
+	info, err := util.FetchImageTagInfo(check, rollbackTo)
+	if err != nil {
+		return err
+	}
+	if info.Manifest.Description == "" {
+		return fmt.Errorf("the image %s:%s has no manifest label", check, rollbackTo)
+	}
+	if config.Cfg.RequireSignature {
+		if err := verifyImage(check, rollbackTo); err != nil {
+			return fmt.Errorf("can not roll back to the image %s:%s: %w", check, rollbackTo, err)
+		}
+	}
+	digest, err := util.FetchImageDigest(check, rollbackTo)
+	if err != nil {
+		return err
+	}
+	checks, err := registryChecks()
+	if err != nil {
+		return err
+	}
+	// The images of the dev branches are only published to the dev envs.
+	_, dev := naming.Check(config.Cfg.DevNameTemplate, check, checks)
+	branch := ""
+	if !dev {
+		branch = productionBranch()
+	}
+	i := checkImageInfo{
+		checktypeName: check,
+		imageName:     buildImageName(check, rollbackTo),
+		manifest:      info.Manifest,
+		commit:        info.Commit,
+		digest:        digest,
+		tag:           rollbackTo,
+		extraTags:     imagetag.Extra(config.Cfg.ExtraTags, info.Commit, branch),
+	}
+	if err = retagImage(&i); err != nil {
+		return err
+	}
+	if err = publishChecktype(check, i.manifest, i.publishedImageName(), !dev); err != nil {
+		return err
+	}
+	i.log().Info("Checktype rolled back", "image", i.publishedImageName(), "from", rollbackTo, "commit", info.Commit)
+	return nil
+}
+
+// retagImage tags an image of the registry with the version that follows the
+// latest version of its check and with its extra tags. The tags are pushed
+// holding the lease on the tags of the check, if enabled.
+func retagImage(i *checkImageInfo) error {
+	release, err := acquireTagLease(*i)
+	if err != nil {
+		return err
+	}
+	defer release()
+	info, err := util.FetchImagesInfo(i.checktypeName)
+	if err != nil {
+		return err
+	}
+	tag, err := imagetag.NextFree(config.Cfg.TagStrategy, info.Tags, i.tag)
+	if err != nil {
+		return err
+	}
+	for _, t := range append([]string{tag}, i.extraTags...) {
+		if err := util.TagRemoteImage(i.checktypeName, i.tag, t); err != nil {
+			return err
+		}
+	}
+	i.log().Info("Image tagged as the latest version", "new_tag", tag, "digest", i.digest)
+	i.imageName, i.tag = i.repository()+":"+tag, tag
+	return nil
+}
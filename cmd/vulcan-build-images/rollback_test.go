@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+)
+
+func Test_rollbackCheck(t *testing.T) {
+	defer func(c config.Config, o, to string) { config.Cfg, output, rollbackTo = c, o, to }(config.Cfg, output, rollbackTo)
+	tests := []struct {
+		name     string
+		strategy string
+		tags     []string
+		labels   map[string]map[string]string
+		to       string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "Integer",
+			tags: []string{"9", "latest", "10", imagetag.LeaseTag},
+			labels: map[string]map[string]string{
+				"9":  {"commit": "a1b2c3d", "sdk-version": "f00"},
+				"10": {"commit": "d4e5f6a", "sdk-version": "f00"},
+			},
+			want: "10: commit d4e5f6a, sdk version f00, pushed 2017-05-25T14:25:03Z (latest)\n" +
+				"9: commit a1b2c3d, sdk version f00, pushed 2017-05-25T14:25:03Z\n",
+		},
+		{
+			name:     "Semver",
+			strategy: imagetag.StrategySemver,
+			tags:     []string{"1.10.0", "1.9.0", "12"},
+			labels: map[string]map[string]string{
+				"1.9.0":  {"commit": "a1b2c3d", "sdk-version": "f00"},
+				"1.10.0": {"commit": "d4e5f6a", "sdk-version": "f01"},
+			},
+			want: "1.10.0: commit d4e5f6a, sdk version f01, pushed 2017-05-25T14:25:03Z (latest)\n" +
+				"1.9.0: commit a1b2c3d, sdk version f00, pushed 2017-05-25T14:25:03Z\n",
+		},
+		{
+			name:    "NoVersions",
+			tags:    []string{"latest"},
+			wantErr: true,
+		},
+		{
+			name:    "UnknownVersion",
+			tags:    []string{"9", "10"},
+			to:      "8",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := buildFakeRegistry(tt.tags, tt.labels)
+			defer s.Close()
+			config.Cfg.DockerAPIBaseURL = s.URL
+			config.Cfg.DockerAPIBaseExtendedURL = s.URL
+			config.Cfg.DockerRegistryUser = "user"
+			config.Cfg.DockerRegistryPwd = "pwd"
+			config.Cfg.TagStrategy = tt.strategy
+			output = filepath.Join(t.TempDir(), "versions.txt")
+			rollbackTo = tt.to
+			err := rollbackCheck("vulcan-tls")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rollbackChecktype(t *testing.T) {
+	defer func(c config.Config, to string) { config.Cfg, rollbackTo = c, to }(config.Cfg, rollbackTo)
+	var published []string
+	p := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		published = append(published, string(body))
+		writeJSONResponse(w, http.StatusCreated, "{}", nil)
+	}))
+	defer p.Close()
+	labels := map[string]string{"commit": "a1b2c3d", "sdk-version": "f00", "manifest": `{"Description":"Checks TLS"}`}
+	reg := newFakeRegistry([]string{"9", "10", "latest"}, map[string]map[string]string{"9": labels, "10": labels})
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	config.Cfg = config.Config{
+		DockerRegistry:           "docker.example.com",
+		VulcanChecksRepo:         "vulcan-checks",
+		DockerAPIBaseURL:         s.URL,
+		DockerAPIBaseExtendedURL: s.URL,
+		DockerRegistryUser:       "user",
+		DockerRegistryPwd:        "pwd",
+		ExtraTags:                []string{imagetag.ExtraLatest},
+		PinImageDigest:           true,
+		PrimaryMasterBranchEnvs:  []string{p.URL},
+	}
+	rollbackTo = "9"
+	if err := rollbackCheck("vulcan-tls"); err != nil {
+		t.Fatal(err)
+	}
+	digest := reg.digest("9")
+	for _, tag := range []string{"11", "latest"} {
+		if got := reg.digest(tag); got != digest {
+			t.Errorf("got tag %s pointing to %s, want %s", tag, got, digest)
+		}
+	}
+	want := "docker.example.com/vulcan-checks/vulcan-tls:11@" + digest
+	if len(published) != 1 || !strings.Contains(published[0], want) {
+		t.Errorf("got checktypes published %v, want one with the image %s", published, want)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/sbom"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// sbomEnabled returns true if the SBOMs of the images built must be
+// generated, either to store them or to scan them for vulnerabilities.
+func sbomEnabled() bool {
+	return sbomDir() != "" || config.Cfg.VulnDB != ""
+}
+
+// sbomDir returns the directory where the SBOMs are written, or an empty
+// string if the SBOMs must not be generated.
+func sbomDir() string {
+	if sbomDirFlag != "" {
+		return sbomDirFlag
+	}
+	return config.Cfg.SBOMDir
+}
+
+// generateSBOM generates the SBOM of a local image, built from the check in
+// checkDir, containing the go modules of the check binary and the OS packages
+// of the image, and writes it to the SBOM dir, if any. The image is not
+// modified, the SBOM is pushed to the registry once the image is pushed. If no
+// go binary is found in the image the SBOM only contains the OS packages.
+func generateSBOM(imageName, checkDir, name, version string) (sbom.BOM, error) {
+	bins := binaryCandidates(imageName, checkDir)
+	paths := append([]string{sbom.OSReleasePath, sbom.APKInstalledPath, sbom.DpkgStatusPath}, bins...)
+	files, err := util.ReadImageFiles(imageName, paths...)
+	if err != nil {
+		return sbom.BOM{}, err
+	}
+	var modules []sbom.Component
+	if bi := goBuildInfo(files, bins); bi != nil {
+		modules = sbom.GoModules(bi)
+	} else {
+		logger.Warn("No go binary found in the image, the SBOM only contains the OS packages", "image", imageName,
+			"binaries", bins)
+	}
+	pkgs, err := sbom.OSPackages(files)
+	if err != nil {
+		return sbom.BOM{}, err
+	}
+	bom := sbom.New(name, version, modules, pkgs)
+
+	if dir := sbomDir(); dir != "" {
+		if err = writeSBOM(dir, name, version, bom); err != nil {
+			return sbom.BOM{}, err
+		}
+	}
+
+	logger.Info("SBOM generated", "image", imageName, "components", len(bom.Components))
+	return bom, nil
+}
+
+// pushSBOM pushes the SBOM of a pushed image to the registry, next to the
+// image, with the tag cosign uses for the SBOMs, i.e.: sha256-<hex>.sbom. If
+// the SBOM of the image was not generated it does nothing.
+func pushSBOM(i checkImageInfo) error {
+	if i.sbom == nil {
+		return nil
+	}
+	content, err := json.Marshal(i.sbom)
+	if err != nil {
+		return err
+	}
+	name := imageBaseName(i.imageName)
+	err = util.PushArtifact(name, util.ArtifactTag(i.digest, util.SBOMTagSuffix), util.Artifact{
+		MediaType: util.SBOMMediaType,
+		Content:   content,
+	})
+	if err != nil {
+		return fmt.Errorf("error pushing the SBOM of the image: %w", err)
+	}
+	i.log().Info("SBOM pushed", "image", i.repository(), "digest", i.digest)
+	return nil
+}
+
+// copySBOM pushes the SBOM of the image of a check with the given digest, if
+// any, as the SBOM of a pushed image with the same layers, e.g. a promoted
+// one.
+func copySBOM(name, digest string, i checkImageInfo) error {
+	a, err := util.FetchArtifact(name, util.ArtifactTag(digest, util.SBOMTagSuffix))
+	if errors.Is(err, util.ErrArtifactNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = util.PushArtifact(imageBaseName(i.imageName), util.ArtifactTag(i.digest, util.SBOMTagSuffix), a)
+	if err != nil {
+		return fmt.Errorf("error pushing the SBOM of the image: %w", err)
+	}
+	return nil
+}
+
+// binaryCandidates returns the paths of the files of an image that can be
+// the check binary: the binary executed by the image and the files added to
+// the image by the ADD and COPY instructions of the Dockerfile of the check.
+func binaryCandidates(imageName, checkDir string) []string {
+	var bins []string
+	if bin, err := util.ImageBinaryPath(imageName); err == nil {
+		bins = append(bins, bin)
+	} else {
+		logger.Warn("Can not get the binary executed by the image", "image", imageName, "error", err)
+	}
+	dockerfile, err := os.ReadFile(filepath.Join(checkDir, "Dockerfile"))
+	if err != nil {
+		logger.Warn("Can not read the Dockerfile of the check", "dir", checkDir, "error", err)
+		return bins
+	}
+	for _, f := range util.DockerfileAddedFiles(dockerfile) {
+		if !slices.Contains(bins, f) {
+			bins = append(bins, f)
+		}
+	}
+	return bins
+}
+
+// goBuildInfo returns the build info of the first go binary found in the
+// given files of an image, or nil if none of them is a go binary.
+func goBuildInfo(files map[string][]byte, bins []string) *debug.BuildInfo {
+	for _, bin := range bins {
+		content, ok := files[bin]
+		if !ok {
+			continue
+		}
+		bi, err := buildinfo.Read(bytes.NewReader(content))
+		if err != nil {
+			logger.Debug("The file is not a go binary", "file", bin, "error", err)
+			continue
+		}
+		return bi
+	}
+	return nil
+}
+
+func writeSBOM(dir, name, version string, bom sbom.BOM) error {
+	content, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%s-%s.cdx.json", name, version))
+	if err = os.WriteFile(file, content, 0644); err != nil {
+		return err
+	}
+	logger.Info("SBOM written", "file", file)
+	return nil
+}
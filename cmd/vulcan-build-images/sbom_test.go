@@ -0,0 +1,106 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/sbom"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+func Test_goBuildInfo(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gobin, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		files  map[string][]byte
+		bins   []string
+		wantGo bool
+	}{
+		{
+			name:   "Entrypoint",
+			files:  map[string][]byte{"/check": gobin},
+			bins:   []string{"/check"},
+			wantGo: true,
+		},
+		{
+			name:   "EntrypointNotGo",
+			files:  map[string][]byte{"/run.sh": []byte("#!/bin/sh\nexec /usr/bin/check\n"), "/usr/bin/check": gobin},
+			bins:   []string{"/run.sh", "/usr/bin/check"},
+			wantGo: true,
+		},
+		{
+			name:  "NoGoBinary",
+			files: map[string][]byte{"/run.sh": []byte("#!/bin/sh\nexec nmap\n")},
+			bins:  []string{"/run.sh", "/check"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := goBuildInfo(tt.files, tt.bins)
+			if (got != nil) != tt.wantGo {
+				t.Errorf("goBuildInfo() = %v, want go binary %v", got, tt.wantGo)
+			}
+		})
+	}
+}
+
+func Test_pushSBOM(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	reg := newFakeRegistry([]string{"3", "4"}, nil)
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	config.Cfg = config.Config{
+		DockerRegistry:     "docker.example.com",
+		VulcanChecksRepo:   "vulcan-checks",
+		DockerAPIBaseURL:   s.URL,
+		DockerRegistryUser: "user",
+		DockerRegistryPwd:  "pwd",
+	}
+	bom := sbom.New("vulcan-tls", "3", []sbom.Component{{Type: sbom.ComponentTypeLibrary, Name: "golang.org/x/net"}})
+	i := checkImageInfo{
+		checktypeName: "vulcan-tls",
+		imageName:     buildImageName("vulcan-tls", "3"),
+		tag:           "3",
+		digest:        reg.digest("3"),
+		sbom:          &bom,
+	}
+	if err := pushSBOM(i); err != nil {
+		t.Fatal(err)
+	}
+	// The SBOM is copied to an image with the same layers.
+	promoted := checkImageInfo{imageName: buildImageName("vulcan-tls", "4"), digest: reg.digest("4")}
+	if err := copySBOM("vulcan-tls", i.digest, promoted); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{i.digest, promoted.digest} {
+		a, err := util.FetchArtifact("vulcan-tls", util.ArtifactTag(d, util.SBOMTagSuffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got sbom.BOM
+		if err = json.Unmarshal(a.Content, &got); err != nil {
+			t.Fatal(err)
+		}
+		if a.MediaType != util.SBOMMediaType || len(got.Components) != len(bom.Components) {
+			t.Errorf("got SBOM %s with %+v, want %+v", a.MediaType, got, bom)
+		}
+	}
+	// Nothing is copied if the image has no SBOM.
+	if err := copySBOM("vulcan-tls", "sha256:0b8a4c", i); err != nil {
+		t.Fatal(err)
+	}
+}
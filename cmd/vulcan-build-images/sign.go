@@ -0,0 +1,189 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/provenance"
+	"github.com/adevinta/vulcan-checks-bsys/signing"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// signImage generates the provenance of a pushed image and signs the image
+// and the provenance with the configured key. The signature and the
+// attestation are pushed to the registry, next to the image, with the tags
+// cosign uses, i.e.: sha256-<hex>.sig and sha256-<hex>.att, and, if a
+// signatures dir is configured, also written to it. If no signing key is
+// configured it does nothing.
+func signImage(i checkImageInfo) error {
+	if config.Cfg.SigningKey == "" {
+		return nil
+	}
+	key, err := signing.LoadPrivateKey(config.Cfg.SigningKey, []byte(os.Getenv(signing.PasswordEnvVar)))
+	if err != nil {
+		return fmt.Errorf("error loading the signing key: %w", err)
+	}
+	name := imageBaseName(i.imageName)
+	repo := buildImageRepository(name)
+	stmt, err := provenance.New(provenance.BuildInfo{
+		Image:         repo,
+		Digest:        i.digest,
+		Tag:           i.tag,
+		Commit:        i.commit,
+		SDKVersion:    i.sdkVersion,
+		BuildPlan:     i.buildPlan,
+		ContextDigest: i.contextDigest,
+		BuilderImage:  i.builderImage,
+		BuilderID:     config.Cfg.BuilderID,
+		Manifest:      i.manifest,
+		StartedOn:     i.startedOn,
+		FinishedOn:    i.finishedOn,
+	})
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(stmt)
+	if err != nil {
+		return err
+	}
+	att, err := signing.SignEnvelope(key, signing.InTotoPayloadType, payload)
+	if err != nil {
+		return err
+	}
+	sig, err := signing.SignImage(key, repo, i.digest, map[string]string{
+		"commit": i.commit,
+		"tag":    i.tag,
+	})
+	if err != nil {
+		return err
+	}
+	if err = pushSignature(name, i.digest, sig, att); err != nil {
+		return err
+	}
+	if config.Cfg.SignaturesDir != "" {
+		store := signing.Store{Dir: config.Cfg.SignaturesDir}
+		if err = store.WriteSignature(name, i.digest, sig); err != nil {
+			return err
+		}
+		if err = store.WriteAttestation(name, i.digest, att); err != nil {
+			return err
+		}
+	}
+	i.log().Info("Image and its provenance signed", "image", repo, "digest", i.digest)
+	return nil
+}
+
+// pushSignature pushes the signature and the attestation of an image to the
+// registry.
+func pushSignature(name, digest string, sig signing.Signature, att signing.Envelope) error {
+	err := util.PushArtifact(name, util.ArtifactTag(digest, util.SignatureTagSuffix), util.Artifact{
+		MediaType:   util.SignatureMediaType,
+		Content:     sig.Payload,
+		Annotations: map[string]string{signing.SignatureAnnotation: sig.Signature},
+	})
+	if err != nil {
+		return fmt.Errorf("error pushing the signature of the image: %w", err)
+	}
+	content, err := json.Marshal(att)
+	if err != nil {
+		return err
+	}
+	err = util.PushArtifact(name, util.ArtifactTag(digest, util.AttestationTagSuffix), util.Artifact{
+		MediaType: util.AttestationMediaType,
+		Content:   content,
+	})
+	if err != nil {
+		return fmt.Errorf("error pushing the provenance of the image: %w", err)
+	}
+	return nil
+}
+
+// fetchSignature reads the signature and the attestation of an image from
+// the registry.
+func fetchSignature(name, digest string) (signing.Signature, signing.Envelope, error) {
+	a, err := util.FetchArtifact(name, util.ArtifactTag(digest, util.SignatureTagSuffix))
+	if err != nil {
+		return signing.Signature{}, signing.Envelope{}, fmt.Errorf("error reading the signature of the image: %w", err)
+	}
+	sig := signing.Signature{Payload: a.Content, Signature: a.Annotations[signing.SignatureAnnotation]}
+	if a.MediaType != util.SignatureMediaType || sig.Signature == "" {
+		return signing.Signature{}, signing.Envelope{}, errors.New("the signature of the image is not a cosign signature")
+	}
+	a, err = util.FetchArtifact(name, util.ArtifactTag(digest, util.AttestationTagSuffix))
+	if err != nil {
+		return signing.Signature{}, signing.Envelope{}, fmt.Errorf("error reading the provenance of the image: %w", err)
+	}
+	var att signing.Envelope
+	if err = json.Unmarshal(a.Content, &att); err != nil {
+		return signing.Signature{}, signing.Envelope{}, fmt.Errorf("invalid provenance of the image: %w", err)
+	}
+	return sig, att, nil
+}
+
+// verifyImageRef verifies the signature and provenance of an image given in
+// the form name:tag.
+func verifyImageRef(ref string) error {
+	name, tag, ok := strings.Cut(ref, ":")
+	if !ok || name == "" || tag == "" {
+		return fmt.Errorf("invalid image %q, it must be in the form name:tag", ref)
+	}
+	if err := verifyImage(name, tag); err != nil {
+		return err
+	}
+	logger.Info("Signature and provenance of the image are valid", "check", name, "tag", tag)
+	return nil
+}
+
+// verifyImage checks that the given image tag stored in the registry has a
+// valid signature, pushed to the registry next to it, and a valid provenance
+// that states the image was built from the commit stored in its labels.
+func verifyImage(name, tag string) error {
+	if config.Cfg.SigningPublicKey == "" {
+		return errors.New("no public key configured to verify the images")
+	}
+	pub, err := signing.LoadPublicKey(config.Cfg.SigningPublicKey)
+	if err != nil {
+		return err
+	}
+	digest, err := util.FetchImageDigest(name, tag)
+	if err != nil {
+		return err
+	}
+	info, err := util.FetchImageTagInfo(name, tag)
+	if err != nil {
+		return err
+	}
+	sig, att, err := fetchSignature(name, digest)
+	if err != nil {
+		return err
+	}
+	if err = signing.VerifyImage(pub, sig, buildImageRepository(name), digest); err != nil {
+		return err
+	}
+	payload, err := signing.VerifyEnvelope(pub, att)
+	if err != nil {
+		return err
+	}
+	var stmt provenance.Statement
+	if err = json.Unmarshal(payload, &stmt); err != nil {
+		return err
+	}
+	return provenance.Verify(stmt, digest, info.Commit)
+}
+
+// imageBaseName returns the name of an image without the registry, the
+// repository and the tag, e.g.: vulcan-nessus-experimental for
+// docker.example.com/vulcan-checks/vulcan-nessus-experimental:3.
+func imageBaseName(imageName string) string {
+	name := imageName[strings.LastIndex(imageName, "/")+1:]
+	name, _, _ = strings.Cut(name, ":")
+	return name
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+// writeSigningKeys writes a new pair of keys to dir and returns their paths.
+func writeSigningKeys(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPath, pubPath := filepath.Join(dir, "cosign.key"), filepath.Join(dir, "cosign.pub")
+	if err = os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privPath, pubPath
+}
+
+func Test_signImage(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	tests := []struct {
+		name    string
+		commit  string
+		sign    bool
+		wantErr bool
+	}{
+		{
+			name:   "Signed",
+			commit: "a1b2c3d",
+			sign:   true,
+		},
+		{
+			name:    "NotSigned",
+			commit:  "a1b2c3d",
+			wantErr: true,
+		},
+		{
+			name:    "OtherCommit",
+			commit:  "0f1e2d3",
+			sign:    true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newFakeRegistry([]string{"3"}, map[string]map[string]string{"3": {"commit": "a1b2c3d"}})
+			s := httptest.NewServer(reg)
+			defer s.Close()
+			config.Cfg = config.Config{
+				DockerRegistry:           "docker.example.com",
+				VulcanChecksRepo:         "vulcan-checks",
+				DockerAPIBaseURL:         s.URL,
+				DockerAPIBaseExtendedURL: s.URL,
+				DockerRegistryUser:       "user",
+				DockerRegistryPwd:        "pwd",
+			}
+			config.Cfg.SigningKey, config.Cfg.SigningPublicKey = writeSigningKeys(t, t.TempDir())
+			if tt.sign {
+				i := checkImageInfo{
+					checktypeName: "vulcan-tls",
+					imageName:     buildImageName("vulcan-tls", "3"),
+					commit:        tt.commit,
+					digest:        reg.digest("3"),
+					tag:           "3",
+				}
+				if err := signImage(i); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := util.FetchArtifact("vulcan-tls", util.ArtifactTag(i.digest, util.SignatureTagSuffix)); err != nil {
+					t.Errorf("signature not pushed to the registry: %v", err)
+				}
+			}
+			err := verifyImage("vulcan-tls", "3")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_publishChecksRequireSignature(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	reg := newFakeRegistry([]string{"3"}, map[string]map[string]string{"3": {"commit": "a1b2c3d"}})
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	p := buildFakePersistence("{}", 200)
+	defer p.Close()
+	config.Cfg = config.Config{
+		DockerRegistry:           "docker.example.com",
+		VulcanChecksRepo:         "vulcan-checks",
+		DockerAPIBaseURL:         s.URL,
+		DockerAPIBaseExtendedURL: s.URL,
+		DockerRegistryUser:       "user",
+		DockerRegistryPwd:        "pwd",
+		RequireSignature:         true,
+	}
+	_, config.Cfg.SigningPublicKey = writeSigningKeys(t, t.TempDir())
+	if err := publishChecks(p.URL); err == nil {
+		t.Error("publishChecks() got no error, want an error for the image not signed")
+	}
+}
+
+func Test_imageBaseName(t *testing.T) {
+	tests := []struct {
+		name      string
+		imageName string
+		want      string
+	}{
+		{
+			name:      "FullName",
+			imageName: "docker.example.com/vulcan-checks/vulcan-nessus-experimental:3",
+			want:      "vulcan-nessus-experimental",
+		},
+		{
+			name:      "NoTag",
+			imageName: "docker.example.com/vulcan-checks/vulcan-nessus",
+			want:      "vulcan-nessus",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageBaseName(tt.imageName); got != tt.want {
+				t.Errorf("imageBaseName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
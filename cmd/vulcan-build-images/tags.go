@@ -0,0 +1,187 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+var (
+	// tagLeaseTTL is the duration of the leases on the tags of the checks,
+	// 0 if the leases are disabled.
+	tagLeaseTTL time.Duration
+
+	// leaseSettleTime is the time waited after pushing a lease before
+	// checking if another pipeline pushed its lease at the same time.
+	leaseSettleTime = 5 * time.Second
+	// leasePollInterval is the time waited between checks of a lease held
+	// by another pipeline.
+	leasePollInterval = 15 * time.Second
+)
+
+func parseTagLeaseTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid tag_lease_ttl %q, it must be a positive duration, e.g. 10m", ttl)
+	}
+	return d, nil
+}
+
+// pushImage pushes an image with its version and extra tags. The image is
+// pushed holding the lease on the tags of its check, if enabled, after
+// verifying that its version tag doesn't collide with an existing one.
+func pushImage(i *checkImageInfo) error {
+	release, err := acquireTagLease(*i)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := resolveTagCollision(i); err != nil {
+		return err
+	}
+	l := i.log()
+	l.Info("Pushing image", "image", i.imageName)
+	_, digest, err := util.PushImage(i.imageName, l)
+	if err != nil {
+		return err
+	}
+	i.digest = digest
+	l.Info("Docker image pushed", "image", i.imageName, "digest", i.digest)
+	for _, name := range i.imageNames()[1:] {
+		if _, _, err := util.PushImage(name, l); err != nil {
+			return err
+		}
+		l.Info("Docker image pushed", "image", name)
+	}
+	return nil
+}
+
+// resolveTagCollision verifies, right before pushing an image, that its
+// version tag doesn't exist in the registry, as a concurrent pipeline may
+// have pushed it after the images to build were detected. An existing tag of
+// an image built from the same commit is not a collision. Otherwise, if the
+// tag collision policy is bump, the image is tagged with the next free
+// version, else an error is returned.
+func resolveTagCollision(i *checkImageInfo) error {
+	info, err := util.FetchImagesInfo(i.checktypeName)
+	if err != nil {
+		return err
+	}
+	if imagetag.CheckCollision(info.Tags, i.tag) == nil {
+		return nil
+	}
+	existing, err := util.FetchImageTagInfo(i.checktypeName, i.tag)
+	if err != nil {
+		return err
+	}
+	if existing.Commit == i.commit {
+		i.log().Info("The tag already exists with an image of the same commit", "commit", i.commit)
+		return nil
+	}
+	if config.Cfg.TagCollision != imagetag.CollisionBump {
+		return fmt.Errorf("can not push the image %s, the tag already exists with an image of the commit %s", i.imageName, existing.Commit)
+	}
+	tag, err := imagetag.NextFree(config.Cfg.TagStrategy, info.Tags, i.tag)
+	if err != nil {
+		return err
+	}
+	name := i.repository() + ":" + tag
+	if err := util.TagImage(i.imageName, name); err != nil {
+		return err
+	}
+	i.log().Warn("The tag already exists with an image of another commit, using the next free tag", "commit", existing.Commit,
+		"next_tag", tag)
+	i.imageName, i.tag = name, tag
+	return nil
+}
+
+// acquireTagLease waits until the lease on the tags of the check of an image
+// is not held by another pipeline and takes it. It returns a function that
+// releases the lease. If the leases are disabled it does nothing. The lease
+// is best effort, see imagetag.Lease, so the images are still pushed after
+// verifying their version tags with resolveTagCollision.
+func acquireTagLease(i checkImageInfo) (func(), error) {
+	if tagLeaseTTL == 0 {
+		return func() {}, nil
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	holder := i.commit + "-" + hex.EncodeToString(id)
+	deadline := time.Now().Add(tagLeaseTTL)
+	for {
+		l, err := currentTagLease(i.checktypeName)
+		if err != nil {
+			return nil, err
+		}
+		if !l.Active(time.Now()) {
+			if err := pushTagLease(i.checktypeName, imagetag.Lease{Holder: holder, Expires: time.Now().Add(tagLeaseTTL)}); err != nil {
+				return nil, err
+			}
+			// Another pipeline can have pushed its lease at the same time,
+			// the last one pushed wins.
+			time.Sleep(leaseSettleTime)
+			if l, err = currentTagLease(i.checktypeName); err != nil {
+				return nil, err
+			}
+			if l.Holder == holder {
+				i.log().Info("Lease on the tags acquired", "holder", holder)
+				return func() { releaseTagLease(i, holder) }, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for the lease on the tags of %s held by %s", i.checktypeName, l.Holder)
+		}
+		i.log().Info("Waiting for the lease on the tags", "holder", l.Holder, "expires", l.Expires)
+		time.Sleep(leasePollInterval)
+	}
+}
+
+// currentTagLease returns the lease on the tags of a check stored in the
+// registry, an inactive one if there is none.
+func currentTagLease(checktypeName string) (imagetag.Lease, error) {
+	a, err := util.FetchArtifact(checktypeName, imagetag.LeaseTag)
+	if errors.Is(err, util.ErrArtifactNotFound) {
+		return imagetag.Lease{}, nil
+	}
+	if err != nil {
+		return imagetag.Lease{}, err
+	}
+	l, _ := imagetag.ParseLease(a.Content)
+	return l, nil
+}
+
+// pushTagLease pushes a lease on the tags of a check to the registry, as an
+// artifact tagged with the lease tag, replacing the current one.
+func pushTagLease(checktypeName string, l imagetag.Lease) error {
+	content, err := l.Marshal()
+	if err != nil {
+		return err
+	}
+	return util.PushArtifact(checktypeName, imagetag.LeaseTag, util.Artifact{MediaType: imagetag.LeaseMediaType, Content: content})
+}
+
+// releaseTagLease releases a lease by pushing it with the current time as
+// expiration. An error releasing the lease is only logged, as the lease
+// expires anyway.
+func releaseTagLease(i checkImageInfo, holder string) {
+	if err := pushTagLease(i.checktypeName, imagetag.Lease{Holder: holder, Expires: time.Now()}); err != nil {
+		i.log().Warn("Error releasing the lease on the tags", "error", err)
+		return
+	}
+	i.log().Info("Lease on the tags released")
+}
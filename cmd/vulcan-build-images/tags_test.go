@@ -0,0 +1,293 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+)
+
+// fakeRegistry is a registry of the images of a check. It answers the queries
+// of the tags of the image, of the labels of each tag and of the digests of
+// their manifests, and stores in memory the manifests and the blobs pushed to
+// it. The manifests pushed with a new tag get the labels of the tags that
+// point to the same manifest.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	tags      []string
+	labels    map[string]map[string]string
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func newFakeRegistry(tags []string, labels map[string]map[string]string) *fakeRegistry {
+	f := &fakeRegistry{
+		tags:      tags,
+		labels:    make(map[string]map[string]string),
+		manifests: make(map[string][]byte),
+		blobs:     make(map[string][]byte),
+	}
+	for t, l := range labels {
+		f.labels[t] = l
+	}
+	for _, t := range tags {
+		f.manifests[t] = []byte(fmt.Sprintf(`{"schemaVersion":2,"tag":%q}`, t))
+	}
+	return f
+}
+
+// buildFakeRegistry returns a server that answers the queries of the tags of
+// an image and of the labels of each tag.
+func buildFakeRegistry(tags []string, labels map[string]map[string]string) *httptest.Server {
+	return httptest.NewServer(newFakeRegistry(tags, labels))
+}
+
+// digest returns the digest of the manifest of a tag.
+func (f *fakeRegistry) digest(tag string) string {
+	return digest.FromBytes(f.manifests[tag]).String()
+}
+
+// ref returns the tag of a reference to a manifest, that is a tag or a
+// digest.
+func (f *fakeRegistry) ref(ref string) (string, bool) {
+	if _, ok := f.manifests[ref]; ok {
+		return ref, true
+	}
+	for t := range f.manifests {
+		if f.digest(t) == ref {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p := r.URL.Path
+	switch {
+	case strings.HasSuffix(p, "/_catalog"):
+		json.NewEncoder(w).Encode(map[string]interface{}{"repositories": []string{"vulcan-checks/vulcan-tls"}}) // nolint: errcheck
+	case strings.HasSuffix(p, "/tags/list"):
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": "vulcan-checks/vulcan-tls", "tags": f.tags}) // nolint: errcheck
+	case strings.HasSuffix(p, "/manifest.json"):
+		// e.g.: /vulcan-tls/3/manifest.json
+		parts := strings.Split(strings.Trim(p, "/"), "/")
+		props := make(map[string][]string)
+		for k, v := range f.labels[parts[len(parts)-2]] {
+			props["docker.label."+k] = []string{v}
+		}
+		w.Header().Set("Last-Modified", "Wed, 25 May 2017 14:25:03 GMT")
+		json.NewEncoder(w).Encode(map[string]interface{}{"properties": props}) // nolint: errcheck
+	case strings.Contains(p, "/manifests/") && r.Method == http.MethodPut:
+		tag := path.Base(p)
+		content, _ := io.ReadAll(r.Body)
+		f.manifests[tag] = content
+		if !slices.Contains(f.tags, tag) {
+			f.tags = append(f.tags, tag)
+		}
+		for t := range f.manifests {
+			if t != tag && f.labels[tag] == nil && f.digest(t) == f.digest(tag) {
+				f.labels[tag] = f.labels[t]
+			}
+		}
+		w.Header().Set("Docker-Content-Digest", f.digest(tag))
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(p, "/manifests/"):
+		tag, ok := f.ref(path.Base(p))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", specs.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", f.digest(tag))
+		w.Write(f.manifests[tag]) // nolint: errcheck
+	case strings.HasSuffix(p, "/blobs/uploads/") && r.Method == http.MethodPost:
+		w.Header().Set("Location", "upload")
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(p, "/blobs/uploads/") && r.Method == http.MethodPut:
+		content, _ := io.ReadAll(r.Body)
+		f.blobs[r.URL.Query().Get("digest")] = content
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(p, "/blobs/"):
+		content, ok := f.blobs[path.Base(p)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content) // nolint: errcheck
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func Test_resolveTagCollision(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	tests := []struct {
+		name      string
+		tags      []string
+		labels    map[string]map[string]string
+		policy    string
+		wantImage string
+		wantErr   bool
+	}{
+		{
+			name:      "NoCollision",
+			tags:      []string{"1", "2"},
+			wantImage: "docker.example.com/vulcan-checks/vulcan-tls:3",
+		},
+		{
+			name:      "SameCommit",
+			tags:      []string{"2", "3"},
+			labels:    map[string]map[string]string{"3": {"commit": "a1b2c3d"}},
+			wantImage: "docker.example.com/vulcan-checks/vulcan-tls:3",
+		},
+		{
+			name:    "OtherCommit",
+			tags:    []string{"2", "3"},
+			labels:  map[string]map[string]string{"3": {"commit": "0f1e2d3"}},
+			policy:  imagetag.CollisionFail,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := buildFakeRegistry(tt.tags, tt.labels)
+			defer s.Close()
+			config.Cfg.DockerAPIBaseURL = s.URL
+			config.Cfg.DockerAPIBaseExtendedURL = s.URL
+			config.Cfg.DockerRegistryUser = "user"
+			config.Cfg.DockerRegistryPwd = "pwd"
+			config.Cfg.TagCollision = tt.policy
+			i := checkImageInfo{
+				checktypeName: "vulcan-tls",
+				imageName:     "docker.example.com/vulcan-checks/vulcan-tls:3",
+				tag:           "3",
+				commit:        "a1b2c3d",
+			}
+			err := resolveTagCollision(&i)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTagCollision() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && i.imageName != tt.wantImage {
+				t.Errorf("resolveTagCollision() got image %s, want %s", i.imageName, tt.wantImage)
+			}
+		})
+	}
+}
+
+func Test_currentTagLease(t *testing.T) {
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	lease := imagetag.Lease{Holder: "0f1e2d3-abcd", Expires: expires}
+	tests := []struct {
+		name string
+		push bool
+		want imagetag.Lease
+	}{
+		{
+			name: "NoLease",
+		},
+		{
+			name: "Lease",
+			push: true,
+			want: lease,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := buildFakeRegistry([]string{"1"}, nil)
+			defer s.Close()
+			config.Cfg.DockerAPIBaseURL = s.URL
+			config.Cfg.DockerAPIBaseExtendedURL = s.URL
+			config.Cfg.DockerRegistryUser = "user"
+			config.Cfg.DockerRegistryPwd = "pwd"
+			if tt.push {
+				if err := pushTagLease("vulcan-tls", lease); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := currentTagLease("vulcan-tls")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Holder != tt.want.Holder || !got.Expires.Equal(tt.want.Expires) {
+				t.Errorf("currentTagLease() got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_acquireTagLease(t *testing.T) {
+	defer func(c config.Config, ttl, settle time.Duration) {
+		config.Cfg, tagLeaseTTL, leaseSettleTime = c, ttl, settle
+	}(config.Cfg, tagLeaseTTL, leaseSettleTime)
+	s := buildFakeRegistry([]string{"1"}, nil)
+	defer s.Close()
+	config.Cfg.DockerAPIBaseURL = s.URL
+	config.Cfg.DockerAPIBaseExtendedURL = s.URL
+	config.Cfg.DockerRegistryUser = "user"
+	config.Cfg.DockerRegistryPwd = "pwd"
+	tagLeaseTTL, leaseSettleTime = time.Minute, 0
+
+	i := checkImageInfo{checktypeName: "vulcan-tls", commit: "a1b2c3d"}
+	release, err := acquireTagLease(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := currentTagLease("vulcan-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Active(time.Now()) || !strings.HasPrefix(l.Holder, "a1b2c3d-") {
+		t.Errorf("got lease %+v, want an active lease held by the commit a1b2c3d", l)
+	}
+	release()
+	if l, err = currentTagLease("vulcan-tls"); err != nil {
+		t.Fatal(err)
+	}
+	if l.Active(time.Now()) {
+		t.Errorf("got active lease %+v after releasing it", l)
+	}
+}
+
+func Test_parseTagLeaseTTL(t *testing.T) {
+	tests := []struct {
+		ttl     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{ttl: "", want: 0},
+		{ttl: "10m", want: 10 * time.Minute},
+		{ttl: "-1m", wantErr: true},
+		{ttl: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.ttl), func(t *testing.T) {
+			got, err := parseTagLeaseTTL(tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTagLeaseTTL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTagLeaseTTL() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
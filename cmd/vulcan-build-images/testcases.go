@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	sdkconfig "github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-checks-bsys/checktest"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+)
+
+// runTestCases builds the image of the check in imagePath and runs it for
+// each of the test cases defined in the check dir, comparing the reports
+// generated with the expected ones. It returns an error if any of the test
+// cases fails.
+func runTestCases(imagePath string) error {
+	cases, err := checktest.Read(path.Join(imagePath, checktest.File))
+	if err != nil {
+		return err
+	}
+	for _, tc := range cases {
+		if err = validateTarget(tc.Target, tc.AssetType); err != nil {
+			return fmt.Errorf("invalid target of the test case %s: %w", tc.Name, err)
+		}
+	}
+	m, err := manifest.Read(path.Join(imagePath, manifestFileName))
+	if err != nil {
+		return err
+	}
+	local, err := loadLocalConfig(imagePath)
+	if err != nil {
+		return err
+	}
+	imageName, err := forceBuild(imagePath)
+	if err != nil {
+		return err
+	}
+
+	var results []checktest.Result
+	for _, tc := range cases {
+		l := logger.With("check", imageName, "test_case", tc.Name)
+		l.Info("Running test case")
+		res := checktest.Result{Case: tc.Name}
+		c, timeout, err := testCaseConfig(tc, m, local)
+		if err != nil {
+			return fmt.Errorf("test case %s: %w", tc.Name, err)
+		}
+		r, err := runCheckReport(imageName, c, timeout)
+		if err != nil {
+			res.Err = err
+			l.Error("Test case failed, error running the check", "error", err)
+		} else if res.Diff = tc.Diff(r); res.Diff != "" {
+			l.Error("Test case failed, report mismatch", "diff", res.Diff)
+		} else {
+			l.Info("Test case passed")
+		}
+		results = append(results, res)
+	}
+
+	failed := checktest.Failed(results)
+	logger.Info("Test cases finished", "check", imageName, "passed", len(results)-len(failed), "failed", len(failed))
+	if len(failed) > 0 {
+		return fmt.Errorf("test cases failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// loadLocalConfig reads the local.toml of a check, if present. The required
+// vars defined in it, that are usually secrets not stored in the repo, are
+// passed to all the test cases.
+func loadLocalConfig(imagePath string) (*sdkconfig.Config, error) {
+	cpath := path.Join(imagePath, "local.toml")
+	if _, err := os.Stat(cpath); err != nil {
+		return &sdkconfig.Config{}, nil
+	}
+	return sdkconfig.LoadConfigFromFile(cpath)
+}
+
+// testCaseConfig returns the config and the timeout used to run the check
+// for a test case. The options of the test case are set in the ones of the
+// manifest for the asset type of the test case, and the timeout is the one of
+// the manifest for that asset type.
+func testCaseConfig(tc checktest.Case, m manifest.Data, local *sdkconfig.Config) (*sdkconfig.Config, time.Duration, error) {
+	m, err := m.ForAssetType(tc.AssetType)
+	if err != nil {
+		return nil, 0, err
+	}
+	options, err := manifest.MergeOptions(m.Options, tc.Options)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid options: %w", err)
+	}
+	c := &sdkconfig.Config{
+		Log:             local.Log,
+		AllowPrivateIPs: local.AllowPrivateIPs,
+		RequiredVars:    make(map[string]string),
+	}
+	c.Check.Target = tc.Target
+	c.Check.AssetType = tc.AssetType
+	c.Check.Opts = options
+	for k, v := range local.RequiredVars {
+		c.RequiredVars[k] = v
+	}
+	for k, v := range tc.RequiredVars {
+		c.RequiredVars[k] = v
+	}
+	return c, time.Duration(m.Timeout) * time.Second, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/junit"
+	"github.com/adevinta/vulcan-checks-bsys/util"
+)
+
+const (
+	testPolicyFail = "fail"
+	testPolicyWarn = "warn"
+)
+
+// testCheck runs the go tests of the check in imagePath and writes its JUnit
+// report to the test reports dir, if any. Depending on the test policy, a
+// failure of the tests is returned as an error or only logged.
+func testCheck(imagePath string) error {
+	if skipTests {
+		logger.Info("Skipping the tests", "dir", imagePath)
+		return nil
+	}
+	policy := config.Cfg.TestPolicy
+	if policy == "" {
+		policy = testPolicyFail
+	}
+	if policy != testPolicyFail && policy != testPolicyWarn {
+		return fmt.Errorf("invalid test policy %q", policy)
+	}
+
+	name := path.Base(imagePath)
+	dir := config.Cfg.TestReportsDir
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	opts := util.GoTestOptions{
+		Race:  config.Cfg.TestRace,
+		Cover: config.Cfg.TestCoverage,
+	}
+	if opts.Cover && dir != "" {
+		opts.CoverProfile = filepath.Join(dir, name+".cover.out")
+	}
+
+	logger.Info("Running go test", "dir", imagePath)
+	out, testErr := util.GoTestDir(imagePath, opts)
+	report, err := junit.Parse(bytes.NewReader(out))
+	if err != nil {
+		return fmt.Errorf("error parsing the output of the tests of dir %s: %w", imagePath, err)
+	}
+	for pkg, cov := range report.Coverage() {
+		logger.Info("Coverage", "dir", imagePath, "package", pkg, "coverage", cov)
+	}
+	if dir != "" {
+		if err = writeJUnitReport(filepath.Join(dir, name+".junit.xml"), report); err != nil {
+			return err
+		}
+	}
+
+	if testErr == nil && report.Failures == 0 {
+		logger.Info("Tests passed", "dir", imagePath, "tests", report.Tests, "skipped", report.Skipped)
+		return nil
+	}
+	err = fmt.Errorf("tests of dir %s failed: %d of %d tests failed", imagePath, report.Failures, report.Tests)
+	if testErr != nil && report.Failures == 0 {
+		err = fmt.Errorf("error running the tests of dir %s: %w", imagePath, testErr)
+	}
+	if policy == testPolicyWarn {
+		logger.Warn("Tests failed", "dir", imagePath, "error", err)
+		return nil
+	}
+	return err
+}
+
+func writeJUnitReport(file string, report junit.TestSuites) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = report.Write(f); err != nil {
+		f.Close() // nolint: errcheck
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/vulngate"
+)
+
+// newVulnGate returns the vulnerability gate defined in the config, or nil if
+// no vulnerability database is configured. The default severity threshold is
+// HIGH.
+func newVulnGate() (*vulngate.Gate, error) {
+	if config.Cfg.VulnDB == "" {
+		return nil, nil
+	}
+	db, err := vulngate.LoadDB(config.Cfg.VulnDB)
+	if err != nil {
+		return nil, err
+	}
+	g := &vulngate.Gate{DB: db, Threshold: vulngate.SeverityHigh}
+	if config.Cfg.VulnSeverityThreshold != "" {
+		if g.Threshold, err = vulngate.ParseSeverity(config.Cfg.VulnSeverityThreshold); err != nil {
+			return nil, err
+		}
+	}
+	if config.Cfg.VulnAllowlist != "" {
+		if g.Allowlist, err = vulngate.LoadAllowlist(config.Cfg.VulnAllowlist); err != nil {
+			return nil, fmt.Errorf("error reading the vulnerability allowlist: %w", err)
+		}
+	}
+	return g, nil
+}
+
+// checkVulns scans the SBOM of a built image and logs its findings.
+func checkVulns(g vulngate.Gate, i checkImageInfo) vulngate.Result {
+	r := g.Check(i.checktypeName, *i.sbom)
+	for _, f := range r.Findings {
+		status := "blocking"
+		if f.Accepted != "" {
+			status = "accepted: " + f.Accepted
+		} else if f.Severity < g.Threshold {
+			status = "below threshold"
+		}
+		i.log().Info("Vulnerability found", "id", f.ID, "severity", f.Severity.String(), "component", f.Component,
+			"version", f.Version, "status", status)
+	}
+	return r
+}
+
+// blockedImagesError returns an error listing the images not pushed because
+// of their vulnerabilities, if any.
+func blockedImagesError(images []checkImageInfo) error {
+	var blocked []string
+	for _, i := range images {
+		if i.vulns != nil && !i.vulns.Passed() {
+			blocked = append(blocked, i.imageName)
+		}
+	}
+	if len(blocked) == 0 {
+		return nil
+	}
+	return fmt.Errorf("images not pushed because of their vulnerabilities: %s", strings.Join(blocked, ", "))
+}
@@ -7,14 +7,18 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 
+	"github.com/adevinta/vulcan-checks-bsys/ci"
 	"github.com/adevinta/vulcan-checks-bsys/config"
+	"github.com/adevinta/vulcan-checks-bsys/imagetag"
+	"github.com/adevinta/vulcan-checks-bsys/logging"
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/naming"
 	"github.com/adevinta/vulcan-checks-bsys/util"
 )
 
@@ -23,36 +27,60 @@ const (
 If the config file path is not specified it defaults to ~/.vulcan-checks-bsys.toml
 baseDirPath must be relative to the git repo.`
 
-	buildBranchEnvVar  = "TRAVIS_BRANCH"
 	forceBuildEnvVar   = "FORCE_BUILD"
 	forceBuildAllToken = "ALL"
-	prodBranchName     = "master"
-	imgNameDevSuffix   = "experimental"
 	configFlagUsage    = "Path to the configuration file"
+	logFormatFlagUsage = "Format of the logs: text or json"
+	logLevelFlagUsage  = "Minimum level of the logs: debug, info, warn or error"
+	quietFlagUsage     = "Only logs the warnings and the errors"
+	manifestFileName   = "manifest.toml"
 )
 
 var (
-	logger          *log.Logger
-	logWriter       = os.Stdout
+	logger          *slog.Logger
+	logWriter       = os.Stderr
 	cfg             string
+	logFormat       string
+	logLevel        string
+	quiet           bool
 	forceBuildImage = ""
 )
 
 func init() {
 	forceBuildImage = os.Getenv(forceBuildEnvVar)
-	logger = log.New(
-		logWriter,
-		"detect-images",
-		log.Lshortfile,
-	)
+	logger = slog.New(slog.NewTextHandler(logWriter, nil))
 }
 
 func main() {
 	flag.StringVar(&cfg, "c", "", configFlagUsage)
+	flag.StringVar(&logFormat, "log-format", logging.FormatText, logFormatFlagUsage)
+	flag.StringVar(&logLevel, "log-level", "info", logLevelFlagUsage)
+	flag.BoolVar(&quiet, "quiet", false, quietFlagUsage)
 	flag.Parse()
-	err := config.LoadFrom(cfg)
+	l, err := logging.New(logWriter, logging.Options{Format: logFormat, Level: logLevel, Quiet: quiet})
 	if err != nil {
-		logger.Fatal(err)
+		logging.Fatal(logger, "Invalid log flags", err)
+	}
+	logger = l.With("cmd", "vulcan-detect-images")
+	slog.SetDefault(logger)
+	err = config.LoadFrom(cfg)
+	if err != nil {
+		logging.Fatal(logger, "Error loading the config", err)
+	}
+	if err = imagetag.Validate(config.Cfg.TagStrategy, config.Cfg.ExtraTags); err != nil {
+		logging.Fatal(logger, "Invalid tag config", err)
+	}
+	if config.Cfg.DevNameTemplate != "" {
+		if err = naming.ValidateTemplate(config.Cfg.DevNameTemplate); err != nil {
+			logging.Fatal(logger, "Invalid dev name template", err)
+		}
+	}
+	// The manifests are read to get their Version when tagging with
+	// semantic versions, so they can contain the asset types of the config.
+	for _, a := range config.Cfg.AssetTypes {
+		if _, err = manifest.RegisterAssetTypePattern(a.Name, a.Description, a.TargetPattern); err != nil {
+			logging.Fatal(logger, "Invalid asset type in the config", err)
+		}
 	}
 	if len(flag.Args()) < 2 {
 		fmt.Println(usage)
@@ -61,27 +89,23 @@ func main() {
 	args := flag.Args()
 	baseDir := args[0]
 	resultFilePath := args[1]
+	env := ci.Detect()
+	logger.Info("Build detected", "provider", env.Provider, "branch", env.Branch, "commit", env.Commit, "pull_request", env.PullRequest)
 	if forceBuildImage == "" {
-		err = detectImages(baseDir, resultFilePath, false)
+		err = detectImages(baseDir, resultFilePath, env, false)
 	} else if forceBuildImage == forceBuildAllToken {
-		logger.Print("Rebuilding all images")
-		err = detectImages(baseDir, resultFilePath, true)
+		logger.Info("Rebuilding all images")
+		err = detectImages(baseDir, resultFilePath, env, true)
 	} else {
-		err = forceDetectOneImage(baseDir, resultFilePath, forceBuildImage)
+		err = forceDetectOneImage(baseDir, resultFilePath, forceBuildImage, env)
 	}
 
 	if err != nil {
-		logger.Fatal(err)
+		logging.Fatal(logger, "Error detecting the images to build", err)
 	}
 }
 
-func forceDetectOneImage(baseDir, resultFilePath, imageName string) error {
-	branchName := os.Getenv(buildBranchEnvVar)
-	logger.Printf("Build branch name: %s", branchName)
-	env := ""
-	if branchName != prodBranchName {
-		env = imgNameDevSuffix
-	}
+func forceDetectOneImage(baseDir, resultFilePath, imageName string, env ci.Env) error {
 	f, err := os.Open(baseDir)
 	if err != nil {
 		return err
@@ -110,18 +134,12 @@ func forceDetectOneImage(baseDir, resultFilePath, imageName string) error {
 		return err
 	}
 	result := strings.Join(dirs, "\n")
-	logger.Printf("Image to build:\n%v", result)
+	logger.Info("Image to build", "images", dirs)
 	return os.WriteFile(resultFilePath, []byte(result), 0644)
 
 }
 
-func detectImages(baseDir, resultFilePath string, force bool) error {
-	branchName := os.Getenv(buildBranchEnvVar)
-	logger.Printf("Build branch name: %s", branchName)
-	env := ""
-	if branchName != prodBranchName {
-		env = imgNameDevSuffix
-	}
+func detectImages(baseDir, resultFilePath string, env ci.Env, force bool) error {
 	dirs, err := getDirsUnder(baseDir)
 	if err != nil {
 		return err
@@ -131,7 +149,7 @@ func detectImages(baseDir, resultFilePath string, force bool) error {
 	if err != nil {
 		return err
 	}
-	logger.Printf("commitInfos:\n%+v", commitInfos)
+	logger.Debug("Last commits of the check dirs", "commits", commitInfos)
 
 	dirs, err = getImagesToBuild(commitInfos, env, force)
 	if err != nil {
@@ -139,7 +157,7 @@ func detectImages(baseDir, resultFilePath string, force bool) error {
 	}
 
 	result := strings.Join(dirs, "\n")
-	logger.Printf("Images to build:\n%v", result)
+	logger.Info("Images to build", "images", dirs)
 	return os.WriteFile(resultFilePath, []byte(result), 0644)
 }
 func getDirsUnder(dir string) (dirs []string, err error) {
@@ -162,18 +180,18 @@ func getDirsUnder(dir string) (dirs []string, err error) {
 	return
 }
 
-func getImagesToBuild(dirsInfo []util.DirLastCommmit, env string, force bool) (dirs []string, err error) {
+func getImagesToBuild(dirsInfo []util.DirLastCommmit, env ci.Env, force bool) (dirs []string, err error) {
 	sdkVer, err := util.GetCurrentSDKVersion()
 	if err != nil {
 		return nil, fmt.Errorf("Error getting current sdk version.Details: %v", err)
 	}
-	logger.Printf("SDK version: %s", sdkVer)
+	logger.Info("SDK version", "sdk_version", sdkVer)
 
 	for _, dirInfo := range dirsInfo {
 		// The imgName start value is the name of the directory of the last commit.
-		imgName := path.Base(dirInfo.Path)
-		if env != "" {
-			imgName = fmt.Sprintf("%s-%s", imgName, env)
+		imgName, err := imageName(path.Base(dirInfo.Path), env)
+		if err != nil {
+			return nil, err
 		}
 
 		imgInfo, err := util.FetchImagesInfo(imgName)
@@ -181,31 +199,70 @@ func getImagesToBuild(dirsInfo []util.DirLastCommmit, env string, force bool) (d
 			return nil, err
 		}
 
-		tag, found := util.GetLatestTag(imgInfo.Tags)
+		tag, found := imagetag.Latest(config.Cfg.TagStrategy, imgInfo.Tags)
+		latestCommit := ""
 		if found {
 			// NOTE: This can be improved!!. We don't need to fetch image info when force is true.
 			imageInfo, err := util.FetchImageTagInfo(imgInfo.Name, tag)
 			if err != nil {
 				return nil, err
 			}
-			if imageInfo.Commit != dirInfo.Commit || imageInfo.SDKVersion != sdkVer || force {
-				dirs = append(dirs, dirInfo.Path+":"+incrementTagVersion(tag)+":"+dirInfo.Commit)
+			if imageInfo.Commit == dirInfo.Commit && imageInfo.SDKVersion == sdkVer && !force {
+				continue
 			}
-		} else {
-			dirs = append(dirs, dirInfo.Path+":"+incrementTagVersion(tag)+":"+dirInfo.Commit)
+			latestCommit = imageInfo.Commit
+		}
+		next, err := nextTag(dirInfo, tag, found, latestCommit)
+		if err != nil {
+			return nil, err
+		}
+		// Never overwrite an existing image, e.g. when the Version of the
+		// manifest has been lowered.
+		if err = imagetag.CheckCollision(imgInfo.Tags, next); err != nil {
+			return nil, fmt.Errorf("can not tag the image %s: %w", imgName, err)
 		}
+		dirs = append(dirs, dirInfo.Path+":"+next+":"+dirInfo.Commit)
 	}
 
 	return dirs, nil
 }
 
-func incrementTagVersion(tag string) string {
-	v, err := strconv.ParseInt(tag, 0, 64)
+// imageName returns the name of the image of a check built in a build env:
+// the name of the check for the production branch, or the one generated by
+// the dev name template of the config for the other branches and the pull
+// requests.
+func imageName(check string, env ci.Env) (string, error) {
+	if env.IsProduction(config.Cfg.ProductionBranch) {
+		return check, nil
+	}
+	return naming.DevName(config.Cfg.DevNameTemplate, check, env.Branch)
+}
+
+// nextTag returns the tag of the next image of a check dir according to the
+// tag strategy of the config. The latest tag is the one of the latest image
+// of the check, if found, that was built from the latestCommit.
+func nextTag(dirInfo util.DirLastCommmit, latest string, found bool, latestCommit string) (string, error) {
+	if config.Cfg.TagStrategy != imagetag.StrategySemver {
+		return imagetag.NextInteger(latest)
+	}
+	m, err := manifest.Read(filepath.Join(dirInfo.Path, manifestFileName))
+	if err != nil {
+		return "", err
+	}
+	var messages []string
+	if found && m.Version == "" {
+		messages, err = util.GetCommitMessagesForDirInRepo(dirInfo.Path, latestCommit, "")
+		if err != nil {
+			// The commit of the latest image may not be in the history of
+			// the branch, e.g. after a force push.
+			logger.Warn("Incrementing the patch version", "check", path.Base(dirInfo.Path), "error", err)
+		}
+	}
+	v, err := imagetag.NextVersion(latest, found, m.Version, messages)
 	if err != nil {
-		return "0"
+		return "", fmt.Errorf("can not get the next version of %s: %w", dirInfo.Path, err)
 	}
-	v++
-	return strconv.FormatInt(v, 10)
+	return v.String(), nil
 }
 
 func getLastCommitForDirs(dirs []string) (commitInfos []util.DirLastCommmit, err error) {
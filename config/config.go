@@ -27,11 +27,108 @@ type Config struct {
 	DockerRegistry           string `toml:"docker_registry_pwd"`
 	VulcanChecksRepo         string `toml:"vulcan_checks_repo"`
 
+	// ProductionBranch is the branch whose builds are published to the
+	// master branch envs, master by default. The builds of the other
+	// branches and of the pull requests are published to the dev branch
+	// envs.
+	ProductionBranch string `toml:"production_branch"`
+
 	PrimaryMasterBranchEnvs   []string `toml:"primary_master_branch_envs"`
 	SecondaryMasterBranchEnvs []string `toml:"secondary_master_branch_envs"`
 
 	PrimaryDevBranchEnvs   []string `toml:"primary_dev_branch_envs"`
 	SecondaryDevBranchEnvs []string `toml:"secondary_dev_branch_envs"`
+
+	// MultiStageBuild makes the build system compile the checks inside the
+	// docker build, using GoBuilderImage, instead of in the host.
+	MultiStageBuild bool   `toml:"multi_stage_build"`
+	GoBuilderImage  string `toml:"go_builder_image"`
+
+	// PinImageDigest makes the build system publish the checktypes with
+	// image references that include the digest of the image pushed.
+	PinImageDigest bool `toml:"pin_image_digest"`
+
+	// SigningKey is the path of the private key, in a format supported by
+	// cosign, used to sign the images and their provenance attestations.
+	// The signatures are pushed to the registry, next to the images, and
+	// verified with the SigningPublicKey. A copy of them is written to the
+	// SignaturesDir, if set. If RequireSignature is true, publishing,
+	// promoting or rolling back an image without a valid signature and
+	// provenance fails.
+	SigningKey       string `toml:"signing_key"`
+	SigningPublicKey string `toml:"signing_public_key"`
+	SignaturesDir    string `toml:"signatures_dir"`
+	RequireSignature bool   `toml:"require_signature"`
+	BuilderID        string `toml:"builder_id"`
+
+	// SBOMDir is the directory where the CycloneDX SBOMs of the images built
+	// are written. The SBOMs are also pushed to the registry next to the
+	// images. Leave empty to not generate SBOMs.
+	SBOMDir string `toml:"sbom_dir"`
+
+	// VulnDB is the path of the offline vulnerability database used to scan
+	// the SBOMs of the images built. The images with findings, not accepted
+	// in the VulnAllowlist, of a severity equal or higher than the
+	// VulnSeverityThreshold are not pushed. Leave empty to disable the gate.
+	VulnDB                string `toml:"vuln_db"`
+	VulnAllowlist         string `toml:"vuln_allowlist"`
+	VulnSeverityThreshold string `toml:"vuln_severity_threshold"`
+
+	// TestPolicy defines what happens when the go tests of a check, run
+	// before building its image, fail: "fail", the default, stops the build
+	// and "warn" only logs the failure. The JUnit reports and, if
+	// TestCoverage is true, the coverage profiles are written to the
+	// TestReportsDir.
+	TestPolicy     string `toml:"test_policy"`
+	TestRace       bool   `toml:"test_race"`
+	TestCoverage   bool   `toml:"test_coverage"`
+	TestReportsDir string `toml:"test_reports_dir"`
+
+	// LintRules overrides the default severity of the lint rules: error,
+	// warning, note or off. Unknown rule IDs make the config invalid.
+	// LintQueueNames are the valid queue names of the checks, if empty the
+	// queue names are not linted.
+	LintRules      map[string]string `toml:"lint_rules"`
+	LintQueueNames []string          `toml:"lint_queue_names"`
+
+	// PublishManifestMetadata makes the build system send the metadata of
+	// the manifests, like the owner or the tags of the checks, to the
+	// persistence service when publishing the checktypes.
+	PublishManifestMetadata bool `toml:"publish_manifest_metadata"`
+
+	// AssetTypes are the asset types, besides the built-in ones, that the
+	// checks can accept in their manifests.
+	AssetTypes []AssetTypeConfig `toml:"asset_types"`
+
+	// TagStrategy is the strategy used to tag the images of the checks:
+	// "integer", the default, or "semver". ExtraTags are the kinds of tags,
+	// "latest", "commit" or "branch", added to the images besides the
+	// version tag.
+	TagStrategy string   `toml:"tag_strategy"`
+	ExtraTags   []string `toml:"extra_tags"`
+
+	// TagCollision is what happens when the version tag of an image already
+	// exists, with an image of another commit, right before pushing it:
+	// "fail", the default, or "bump", that tags the image with the next free
+	// version. TagLeaseTTL, e.g. "10m", enables a best effort lease, stored
+	// in the registry, that serializes the pushes of the images of a check
+	// between pipelines. Leave empty to disable the lease.
+	TagCollision string `toml:"tag_collision"`
+	TagLeaseTTL  string `toml:"tag_lease_ttl"`
+
+	// DevNameTemplate is the template of the names of the images and
+	// checktypes of the checks built from branches other than master, e.g.
+	// "{check}-{branch-slug}". The default, "{check}-experimental", shares
+	// the names between all the branches.
+	DevNameTemplate string `toml:"dev_name_template"`
+}
+
+// AssetTypeConfig defines an asset type. The targets of the asset type must
+// match the TargetPattern regular expression, if defined.
+type AssetTypeConfig struct {
+	Name          string `toml:"name"`
+	Description   string `toml:"description"`
+	TargetPattern string `toml:"target_pattern"`
 }
 
 // LoadFrom loads the config from the specified file path.
@@ -0,0 +1,201 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package drift finds the differences between the manifests of the checks in
+// the repo, the manifests of their latest images in the registry and the
+// checktypes published to the persistence services.
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/persistence"
+)
+
+// Check contains the drift of a check.
+type Check struct {
+	Name string `json:"name"`
+	// Image is the latest image of the check in the registry, empty if the
+	// check has no images.
+	Image string `json:"image,omitempty"`
+	// Manifest contains the differences between the manifest of the latest
+	// image, the old values, and the manifest in the repo, the new ones.
+	Manifest []manifest.Change `json:"manifest,omitempty"`
+	Envs     []Env             `json:"envs,omitempty"`
+}
+
+// Drifted returns true if the check has no image in the registry, the
+// manifest of the check in the repo differs from the one of its latest image,
+// or the checktype published to any of the envs differs from the one
+// expected.
+func (c Check) Drifted() bool {
+	if c.Image == "" || len(c.Manifest) > 0 {
+		return true
+	}
+	for _, e := range c.Envs {
+		if e.Drifted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Env contains the drift of the checktype of a check in a persistence
+// service.
+type Env struct {
+	Endpoint string `json:"endpoint"`
+	// Missing is true if the checktype doesn't exist in the env.
+	Missing bool `json:"missing,omitempty"`
+	// Changes contains the differences between the checktype published,
+	// the old values, and the one expected from the latest image, the new
+	// ones.
+	Changes []manifest.Change `json:"changes,omitempty"`
+	// Reconciled is true if the expected checktype has been published to
+	// the env.
+	Reconciled bool `json:"reconciled,omitempty"`
+}
+
+// Drifted returns true if the checktype is missing or differs from the
+// expected one and it has not been reconciled.
+func (e Env) Drifted() bool {
+	return !e.Reconciled && (e.Missing || len(e.Changes) > 0)
+}
+
+// FindChecktype returns the enabled checktype with the given name or, if none
+// is enabled, the last one.
+func FindChecktype(checktypes []persistence.PublishChecktypeResult, name string) (persistence.PublishChecktypeResult, bool) {
+	var (
+		res   persistence.PublishChecktypeResult
+		found bool
+	)
+	for _, ct := range checktypes {
+		if ct.Name != name {
+			continue
+		}
+		if ct.Enabled {
+			return ct, true
+		}
+		res, found = ct, true
+	}
+	return res, found
+}
+
+// CompareChecktype returns the differences between a checktype published to
+// a persistence service and the expected one. The digests of the images are
+// ignored.
+func CompareChecktype(got persistence.PublishChecktypeResult, want persistence.Checktype) []manifest.Change {
+	var changes []manifest.Change
+	if gotImage, wantImage := stripDigest(got.Image), stripDigest(want.Image); gotImage != wantImage {
+		changes = append(changes, manifest.Change{Field: "Image", Old: gotImage, New: wantImage})
+	}
+	gotData, gotErr := checktypeData(got.Description, got.Timeout, options(got.Options), got.RequiredVars, got.QueueName, got.Assets)
+	wantData, wantErr := checktypeData(want.Description, want.Timeout, want.Options, want.RequiredVars, want.QueueName, want.Assets)
+	if gotErr != nil || wantErr != nil {
+		// Some asset types are not registered, so compare them as strings.
+		gotData.AssetTypes, wantData.AssetTypes = nil, nil
+		if strings.Join(got.Assets, ", ") != strings.Join(want.Assets, ", ") {
+			changes = append(changes, manifest.Change{Field: "AssetTypes", Old: strings.Join(got.Assets, ", "), New: strings.Join(want.Assets, ", ")})
+		}
+	}
+	return append(changes, manifest.Diff(gotData, wantData)...)
+}
+
+// checktypeData returns the fields of a checktype as the data of a manifest,
+// so they can be compared with manifest.Diff.
+func checktypeData(description string, timeout int, options string, requiredVars []string, queueName string, assets []string) (manifest.Data, error) {
+	d := manifest.Data{
+		Description:  description,
+		Timeout:      timeout,
+		Options:      options,
+		RequiredVars: requiredVars,
+		QueueName:    queueName,
+	}
+	for _, a := range assets {
+		var at manifest.AssetType
+		if err := at.UnmarshalText([]byte(a)); err != nil {
+			return d, err
+		}
+		d.AssetTypes = append(d.AssetTypes, &at)
+	}
+	return d, nil
+}
+
+// options returns the options of a checktype returned by the persistence
+// service, which can be a string or a json object, as a string.
+func options(o interface{}) string {
+	switch v := o.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	content, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Sprint(o)
+	}
+	return string(content)
+}
+
+func stripDigest(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	return image
+}
+
+// WriteText writes the checks that drifted in a human readable format.
+func WriteText(w io.Writer, checks []Check) error {
+	var b strings.Builder
+	drifted := 0
+	for _, c := range checks {
+		if !c.Drifted() && !reconciled(c) {
+			continue
+		}
+		drifted++
+		image := c.Image
+		if image == "" {
+			image = "no image in the registry"
+		}
+		fmt.Fprintf(&b, "%s (%s)\n", c.Name, image)
+		if len(c.Manifest) > 0 {
+			b.WriteString("  manifest in the repo differs from the latest image:\n")
+			for _, ch := range c.Manifest {
+				fmt.Fprintf(&b, "    %s\n", ch)
+			}
+		}
+		for _, e := range c.Envs {
+			status := ""
+			if e.Reconciled {
+				status = " (reconciled)"
+			}
+			switch {
+			case e.Missing:
+				fmt.Fprintf(&b, "  %s: checktype not published%s\n", e.Endpoint, status)
+			case len(e.Changes) > 0:
+				fmt.Fprintf(&b, "  %s: checktype differs from the latest image%s:\n", e.Endpoint, status)
+				for _, ch := range e.Changes {
+					fmt.Fprintf(&b, "    %s\n", ch)
+				}
+			}
+		}
+	}
+	if drifted == 0 {
+		b.WriteString("No drift found.\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func reconciled(c Check) bool {
+	for _, e := range c.Envs {
+		if e.Reconciled {
+			return true
+		}
+	}
+	return false
+}
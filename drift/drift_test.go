@@ -0,0 +1,148 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package drift
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/persistence"
+)
+
+func TestFindChecktype(t *testing.T) {
+	checktypes := []persistence.PublishChecktypeResult{
+		{ID: "1", Name: "vulcan-nessus"},
+		{ID: "2", Name: "vulcan-nessus", Enabled: true},
+		{ID: "3", Name: "vulcan-nessus"},
+		{ID: "4", Name: "vulcan-tls"},
+		{ID: "5", Name: "vulcan-tls"},
+	}
+	tests := []struct {
+		name      string
+		checktype string
+		wantID    string
+		wantFound bool
+	}{
+		{name: "Enabled", checktype: "vulcan-nessus", wantID: "2", wantFound: true},
+		{name: "LastNotEnabled", checktype: "vulcan-tls", wantID: "5", wantFound: true},
+		{name: "NotFound", checktype: "vulcan-exposed-http"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := FindChecktype(checktypes, tt.checktype)
+			if found != tt.wantFound || got.ID != tt.wantID {
+				t.Errorf("FindChecktype() got %s, %v, want %s, %v", got.ID, found, tt.wantID, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCompareChecktype(t *testing.T) {
+	want := persistence.Checktype{
+		Name:         "vulcan-nessus",
+		Description:  "Runs nessus",
+		Timeout:      3600,
+		Image:        "registry.example.com/vulcan-checks/vulcan-nessus:3",
+		Options:      `{"policy": "basic", "port": 443}`,
+		RequiredVars: []string{"NESSUS_USERNAME", "NESSUS_PASSWORD"},
+		QueueName:    "NessusQueue",
+		Assets:       []string{"IP", "Hostname"},
+	}
+	tests := []struct {
+		name string
+		got  persistence.PublishChecktypeResult
+		want []manifest.Change
+	}{
+		{
+			name: "Equivalent",
+			got: persistence.PublishChecktypeResult{
+				Name:         "vulcan-nessus",
+				Description:  "Runs nessus",
+				Timeout:      3600,
+				Image:        "registry.example.com/vulcan-checks/vulcan-nessus:3@sha256:7e2f1a",
+				Options:      map[string]interface{}{"port": 443, "policy": "basic"},
+				RequiredVars: []string{"NESSUS_PASSWORD", "NESSUS_USERNAME"},
+				QueueName:    "NessusQueue",
+				Assets:       []string{"Hostname", "IP"},
+			},
+		},
+		{
+			name: "Drifted",
+			got: persistence.PublishChecktypeResult{
+				Name:         "vulcan-nessus",
+				Description:  "Runs nessus",
+				Timeout:      1800,
+				Image:        "registry.example.com/vulcan-checks/vulcan-nessus:2",
+				Options:      `{"policy": "basic", "port": 443}`,
+				RequiredVars: []string{"NESSUS_USERNAME", "NESSUS_PASSWORD"},
+				QueueName:    "DefaultQueue",
+				Assets:       []string{"IP", "Hostname"},
+			},
+			want: []manifest.Change{
+				{Field: "Image", Old: "registry.example.com/vulcan-checks/vulcan-nessus:2", New: "registry.example.com/vulcan-checks/vulcan-nessus:3"},
+				{Field: "Timeout", Old: "1800", New: "3600"},
+				{Field: "QueueName", Old: "DefaultQueue", New: "NessusQueue"},
+			},
+		},
+		{
+			name: "UnknownAssetType",
+			got: persistence.PublishChecktypeResult{
+				Name:         "vulcan-nessus",
+				Description:  "Runs nessus",
+				Timeout:      3600,
+				Image:        "registry.example.com/vulcan-checks/vulcan-nessus:3",
+				Options:      `{"policy": "basic", "port": 443}`,
+				RequiredVars: []string{"NESSUS_USERNAME", "NESSUS_PASSWORD"},
+				QueueName:    "NessusQueue",
+				Assets:       []string{"IP", "Mainframe"},
+			},
+			want: []manifest.Change{
+				{Field: "AssetTypes", Old: "IP, Mainframe", New: "IP, Hostname"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareChecktype(tt.got, want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareChecktype() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	checks := []Check{
+		{Name: "vulcan-exposed-http", Image: "vulcan-exposed-http:4"},
+		{
+			Name:     "vulcan-nessus",
+			Image:    "vulcan-nessus:3",
+			Manifest: []manifest.Change{{Field: "Timeout", Old: "1800", New: "3600"}},
+			Envs: []Env{
+				{Endpoint: "https://persistence.example.com", Changes: []manifest.Change{{Field: "QueueName", Old: "DefaultQueue", New: "NessusQueue"}}},
+				{Endpoint: "https://persistence-dev.example.com", Missing: true, Reconciled: true},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteText(&buf, checks); err != nil {
+		t.Fatal(err)
+	}
+	want := `vulcan-nessus (vulcan-nessus:3)
+  manifest in the repo differs from the latest image:
+    Timeout: 1800 -> 3600
+  https://persistence.example.com: checktype differs from the latest image:
+    QueueName: DefaultQueue -> NessusQueue
+  https://persistence-dev.example.com: checktype not published (reconciled)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteText() got:\n%s\nwant:\n%s", got, want)
+	}
+	if checks[0].Drifted() || !checks[1].Drifted() {
+		t.Errorf("Drifted() got %v, %v, want false, true", checks[0].Drifted(), checks[1].Drifted())
+	}
+}
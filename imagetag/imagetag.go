@@ -0,0 +1,230 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package imagetag implements the strategies used to tag the images of the
+// checks: monotonic integer tags, the default, or semantic version tags, plus
+// the optional latest, commit and branch tags.
+package imagetag
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tagging strategies.
+const (
+	// StrategyInteger tags every new image of a check with the latest
+	// integer tag plus one.
+	StrategyInteger = "integer"
+	// StrategySemver tags every new image of a check with a semantic
+	// version derived from the Version of its manifest or from the messages
+	// of the commits since its latest image.
+	StrategySemver = "semver"
+)
+
+// Kinds of extra tags that can be added to the images besides the version
+// tag.
+const (
+	// ExtraLatest tags the images with latest.
+	ExtraLatest = "latest"
+	// ExtraCommit tags the images with sha-<commit>. The prefix prevents
+	// commits that only contain digits from being taken as integer tags.
+	ExtraCommit = "commit"
+	// ExtraBranch tags the images with the name of the branch they are
+	// built from, sanitized to be a valid tag.
+	ExtraBranch = "branch"
+)
+
+// Policies applied when the version tag of an image already exists in the
+// registry right before pushing it, e.g. because another pipeline pushed it.
+const (
+	// CollisionFail fails the build.
+	CollisionFail = "fail"
+	// CollisionBump tags the image with the next free version.
+	CollisionBump = "bump"
+)
+
+// ValidateCollisionPolicy returns an error if the collision policy is not
+// valid. An empty policy is the fail one.
+func ValidateCollisionPolicy(policy string) error {
+	switch policy {
+	case "", CollisionFail, CollisionBump:
+		return nil
+	}
+	return fmt.Errorf("invalid tag collision policy %q, valid policies are: %s, %s", policy, CollisionFail, CollisionBump)
+}
+
+// Validate returns an error if the strategy or any of the kinds of extra tags
+// is not valid. An empty strategy is the integer one.
+func Validate(strategy string, extra []string) error {
+	switch strategy {
+	case "", StrategyInteger, StrategySemver:
+	default:
+		return fmt.Errorf("invalid tag strategy %q, valid strategies are: %s, %s", strategy, StrategyInteger, StrategySemver)
+	}
+	for _, e := range extra {
+		switch e {
+		case ExtraLatest, ExtraCommit, ExtraBranch:
+		default:
+			return fmt.Errorf("invalid extra tag %q, valid extra tags are: %s, %s, %s", e, ExtraLatest, ExtraCommit, ExtraBranch)
+		}
+	}
+	return nil
+}
+
+// Latest returns the latest version tag, according to the strategy, of the
+// given tags. It returns false if none of the tags is a version tag of the
+// strategy.
+func Latest(strategy string, tags []string) (string, bool) {
+	if strategy == StrategySemver {
+		v, found := LatestVersion(tags)
+		if !found {
+			return "", false
+		}
+		return v.String(), true
+	}
+	return LatestInteger(tags)
+}
+
+// LatestInteger returns the highest of the tags that are decimal integers.
+// It returns "0" and false if there are no integer tags.
+func LatestInteger(tags []string) (string, bool) {
+	var (
+		last  int64
+		found bool
+	)
+	for _, tag := range tags {
+		v, err := strconv.ParseInt(tag, 10, 64)
+		if err != nil || v < 0 {
+			continue
+		}
+		found = true
+		if v > last {
+			last = v
+		}
+	}
+	return strconv.FormatInt(last, 10), found
+}
+
+// Sort returns the version tags, according to the strategy, of the given
+// tags sorted from the latest to the oldest. The tags that are not version
+// tags of the strategy are omitted.
+func Sort(strategy string, tags []string) []string {
+	type version struct {
+		tag string
+		v   Version
+	}
+	var versions []version
+	for _, tag := range tags {
+		if strategy == StrategySemver {
+			if v, err := ParseVersion(tag); err == nil {
+				versions = append(versions, version{tag, v})
+			}
+			continue
+		}
+		// The integer tags are compared as major versions.
+		if n, err := strconv.ParseInt(tag, 10, 64); err == nil && n >= 0 {
+			versions = append(versions, version{tag, Version{Major: int(n)}})
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].v.Compare(versions[j].v) > 0 })
+	res := make([]string, len(versions))
+	for i, v := range versions {
+		res[i] = v.tag
+	}
+	return res
+}
+
+// NextInteger returns the integer tag that follows the given one.
+func NextInteger(tag string) (string, error) {
+	v, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || v < 0 {
+		return "", fmt.Errorf("invalid integer tag %q", tag)
+	}
+	return strconv.FormatInt(v+1, 10), nil
+}
+
+// NextFree returns the version tag, according to the strategy, that follows
+// the highest of the given tag and the existing version tags, so it doesn't
+// collide with any of them. Semantic versions get a patch increment.
+func NextFree(strategy string, existing []string, tag string) (string, error) {
+	latest, found := Latest(strategy, append([]string{tag}, existing...))
+	if !found {
+		return "", fmt.Errorf("invalid %s tag %q", strategy, tag)
+	}
+	if strategy != StrategySemver {
+		return NextInteger(latest)
+	}
+	v, err := ParseVersion(latest)
+	if err != nil {
+		return "", err
+	}
+	return v.Bump(BumpPatch).String(), nil
+}
+
+// Extra returns the extra tags of the given kinds for an image built from a
+// commit of a branch. The branch tag is omitted if the branch name is empty,
+// it could be taken as a version tag or it's the LeaseTag.
+func Extra(kinds []string, commit, branch string) []string {
+	var res []string
+	for _, k := range kinds {
+		switch k {
+		case ExtraLatest:
+			res = append(res, "latest")
+		case ExtraCommit:
+			if commit != "" {
+				res = append(res, "sha-"+commit)
+			}
+		case ExtraBranch:
+			tag := Sanitize(branch)
+			if tag == "" || IsVersion(tag) || tag == LeaseTag {
+				continue
+			}
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+// IsVersion returns true if the tag could be taken as a version tag by any
+// of the strategies.
+func IsVersion(tag string) bool {
+	if _, err := strconv.ParseInt(tag, 10, 64); err == nil {
+		return true
+	}
+	_, err := ParseVersion(tag)
+	return err == nil
+}
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// maxTagLen is the max length of a docker tag.
+const maxTagLen = 128
+
+// Sanitize converts a string, like the name of a branch, to a valid docker
+// tag by replacing the invalid characters with dashes. It returns an empty
+// string if nothing valid remains.
+func Sanitize(s string) string {
+	tag := invalidTagChars.ReplaceAllString(s, "-")
+	tag = strings.TrimLeft(tag, ".-")
+	if len(tag) > maxTagLen {
+		tag = tag[:maxTagLen]
+	}
+	return tag
+}
+
+// CheckCollision returns an error if the version tag of a new image already
+// exists. The version tags are immutable, contrary to the extra tags, that
+// are moved to the new images.
+func CheckCollision(existing []string, version string) error {
+	for _, t := range existing {
+		if t == version {
+			return fmt.Errorf("the tag %s already exists", version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package imagetag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		extra    []string
+		wantErr  bool
+	}{
+		{name: "Default", strategy: ""},
+		{name: "Semver", strategy: StrategySemver, extra: []string{ExtraLatest, ExtraCommit, ExtraBranch}},
+		{name: "InvalidStrategy", strategy: "date", wantErr: true},
+		{name: "InvalidExtraTag", strategy: StrategyInteger, extra: []string{"stable"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.strategy, tt.extra)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLatest(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  string
+		tags      []string
+		want      string
+		wantFound bool
+	}{
+		{
+			name:      "Integer",
+			strategy:  StrategyInteger,
+			tags:      []string{"latest", "5", "12", "1.2.3", "7"},
+			want:      "12",
+			wantFound: true,
+		},
+		{
+			name:      "IntegerIgnoresNonDecimal",
+			strategy:  "",
+			tags:      []string{"9", "0x10", "010", "-20"},
+			want:      "10",
+			wantFound: true,
+		},
+		{
+			name:     "IntegerNoTags",
+			strategy: StrategyInteger,
+			want:     "0",
+		},
+		{
+			name:     "IntegerNotFound",
+			strategy: StrategyInteger,
+			tags:     []string{"latest", "sha-a1b2c3d"},
+			want:     "0",
+		},
+		{
+			name:      "Semver",
+			strategy:  StrategySemver,
+			tags:      []string{"latest", "20", "1.10.0", "1.9.3", "v2.0.0", "1.10.0-rc1"},
+			want:      "1.10.0",
+			wantFound: true,
+		},
+		{
+			name:     "SemverNotFound",
+			strategy: StrategySemver,
+			tags:     []string{"1", "2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := Latest(tt.strategy, tt.tags)
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("got %q, %v, want %q, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestNextInteger(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{tag: "0", want: "1"},
+		{tag: "41", want: "42"},
+		{tag: "1.2.3", wantErr: true},
+		{tag: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := NextInteger(tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtra(t *testing.T) {
+	tests := []struct {
+		name   string
+		kinds  []string
+		commit string
+		branch string
+		want   []string
+	}{
+		{
+			name:   "All",
+			kinds:  []string{ExtraLatest, ExtraCommit, ExtraBranch},
+			commit: "a1b2c3d",
+			branch: "feature/TLS_checks",
+			want:   []string{"latest", "sha-a1b2c3d", "feature-TLS_checks"},
+		},
+		{
+			name:   "None",
+			commit: "a1b2c3d",
+			branch: "master",
+		},
+		{
+			name:   "BranchLikeVersion",
+			kinds:  []string{ExtraBranch},
+			branch: "1.2.3",
+		},
+		{
+			name:   "LeaseBranch",
+			kinds:  []string{ExtraBranch},
+			branch: "lease",
+		},
+		{
+			name:  "EmptyBranch",
+			kinds: []string{ExtraBranch, ExtraCommit},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extra(tt.kinds, tt.commit, tt.branch)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("tags mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "master", want: "master"},
+		{in: "fix/issue #12", want: "fix-issue-12"},
+		{in: ".hidden", want: "hidden"},
+		{in: "/", want: ""},
+		{in: strings.Repeat("a", 200), want: strings.Repeat("a", 128)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCollision(t *testing.T) {
+	existing := []string{"1.0.0", "1.1.0", "latest"}
+	if err := CheckCollision(existing, "1.1.0"); err == nil {
+		t.Error("got no error for an existing tag")
+	}
+	if err := CheckCollision(existing, "1.2.0"); err != nil {
+		t.Errorf("got error %v for a new tag", err)
+	}
+}
+
+func TestValidateCollisionPolicy(t *testing.T) {
+	for _, p := range []string{"", CollisionFail, CollisionBump} {
+		if err := ValidateCollisionPolicy(p); err != nil {
+			t.Errorf("got error %v for the policy %q", err, p)
+		}
+	}
+	if err := ValidateCollisionPolicy("overwrite"); err == nil {
+		t.Error("got no error for an invalid policy")
+	}
+}
+
+func TestNextFree(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		existing []string
+		tag      string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "Integer",
+			strategy: StrategyInteger,
+			existing: []string{"5", "6", "7", "latest"},
+			tag:      "6",
+			want:     "8",
+		},
+		{
+			name:     "IntegerHigherThanExisting",
+			strategy: "",
+			existing: []string{"5"},
+			tag:      "9",
+			want:     "10",
+		},
+		{
+			name:     "Semver",
+			strategy: StrategySemver,
+			existing: []string{"1.2.0", "1.3.0", "lease"},
+			tag:      "1.3.0",
+			want:     "1.3.1",
+		},
+		{
+			name:     "InvalidTag",
+			strategy: StrategySemver,
+			tag:      "7",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextFree(tt.strategy, tt.existing, tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	tags := []string{"latest", "9", "10", "1.10.0", "1.9.3", "2", "v2.0.0", "sha-123"}
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{strategy: StrategyInteger, want: []string{"10", "9", "2"}},
+		{strategy: StrategySemver, want: []string{"1.10.0", "1.9.3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			got := Sort(tt.strategy, tags)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("tags mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
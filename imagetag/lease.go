@@ -0,0 +1,52 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package imagetag
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// LeaseTag is the tag, in the repository of the image of a check, of the
+// artifact that stores the lease on pushing its version tags. It's never
+// taken as a version tag.
+const LeaseTag = "lease"
+
+// LeaseMediaType is the media type of the artifact that stores a lease.
+const LeaseMediaType = "application/vnd.adevinta.vulcan.lease.v1+json"
+
+// Lease is a lock, shared through the registry by all the pipelines, on
+// pushing the version tags of a check. It's stored, as json, in an artifact
+// tagged with the LeaseTag in the repository of the image of the check.
+//
+// The lease is best effort: the registry has no compare and swap, so two
+// pipelines that push their lease at the same time can both believe they
+// hold it. The version tags are verified again right before pushing them.
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// Marshal returns the content of the artifact that stores the lease.
+func (l Lease) Marshal() ([]byte, error) {
+	l.Expires = l.Expires.UTC()
+	return json.Marshal(l)
+}
+
+// ParseLease returns the lease stored in the content of an artifact. It
+// returns false if the content is not a valid lease.
+func ParseLease(content []byte) (Lease, bool) {
+	var l Lease
+	if err := json.Unmarshal(content, &l); err != nil || l.Holder == "" {
+		return Lease{}, false
+	}
+	return l, true
+}
+
+// Active returns true if the lease has not expired at the given time. The
+// leases are released by setting their expiration to the release time.
+func (l Lease) Active(now time.Time) bool {
+	return l.Holder != "" && now.Before(l.Expires)
+}
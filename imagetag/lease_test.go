@@ -0,0 +1,51 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package imagetag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+	l := Lease{Holder: "a1b2c3d-0f1e2d3c", Expires: now.Add(10 * time.Minute)}
+	content, err := l.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := ParseLease(content)
+	if !ok {
+		t.Fatalf("lease not found in %s", content)
+	}
+	if got.Holder != l.Holder || !got.Expires.Equal(l.Expires) {
+		t.Errorf("got %+v, want %+v", got, l)
+	}
+	if !got.Active(now) {
+		t.Error("lease not active before expiring")
+	}
+	if got.Active(now.Add(10 * time.Minute)) {
+		t.Error("lease active after expiring")
+	}
+}
+
+func TestParseLease(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Empty"},
+		{name: "NoHolder", content: `{"expires":"2024-03-01T10:00:00Z"}`},
+		{name: "InvalidExpiration", content: `{"holder":"a","expires":"tomorrow"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if l, ok := ParseLease([]byte(tt.content)); ok || l.Active(time.Time{}) {
+				t.Errorf("got valid lease %+v", l)
+			}
+		})
+	}
+}
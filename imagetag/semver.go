@@ -0,0 +1,170 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package imagetag
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version without pre-release or build metadata.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+var versionRegexp = regexp.MustCompile(`^(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+
+// ParseVersion parses a version in the form MAJOR.MINOR.PATCH.
+func ParseVersion(s string) (Version, error) {
+	m := versionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid semantic version %q", s)
+	}
+	var v Version
+	for i, p := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid semantic version %q: %w", s, err)
+		}
+		*p = n
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 if the version is lower, equal or higher than
+// the given one.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Bump returns the version incremented according to the kind of change.
+func (v Version) Bump(b Bump) Version {
+	switch b {
+	case BumpMajor:
+		return Version{Major: v.Major + 1}
+	case BumpMinor:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// LatestVersion returns the highest of the tags that are semantic versions.
+func LatestVersion(tags []string) (Version, bool) {
+	var (
+		last  Version
+		found bool
+	)
+	for _, tag := range tags {
+		v, err := ParseVersion(tag)
+		if err != nil {
+			continue
+		}
+		if !found || v.Compare(last) > 0 {
+			last = v
+		}
+		found = true
+	}
+	return last, found
+}
+
+// Bump is the kind of change that increments a version.
+type Bump int
+
+// Kinds of changes, from the lowest to the highest.
+const (
+	BumpPatch Bump = iota
+	BumpMinor
+	BumpMajor
+)
+
+// conventionalRegexp matches the header of a conventional commit, e.g.:
+// "feat(tls)!: drop support for SSLv3".
+var conventionalRegexp = regexp.MustCompile(`^([a-zA-Z]+)(\([^)]*\))?(!)?: `)
+
+// BumpFromCommits returns the highest change of the given commit messages,
+// following the conventional commits spec: a breaking change, marked with !
+// or a BREAKING CHANGE footer, is a major change, a feat is a minor change and
+// any other commit is a patch.
+func BumpFromCommits(messages []string) Bump {
+	bump := BumpPatch
+	for _, msg := range messages {
+		b := BumpPatch
+		m := conventionalRegexp.FindStringSubmatch(strings.TrimSpace(msg))
+		switch {
+		case m != nil && m[3] == "!", breakingFooter(msg):
+			b = BumpMajor
+		case m != nil && strings.EqualFold(m[1], "feat"):
+			b = BumpMinor
+		}
+		if b > bump {
+			bump = b
+		}
+	}
+	return bump
+}
+
+func breakingFooter(msg string) bool {
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			return true
+		}
+	}
+	return false
+}
+
+// initialVersion is the version of the first image of a check without a
+// Version in its manifest.
+var initialVersion = Version{Major: 1}
+
+// NextVersion returns the version of the next image of a check. The
+// manifestVersion, the Version field of the manifest with an optional v
+// prefix, is used if it's higher than the latest version. Otherwise the
+// latest version is incremented according to the messages of the commits
+// since its image, with a patch increment if the manifest defines a Version,
+// so rebuilding a check never reuses a version.
+func NextVersion(latest string, found bool, manifestVersion string, messages []string) (Version, error) {
+	var (
+		want    Version
+		defined = manifestVersion != ""
+	)
+	if defined {
+		v, err := ParseVersion(strings.TrimPrefix(manifestVersion, "v"))
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid Version in the manifest: %w", err)
+		}
+		want = v
+	}
+	if !found {
+		if defined {
+			return want, nil
+		}
+		return initialVersion, nil
+	}
+	last, err := ParseVersion(latest)
+	if err != nil {
+		return Version{}, err
+	}
+	if defined {
+		if want.Compare(last) > 0 {
+			return want, nil
+		}
+		return last.Bump(BumpPatch), nil
+	}
+	return last.Bump(BumpFromCommits(messages)), nil
+}
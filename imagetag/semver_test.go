@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package imagetag
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Version
+		wantErr bool
+	}{
+		{in: "1.2.3", want: Version{1, 2, 3}},
+		{in: "0.10.0", want: Version{0, 10, 0}},
+		{in: "v1.2.3", wantErr: true},
+		{in: "1.2", wantErr: true},
+		{in: "01.2.3", wantErr: true},
+		{in: "1.2.3-rc1", wantErr: true},
+		{in: "7", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseVersion(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBumpFromCommits(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		want     Bump
+	}{
+		{name: "NoCommits", want: BumpPatch},
+		{name: "Fix", messages: []string{"fix: handle timeouts", "Update deps"}, want: BumpPatch},
+		{name: "Feat", messages: []string{"fix: handle timeouts", "feat(tls): check TLS 1.3"}, want: BumpMinor},
+		{name: "Bang", messages: []string{"feat: new option", "refactor(options)!: rename the port option"}, want: BumpMajor},
+		{name: "Footer", messages: []string{"fix: options\n\nBREAKING CHANGE: the port option is an int"}, want: BumpMajor},
+		{name: "NotConventional", messages: []string{"feature: something", "Feat without colon"}, want: BumpPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BumpFromCommits(tt.messages); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		latest          string
+		found           bool
+		manifestVersion string
+		messages        []string
+		want            string
+		wantErr         bool
+	}{
+		{name: "FirstImage", want: "1.0.0"},
+		{name: "FirstImageManifestVersion", manifestVersion: "v0.3.0", want: "0.3.0"},
+		{name: "Patch", latest: "1.2.3", found: true, messages: []string{"fix: typo"}, want: "1.2.4"},
+		{name: "Minor", latest: "1.2.3", found: true, messages: []string{"feat: new option"}, want: "1.3.0"},
+		{name: "Major", latest: "1.2.3", found: true, messages: []string{"feat!: new options"}, want: "2.0.0"},
+		{name: "ManifestVersionHigher", latest: "1.2.3", found: true, manifestVersion: "2.0.0", messages: []string{"fix: typo"}, want: "2.0.0"},
+		{name: "ManifestVersionNotHigher", latest: "2.0.0", found: true, manifestVersion: "2.0.0", messages: []string{"feat: ignored"}, want: "2.0.1"},
+		{name: "InvalidManifestVersion", manifestVersion: "2.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextVersion(tt.latest, tt.found, tt.manifestVersion, tt.messages)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
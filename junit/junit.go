@@ -0,0 +1,223 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package junit generates JUnit XML reports from the output of go test -json.
+package junit
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestEvent is an event generated by go test -json. See go doc test2json.
+type TestEvent struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// TestSuites is the root element of a JUnit report.
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite contains the results of the tests of a go package.
+type TestSuite struct {
+	Name       string     `xml:"name,attr"`
+	Tests      int        `xml:"tests,attr"`
+	Failures   int        `xml:"failures,attr"`
+	Skipped    int        `xml:"skipped,attr"`
+	Time       string     `xml:"time,attr"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	TestCases  []TestCase `xml:"testcase"`
+}
+
+// Property is a property of a test suite, e.g.: the coverage of a package.
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// TestCase is the result of a test.
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	Classname string   `xml:"classname,attr"`
+	Time      string   `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+}
+
+// Failure contains the output of a failed test.
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Output  string `xml:",chardata"`
+}
+
+// Skipped contains the output of a skipped test.
+type Skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+var coverageRegexp = regexp.MustCompile(`coverage: ([0-9.]+%) of statements`)
+
+// Parse reads the output of go test -json and returns the corresponding
+// JUnit report. The lines that are not json events, like the ones printed
+// when a package doesn't build in old go versions, are ignored.
+func Parse(r io.Reader) (TestSuites, error) {
+	pkgs := make(map[string]*pkgState)
+	state := func(name string) *pkgState {
+		p, ok := pkgs[name]
+		if !ok {
+			p = &pkgState{
+				suite:   TestSuite{Name: name},
+				outputs: make(map[string]*strings.Builder),
+			}
+			pkgs[name] = p
+		}
+		return p
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		var e TestEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil || e.Package == "" {
+			continue
+		}
+		p := state(e.Package)
+		switch e.Action {
+		case "output":
+			out, ok := p.outputs[e.Test]
+			if !ok {
+				out = &strings.Builder{}
+				p.outputs[e.Test] = out
+			}
+			out.WriteString(e.Output)
+			if m := coverageRegexp.FindStringSubmatch(e.Output); e.Test == "" && m != nil {
+				p.suite.Properties = []Property{{Name: "coverage", Value: m[1]}}
+			}
+		case "pass", "fail", "skip":
+			if e.Test == "" {
+				p.result = e.Action
+				p.suite.Time = seconds(e.Elapsed)
+				continue
+			}
+			tc := TestCase{Name: e.Test, Classname: e.Package, Time: seconds(e.Elapsed)}
+			switch e.Action {
+			case "fail":
+				tc.Failure = &Failure{Message: "Failed", Output: p.output(e.Test)}
+			case "skip":
+				tc.Skipped = &Skipped{Message: strings.TrimSpace(p.output(e.Test))}
+			}
+			p.suite.TestCases = append(p.suite.TestCases, tc)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return TestSuites{}, err
+	}
+
+	var names []string
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ts TestSuites
+	for _, name := range names {
+		p := pkgs[name]
+		failed := false
+		for _, tc := range p.suite.TestCases {
+			failed = failed || tc.Failure != nil
+		}
+		// A package can fail without any failed test, e.g.: when it doesn't
+		// build or a TestMain fails, the failure is reported as a test case
+		// with the name of the package.
+		if p.result == "fail" && !failed {
+			p.suite.TestCases = append(p.suite.TestCases, TestCase{
+				Name:      name,
+				Classname: name,
+				Time:      p.suite.Time,
+				Failure:   &Failure{Message: "Package failed", Output: p.output("")},
+			})
+		}
+		// The packages without test files don't produce a test suite.
+		if len(p.suite.TestCases) == 0 {
+			continue
+		}
+		for _, tc := range p.suite.TestCases {
+			p.suite.Tests++
+			if tc.Failure != nil {
+				p.suite.Failures++
+			}
+			if tc.Skipped != nil {
+				p.suite.Skipped++
+			}
+		}
+		ts.Tests += p.suite.Tests
+		ts.Failures += p.suite.Failures
+		ts.Skipped += p.suite.Skipped
+		ts.Suites = append(ts.Suites, p.suite)
+	}
+	return ts, nil
+}
+
+// Write writes the report as XML.
+func (ts TestSuites) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(ts); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Coverage returns the coverage of each package of the report that has it.
+func (ts TestSuites) Coverage() map[string]string {
+	cov := make(map[string]string)
+	for _, s := range ts.Suites {
+		for _, p := range s.Properties {
+			if p.Name == "coverage" {
+				cov[s.Name] = p.Value
+			}
+		}
+	}
+	return cov
+}
+
+// pkgState contains the results of the tests of a package while parsing
+// the output of go test.
+type pkgState struct {
+	suite   TestSuite
+	outputs map[string]*strings.Builder
+	result  string
+}
+
+func (p *pkgState) output(test string) string {
+	out, ok := p.outputs[test]
+	if !ok {
+		return ""
+	}
+	return out.String()
+}
+
+func seconds(elapsed float64) string {
+	return fmt.Sprintf("%.3f", elapsed)
+}
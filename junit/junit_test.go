@@ -0,0 +1,116 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package junit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint: errcheck
+
+	got, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TestSuites{
+		Tests:    4,
+		Failures: 2,
+		Skipped:  1,
+		Suites: []TestSuite{
+			{
+				Name:       "example.com/checks/cmd/check",
+				Tests:      3,
+				Failures:   1,
+				Skipped:    1,
+				Time:       "0.020",
+				Properties: []Property{{Name: "coverage", Value: "42.5%"}},
+				TestCases: []TestCase{
+					{Name: "TestPass", Classname: "example.com/checks/cmd/check", Time: "0.010"},
+					{
+						Name:      "TestFail",
+						Classname: "example.com/checks/cmd/check",
+						Time:      "0.000",
+						Failure: &Failure{
+							Message: "Failed",
+							Output:  "=== RUN   TestFail\n    check_test.go:10: got 1, want 2\n--- FAIL: TestFail (0.00s)\n",
+						},
+					},
+					{
+						Name:      "TestSkip",
+						Classname: "example.com/checks/cmd/check",
+						Time:      "0.000",
+						Skipped:   &Skipped{Message: "check_test.go:20: needs docker"},
+					},
+				},
+			},
+			{
+				Name:     "example.com/checks/cmd/check/broken",
+				Tests:    1,
+				Failures: 1,
+				Time:     "0.000",
+				TestCases: []TestCase{
+					{
+						Name:      "example.com/checks/cmd/check/broken",
+						Classname: "example.com/checks/cmd/check/broken",
+						Time:      "0.000",
+						Failure: &Failure{
+							Message: "Package failed",
+							Output:  "FAIL\texample.com/checks/cmd/check/broken [build failed]\n",
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+	}
+	if cov := got.Coverage(); cov["example.com/checks/cmd/check"] != "42.5%" {
+		t.Errorf("Coverage() = %v, want 42.5%%", cov)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	ts := TestSuites{
+		Tests:    1,
+		Failures: 1,
+		Suites: []TestSuite{
+			{
+				Name:     "example.com/checks/cmd/check",
+				Tests:    1,
+				Failures: 1,
+				TestCases: []TestCase{
+					{Name: "TestFail", Failure: &Failure{Message: "Failed", Output: "got <1>"}},
+				},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := ts.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("Write() output doesn't start with the XML header: %s", buf.String())
+	}
+	var got TestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	got.XMLName = xml.Name{}
+	if diff := cmp.Diff(ts, got); diff != "" {
+		t.Errorf("Write() round trip mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// instruction is an instruction of a Dockerfile.
+type instruction struct {
+	Cmd  string // Upper case, e.g.: CMD.
+	Args string
+	Line int // Line where the instruction starts.
+}
+
+// parseDockerfile returns the instructions of a Dockerfile, joining the lines
+// continued with a backslash and ignoring the comments.
+func parseDockerfile(content []byte) []instruction {
+	var (
+		insts []instruction
+		cur   *instruction
+		buf   strings.Builder
+	)
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") || (line == "" && cur == nil) {
+			continue
+		}
+		if cur == nil {
+			cur = &instruction{Line: n}
+		}
+		continued := strings.HasSuffix(line, `\`)
+		buf.WriteString(strings.TrimSuffix(line, `\`))
+		if continued {
+			buf.WriteString(" ")
+			continue
+		}
+		cmd, args, _ := strings.Cut(strings.TrimSpace(buf.String()), " ")
+		cur.Cmd = strings.ToUpper(cmd)
+		cur.Args = strings.TrimSpace(args)
+		insts = append(insts, *cur)
+		cur = nil
+		buf.Reset()
+	}
+	return insts
+}
+
+// execArgs returns the arguments of an instruction that accepts both the exec
+// form, e.g.: CMD ["/check", "-v"], and the shell form, e.g.: CMD /check -v.
+func (i instruction) execArgs() []string {
+	var args []string
+	if strings.HasPrefix(i.Args, "[") && json.Unmarshal([]byte(i.Args), &args) == nil {
+		return args
+	}
+	return strings.Fields(i.Args)
+}
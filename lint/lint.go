@@ -0,0 +1,241 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package lint applies a set of rules to the directories of the checks to
+// find the common mistakes in their manifests, Dockerfiles, local config
+// examples and go files before they reach production.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+	"github.com/adevinta/vulcan-checks-bsys/sarif"
+)
+
+// Severity is the severity of the findings of a rule.
+type Severity string
+
+// Severities of the rules, the same as the SARIF levels, plus off to disable
+// a rule.
+const (
+	SeverityError   Severity = sarif.LevelError
+	SeverityWarning Severity = sarif.LevelWarning
+	SeverityNote    Severity = sarif.LevelNote
+	SeverityOff     Severity = "off"
+)
+
+// ParseSeverity returns the severity with the given name.
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(s)); sev {
+	case SeverityError, SeverityWarning, SeverityNote, SeverityOff:
+		return sev, nil
+	}
+	return "", fmt.Errorf("invalid lint severity %q", s)
+}
+
+// Names of the files of a check dir inspected by the rules.
+const (
+	ManifestFile     = "manifest.toml"
+	DockerfileFile   = "Dockerfile"
+	LocalExampleFile = "local.toml.example"
+)
+
+// Finding is a problem found in a check dir.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Check is the path of the check dir.
+	Check   string `json:"check"`
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Config contains the configuration of the linter.
+type Config struct {
+	// Severities overrides the default severity of the rules, indexed by
+	// rule ID.
+	Severities map[string]Severity
+	// QueueNames are the valid names of the queues of the checks. If empty
+	// the queue names are not checked.
+	QueueNames []string
+}
+
+// Linter lints check dirs.
+type Linter struct {
+	Config Config
+}
+
+// LintRepo lints all the check dirs found under root.
+func (l Linter) LintRepo(root string) ([]Finding, error) {
+	dirs, err := CheckDirs(root)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, dir := range dirs {
+		f, err := l.LintDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, f...)
+	}
+	return findings, nil
+}
+
+// LintDir lints a check dir.
+func (l Linter) LintDir(dir string) ([]Finding, error) {
+	d, err := loadCheckDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, r := range Rules() {
+		sev := r.Severity
+		if s, ok := l.Config.Severities[r.ID]; ok {
+			sev = s
+		}
+		if sev == SeverityOff {
+			continue
+		}
+		for _, i := range r.check(d, l.Config) {
+			findings = append(findings, Finding{
+				Rule:     r.ID,
+				Severity: sev,
+				Check:    dir,
+				File:     filepath.Join(dir, i.file),
+				Line:     i.line,
+				Message:  i.msg,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// CheckDirs returns the dirs under root that contain a check, that is a
+// manifest or a Dockerfile. The hidden dirs, and the vendor and testdata dirs
+// are skipped.
+func CheckDirs(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if p != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+			return filepath.SkipDir
+		}
+		for _, f := range []string{ManifestFile, DockerfileFile} {
+			if _, err := os.Stat(filepath.Join(p, f)); err == nil {
+				dirs = append(dirs, p)
+				break
+			}
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	return dirs, err
+}
+
+// HasErrors returns true if any of the findings has severity error.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes the findings in a human readable format, one per line.
+func WriteText(w io.Writer, findings []Finding) error {
+	for _, f := range findings {
+		loc := f.File
+		if f.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s: %s [%s]\n", loc, f.Severity, f.Message, f.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SARIF returns the findings in SARIF format.
+func SARIF(findings []Finding) sarif.Log {
+	var rules []sarif.Rule
+	for _, r := range Rules() {
+		rules = append(rules, sarif.Rule{
+			ID:                   r.ID,
+			ShortDescription:     &sarif.Message{Text: r.Description},
+			DefaultConfiguration: &sarif.Configuration{Level: string(r.Severity)},
+		})
+	}
+	var results []sarif.Result
+	for _, f := range findings {
+		results = append(results, sarif.Result{
+			RuleID:    f.Rule,
+			Level:     string(f.Severity),
+			Message:   sarif.Message{Text: f.Message},
+			Locations: []sarif.Location{sarif.NewLocation(filepath.ToSlash(f.File), f.Line)},
+		})
+	}
+	driver := sarif.Driver{
+		Name:           "vulcan-build-images lint",
+		InformationURI: "https://github.com/adevinta/vulcan-checks-bsys",
+		Rules:          rules,
+	}
+	return sarif.New(driver, results)
+}
+
+// checkDir contains the files of a check dir inspected by the rules.
+type checkDir struct {
+	name        string
+	files       map[string][]byte
+	manifest    manifest.Data
+	manifestErr error
+	goFiles     []string
+}
+
+func loadCheckDir(dir string) (*checkDir, error) {
+	d := &checkDir{
+		name:  filepath.Base(dir),
+		files: make(map[string][]byte),
+	}
+	for _, f := range []string{ManifestFile, DockerfileFile, LocalExampleFile} {
+		content, err := os.ReadFile(filepath.Join(dir, f))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.files[f] = content
+	}
+	if _, ok := d.files[ManifestFile]; ok {
+		d.manifest, d.manifestErr = manifest.Read(filepath.Join(dir, ManifestFile))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			d.goFiles = append(d.goFiles, filepath.Join(dir, name))
+		}
+	}
+	return d, nil
+}
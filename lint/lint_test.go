@@ -0,0 +1,172 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLinterLintRepo(t *testing.T) {
+	root := filepath.Join("testdata", "checks")
+	bad := filepath.Join(root, "vulcan-bad")
+	tests := []struct {
+		name string
+		cfg  Config
+		want []Finding
+	}{
+		{
+			name: "DefaultSeverities",
+			cfg:  Config{QueueNames: []string{"default", "nessus"}},
+			want: []Finding{
+				{
+					Rule:     "manifest-fields",
+					Severity: SeverityWarning,
+					Check:    bad,
+					File:     filepath.Join(bad, ManifestFile),
+					Message:  "Timeout not defined, the default timeout of the platform will be used",
+				},
+				{
+					Rule:     "manifest-queue-name",
+					Severity: SeverityError,
+					Check:    bad,
+					File:     filepath.Join(bad, ManifestFile),
+					Line:     3,
+					Message:  `unknown QueueName "nesus", did you mean "nessus"?`,
+				},
+				{
+					Rule:     "dockerfile-cmd",
+					Severity: SeverityError,
+					Check:    bad,
+					File:     filepath.Join(bad, DockerfileFile),
+					Line:     3,
+					Message:  `ENTRYPOINT runs "vulcan-bda" but the binary of the check is named "vulcan-bad"`,
+				},
+				{
+					Rule:     "dockerfile-root",
+					Severity: SeverityWarning,
+					Check:    bad,
+					File:     filepath.Join(bad, DockerfileFile),
+					Message:  "no USER defined, the check runs as root",
+				},
+				{
+					Rule:     "local-required-vars",
+					Severity: SeverityWarning,
+					Check:    bad,
+					File:     filepath.Join(bad, LocalExampleFile),
+					Message:  "local.toml.example not found, it must define the RequiredVars API_KEY",
+				},
+				{
+					Rule:     "go-main-package",
+					Severity: SeverityError,
+					Check:    bad,
+					File:     bad,
+					Message:  "no main package found",
+				},
+			},
+		},
+		{
+			name: "OverriddenSeverities",
+			cfg: Config{
+				Severities: map[string]Severity{
+					"manifest-fields":     SeverityOff,
+					"dockerfile-root":     SeverityOff,
+					"local-required-vars": SeverityOff,
+					"go-main-package":     SeverityOff,
+					"dockerfile-cmd":      SeverityNote,
+				},
+			},
+			want: []Finding{
+				{
+					Rule:     "dockerfile-cmd",
+					Severity: SeverityNote,
+					Check:    bad,
+					File:     filepath.Join(bad, DockerfileFile),
+					Line:     3,
+					Message:  `ENTRYPOINT runs "vulcan-bda" but the binary of the check is named "vulcan-bad"`,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Linter{Config: tt.cfg}.LintRepo(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("LintRepo() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckDirs(t *testing.T) {
+	root := filepath.Join("testdata", "checks")
+	got, err := CheckDirs(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "vulcan-bad"), filepath.Join(root, "vulcan-good")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CheckDirs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	findings := []Finding{
+		{Rule: "dockerfile-cmd", Severity: SeverityError, File: "cmd/vulcan-bad/Dockerfile", Line: 3, Message: "wrong binary"},
+	}
+	var buf bytes.Buffer
+	if err := SARIF(findings).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Runs []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	r := got.Runs[0].Results[0]
+	loc := r.Locations[0].PhysicalLocation
+	if r.RuleID != "dockerfile-cmd" || r.Level != "error" || loc.ArtifactLocation.URI != "cmd/vulcan-bad/Dockerfile" || loc.Region.StartLine != 3 {
+		t.Errorf("SARIF() result = %+v, want dockerfile-cmd error at cmd/vulcan-bad/Dockerfile:3", r)
+	}
+}
+
+func TestParseDockerfile(t *testing.T) {
+	content := []byte("# Comment\nFROM alpine\n\nRUN apk add \\\n    curl\nCMD [\"/check\", \"-v\"]\n")
+	want := []instruction{
+		{Cmd: "FROM", Args: "alpine", Line: 2},
+		{Cmd: "RUN", Args: "apk add  curl", Line: 4},
+		{Cmd: "CMD", Args: `["/check", "-v"]`, Line: 6},
+	}
+	got := parseDockerfile(content)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseDockerfile() mismatch (-want +got):\n%s", diff)
+	}
+	if args := got[2].execArgs(); !cmp.Equal(args, []string{"/check", "-v"}) {
+		t.Errorf("execArgs() = %v, want [/check -v]", args)
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Rule is a rule applied to the check dirs.
+type Rule struct {
+	ID          string
+	Description string
+	// Severity is the default severity of the findings of the rule.
+	Severity Severity
+	check    func(d *checkDir, cfg Config) []issue
+}
+
+// issue is a problem found by a rule in a file of a check dir.
+type issue struct {
+	file string
+	line int
+	msg  string
+}
+
+// Rules returns the rules applied by the linter.
+func Rules() []Rule {
+	return []Rule{
+		{
+			ID:          "manifest-missing",
+			Description: "The check dir must contain a manifest.toml file.",
+			Severity:    SeverityError,
+			check:       checkManifestMissing,
+		},
+		{
+			ID:          "manifest-invalid",
+			Description: "The manifest must be a valid toml file with a Description and, if defined, valid json Options and metadata.",
+			Severity:    SeverityError,
+			check:       checkManifestInvalid,
+		},
+		{
+			ID:          "manifest-fields",
+			Description: "The manifest should define the Timeout and the AssetTypes of the check.",
+			Severity:    SeverityWarning,
+			check:       checkManifestFields,
+		},
+		{
+			ID:          "manifest-queue-name",
+			Description: "The QueueName of the manifest must be one of the queues configured.",
+			Severity:    SeverityError,
+			check:       checkManifestQueueName,
+		},
+		{
+			ID:          "dockerfile-missing",
+			Description: "The check dir must contain a Dockerfile.",
+			Severity:    SeverityError,
+			check:       checkDockerfileMissing,
+		},
+		{
+			ID:          "dockerfile-cmd",
+			Description: "The CMD or ENTRYPOINT of the Dockerfile must run the binary of the check, named as its dir.",
+			Severity:    SeverityError,
+			check:       checkDockerfileCmd,
+		},
+		{
+			ID:          "dockerfile-root",
+			Description: "The image should not run the check as root.",
+			Severity:    SeverityWarning,
+			check:       checkDockerfileRoot,
+		},
+		{
+			ID:          "local-required-vars",
+			Description: "The RequiredVars of the manifest, including the ones of its asset type overrides, must be present in the local.toml.example file.",
+			Severity:    SeverityWarning,
+			check:       checkLocalRequiredVars,
+		},
+		{
+			ID:          "go-main-package",
+			Description: "The check dir must contain the go files of a main package.",
+			Severity:    SeverityError,
+			check:       checkGoMainPackage,
+		},
+	}
+}
+
+func checkManifestMissing(d *checkDir, _ Config) []issue {
+	if _, ok := d.files[ManifestFile]; ok {
+		return nil
+	}
+	return []issue{{file: ManifestFile, msg: "manifest.toml not found"}}
+}
+
+func checkManifestInvalid(d *checkDir, _ Config) []issue {
+	if d.manifestErr == nil {
+		return nil
+	}
+	return []issue{{file: ManifestFile, msg: fmt.Sprintf("invalid manifest: %v", d.manifestErr)}}
+}
+
+func checkManifestFields(d *checkDir, _ Config) []issue {
+	if !d.validManifest() {
+		return nil
+	}
+	var issues []issue
+	if d.manifest.Timeout <= 0 {
+		issues = append(issues, issue{file: ManifestFile, msg: "Timeout not defined, the default timeout of the platform will be used"})
+	}
+	if len(d.manifest.AssetTypes) == 0 {
+		issues = append(issues, issue{file: ManifestFile, msg: "no AssetTypes defined, the check can not be run against any asset"})
+	}
+	return issues
+}
+
+func checkManifestQueueName(d *checkDir, cfg Config) []issue {
+	if !d.validManifest() || d.manifest.QueueName == "" || len(cfg.QueueNames) == 0 {
+		return nil
+	}
+	name := d.manifest.QueueName
+	for _, q := range cfg.QueueNames {
+		if q == name {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("unknown QueueName %q", name)
+	if s := closest(name, cfg.QueueNames); s != "" {
+		msg += fmt.Sprintf(", did you mean %q?", s)
+	}
+	return []issue{{file: ManifestFile, line: keyLine(d.files[ManifestFile], "QueueName"), msg: msg}}
+}
+
+func checkDockerfileMissing(d *checkDir, _ Config) []issue {
+	if _, ok := d.files[DockerfileFile]; ok {
+		return nil
+	}
+	return []issue{{file: DockerfileFile, msg: "Dockerfile not found"}}
+}
+
+func checkDockerfileCmd(d *checkDir, _ Config) []issue {
+	content, ok := d.files[DockerfileFile]
+	if !ok {
+		return nil
+	}
+	var cmd, entrypoint *instruction
+	for _, i := range finalStage(parseDockerfile(content)) {
+		i := i
+		switch i.Cmd {
+		case "CMD":
+			cmd = &i
+		case "ENTRYPOINT":
+			entrypoint = &i
+		}
+	}
+	run := entrypoint
+	if run == nil {
+		run = cmd
+	}
+	if run == nil {
+		return []issue{{file: DockerfileFile, msg: "no CMD nor ENTRYPOINT defined"}}
+	}
+	args := run.execArgs()
+	if len(args) == 0 {
+		return []issue{{file: DockerfileFile, line: run.Line, msg: fmt.Sprintf("empty %s", run.Cmd)}}
+	}
+	if bin := path.Base(args[0]); bin != d.name {
+		return []issue{{
+			file: DockerfileFile,
+			line: run.Line,
+			msg:  fmt.Sprintf("%s runs %q but the binary of the check is named %q", run.Cmd, bin, d.name),
+		}}
+	}
+	return nil
+}
+
+func checkDockerfileRoot(d *checkDir, _ Config) []issue {
+	content, ok := d.files[DockerfileFile]
+	if !ok {
+		return nil
+	}
+	var user *instruction
+	for _, i := range finalStage(parseDockerfile(content)) {
+		i := i
+		if i.Cmd == "USER" {
+			user = &i
+		}
+	}
+	if user == nil {
+		return []issue{{file: DockerfileFile, msg: "no USER defined, the check runs as root"}}
+	}
+	name, _, _ := strings.Cut(user.Args, ":")
+	if name == "root" || name == "0" {
+		return []issue{{file: DockerfileFile, line: user.Line, msg: "the check runs as root"}}
+	}
+	return nil
+}
+
+func checkLocalRequiredVars(d *checkDir, _ Config) []issue {
+	if !d.validManifest() {
+		return nil
+	}
+	required := d.manifest.AllRequiredVars()
+	if len(required) == 0 {
+		return nil
+	}
+	content, ok := d.files[LocalExampleFile]
+	if !ok {
+		return []issue{{
+			file: LocalExampleFile,
+			msg:  fmt.Sprintf("local.toml.example not found, it must define the RequiredVars %s", strings.Join(required, ", ")),
+		}}
+	}
+	var local struct {
+		RequiredVars map[string]string `toml:"RequiredVars"`
+	}
+	if _, err := toml.Decode(string(content), &local); err != nil {
+		return []issue{{file: LocalExampleFile, msg: fmt.Sprintf("invalid local.toml.example: %v", err)}}
+	}
+	var issues []issue
+	for _, v := range required {
+		if _, ok := local.RequiredVars[v]; !ok {
+			issues = append(issues, issue{
+				file: LocalExampleFile,
+				msg:  fmt.Sprintf("required var %s not defined in the RequiredVars section", v),
+			})
+		}
+	}
+	return issues
+}
+
+func checkGoMainPackage(d *checkDir, _ Config) []issue {
+	if len(d.goFiles) == 0 {
+		return []issue{{file: ".", msg: "no go files found"}}
+	}
+	fset := token.NewFileSet()
+	for _, f := range d.goFiles {
+		file, err := parser.ParseFile(fset, f, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return []issue{{file: path.Base(f), msg: fmt.Sprintf("invalid go file: %v", err)}}
+		}
+		if file.Name.Name == "main" {
+			return nil
+		}
+	}
+	return []issue{{file: ".", msg: "no main package found"}}
+}
+
+func (d *checkDir) validManifest() bool {
+	_, ok := d.files[ManifestFile]
+	return ok && d.manifestErr == nil
+}
+
+// finalStage returns the instructions of the last stage of a Dockerfile.
+func finalStage(insts []instruction) []instruction {
+	for n := len(insts) - 1; n >= 0; n-- {
+		if insts[n].Cmd == "FROM" {
+			return insts[n:]
+		}
+	}
+	return insts
+}
+
+// keyLine returns the line where a key of a toml file is defined, or 0 if
+// it's not found.
+func keyLine(content []byte, key string) int {
+	re := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s*=`)
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; s.Scan(); n++ {
+		if re.MatchString(s.Text()) {
+			return n
+		}
+	}
+	return 0
+}
+
+// closest returns the candidate most similar to s, if it's at most two edits
+// away.
+func closest(s string, candidates []string) string {
+	best, dist := "", 3
+	for _, c := range candidates {
+		if d := levenshtein(strings.ToLower(s), strings.ToLower(c)); d < dist {
+			best, dist = c, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
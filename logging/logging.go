@@ -0,0 +1,75 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package logging creates the structured loggers of the commands, that write
+// text or json records, for the CI to ingest them, with a minimum level.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Formats of the records.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configures a logger.
+type Options struct {
+	// Format is the format of the records, FormatText by default.
+	Format string
+	// Level is the minimum level of the records written: debug, info, the
+	// default, warn or error.
+	Level string
+	// Quiet only writes the warnings and the errors. It overrides the
+	// Level if it's lower.
+	Quiet bool
+}
+
+// New returns a logger that writes to w the records of the level of the
+// options, or higher, in the format of the options.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Quiet && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	hopts := &slog.HandlerOptions{Level: level}
+	switch opts.Format {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, hopts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, hopts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, valid formats are: %s, %s", opts.Format, FormatText, FormatJSON)
+}
+
+// ParseLevel parses a level: debug, info, warn or error, case insensitive.
+// An empty level is info.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	switch strings.ToLower(s) {
+	case "debug", "info", "warn", "error":
+	default:
+		return l, fmt.Errorf("invalid log level %q, valid levels are: debug, info, warn, error", s)
+	}
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+// Fatal logs an error and exits with status 1.
+func Fatal(l *slog.Logger, msg string, err error) {
+	l.Error(msg, "error", err)
+	os.Exit(1)
+}
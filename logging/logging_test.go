@@ -0,0 +1,55 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		wantLines int
+		wantJSON  bool
+		wantErr   bool
+	}{
+		{name: "Default", opts: Options{}, wantLines: 3},
+		{name: "Debug", opts: Options{Level: "DEBUG"}, wantLines: 4},
+		{name: "JSON", opts: Options{Format: FormatJSON, Level: "warn"}, wantLines: 2, wantJSON: true},
+		{name: "Quiet", opts: Options{Level: "debug", Quiet: true}, wantLines: 2},
+		{name: "QuietError", opts: Options{Level: "error", Quiet: true}, wantLines: 1},
+		{name: "InvalidFormat", opts: Options{Format: "xml"}, wantErr: true},
+		{name: "InvalidLevel", opts: Options{Level: "trace"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			l, err := New(&b, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			l.Debug("debug")
+			l.Info("info", "check", "vulcan-tls")
+			l.Warn("warn")
+			l.Error("error")
+			lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+			if len(lines) != tt.wantLines {
+				t.Fatalf("got %d records, want %d:\n%s", len(lines), tt.wantLines, b.String())
+			}
+			for _, line := range lines {
+				if got := json.Valid([]byte(line)); got != tt.wantJSON {
+					t.Errorf("got json %v, want %v: %s", got, tt.wantJSON, line)
+				}
+			}
+		})
+	}
+}
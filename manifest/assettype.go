@@ -0,0 +1,224 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// AssetType defines the valid types of assets a check can accept. Besides
+// the built-in asset types defined below, new ones can be added with
+// RegisterAssetType.
+type AssetType int
+
+const (
+	// IP represents an IP assettype.
+	IP AssetType = iota
+	// Hostname represents a hostname assettype.
+	Hostname
+	// DomainName represents an domain name assettype.
+	DomainName
+	// AWSAccount represents an AWS account assettype.
+	AWSAccount
+	// IPRange represents an IP range assettype.
+	IPRange
+	// DockerImage represents a DockerImage asset type.
+	DockerImage
+	// WebAddress represents a WebAddress asset type.
+	WebAddress
+	// GitRepository represents a git repo asset type.
+	GitRepository
+	// GCPProject represents a GCP Project asset type.
+	GCPProject
+
+	numBuiltinAssetTypes = iota
+)
+
+// AssetTypeInfo describes an asset type.
+type AssetTypeInfo struct {
+	Name        string
+	Description string
+	// Validate returns an error if the target is not a valid asset of this
+	// type. If nil, all the targets are considered valid.
+	Validate func(target string) error
+}
+
+var (
+	hostnameRegexp      = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*\.?$`)
+	awsAccountRegexp    = regexp.MustCompile(`^arn:aws:iam::[0-9]{12}:root$`)
+	dockerImageRegexp   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/:@-]*$`)
+	gitRepositoryRegexp = regexp.MustCompile(`^((https?|ssh|git)://\S+|[a-zA-Z0-9._-]+@[a-zA-Z0-9.-]+:\S+)$`)
+	gcpProjectRegexp    = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+)
+
+// assetTypes is the registry of the asset types, indexed by AssetType.
+var assetTypes = struct {
+	sync.RWMutex
+	infos  []AssetTypeInfo
+	byName map[string]AssetType
+}{
+	infos: []AssetTypeInfo{
+		IP: {Name: "IP", Description: "An IP address.", Validate: func(t string) error {
+			if net.ParseIP(t) == nil {
+				return errors.New("not a valid IP address")
+			}
+			return nil
+		}},
+		Hostname:   {Name: "Hostname", Description: "A hostname.", Validate: patternValidator(hostnameRegexp)},
+		DomainName: {Name: "DomainName", Description: "A DNS domain name.", Validate: patternValidator(hostnameRegexp)},
+		AWSAccount: {Name: "AWSAccount", Description: "The ARN of the root user of an AWS account.", Validate: patternValidator(awsAccountRegexp)},
+		IPRange: {Name: "IPRange", Description: "A range of IP addresses in CIDR notation.", Validate: func(t string) error {
+			if _, _, err := net.ParseCIDR(t); err != nil {
+				return errors.New("not a valid CIDR")
+			}
+			return nil
+		}},
+		DockerImage: {Name: "DockerImage", Description: "A reference to a docker image.", Validate: patternValidator(dockerImageRegexp)},
+		WebAddress: {Name: "WebAddress", Description: "The http or https URL of a web application.", Validate: func(t string) error {
+			u, err := url.Parse(t)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.New("not a valid http url")
+			}
+			return nil
+		}},
+		GitRepository: {Name: "GitRepository", Description: "The URL of a git repository.", Validate: patternValidator(gitRepositoryRegexp)},
+		GCPProject:    {Name: "GCPProject", Description: "The ID of a GCP project.", Validate: patternValidator(gcpProjectRegexp)},
+	},
+	byName: map[string]AssetType{
+		"IP":            IP,
+		"Hostname":      Hostname,
+		"DomainName":    DomainName,
+		"AWSAccount":    AWSAccount,
+		"IPRange":       IPRange,
+		"DockerImage":   DockerImage,
+		"WebAddress":    WebAddress,
+		"GitRepository": GitRepository,
+		"GCPProject":    GCPProject,
+	},
+}
+
+func patternValidator(re *regexp.Regexp) func(string) error {
+	return func(target string) error {
+		if !re.MatchString(target) {
+			return fmt.Errorf("doesn't match the pattern %s", re)
+		}
+		return nil
+	}
+}
+
+// RegisterAssetType adds a new asset type to the registry and returns it.
+// Registering an asset type with the name of one already registered replaces
+// its definition, unless it's a built-in asset type.
+func RegisterAssetType(info AssetTypeInfo) (AssetType, error) {
+	if info.Name == "" {
+		return 0, errors.New("the name of the asset type is mandatory")
+	}
+	assetTypes.Lock()
+	defer assetTypes.Unlock()
+	if a, ok := assetTypes.byName[info.Name]; ok {
+		if a < numBuiltinAssetTypes {
+			return 0, fmt.Errorf("can not redefine the built-in asset type %s", info.Name)
+		}
+		assetTypes.infos[a] = info
+		return a, nil
+	}
+	a := AssetType(len(assetTypes.infos))
+	assetTypes.infos = append(assetTypes.infos, info)
+	assetTypes.byName[info.Name] = a
+	return a, nil
+}
+
+// RegisterAssetTypePattern adds a new asset type whose targets must match
+// the given regular expression. If the pattern is empty the targets are not
+// validated.
+func RegisterAssetTypePattern(name, description, pattern string) (AssetType, error) {
+	info := AssetTypeInfo{Name: name, Description: description}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return 0, fmt.Errorf("invalid pattern of the asset type %s: %w", name, err)
+		}
+		info.Validate = patternValidator(re)
+	}
+	return RegisterAssetType(info)
+}
+
+// LookupAssetType returns the asset type with the given name.
+func LookupAssetType(name string) (AssetType, bool) {
+	assetTypes.RLock()
+	defer assetTypes.RUnlock()
+	a, ok := assetTypes.byName[name]
+	return a, ok
+}
+
+// RegisteredAssetTypes returns the info of all the asset types registered,
+// sorted by name.
+func RegisteredAssetTypes() []AssetTypeInfo {
+	assetTypes.RLock()
+	infos := append([]AssetTypeInfo(nil), assetTypes.infos...)
+	assetTypes.RUnlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Info returns the info of an asset type.
+func (a AssetType) Info() (AssetTypeInfo, bool) {
+	assetTypes.RLock()
+	defer assetTypes.RUnlock()
+	if a < 0 || int(a) >= len(assetTypes.infos) {
+		return AssetTypeInfo{}, false
+	}
+	return assetTypes.infos[a], true
+}
+
+// ValidateTarget returns an error if the target is not a valid asset of the
+// asset type.
+func (a AssetType) ValidateTarget(target string) error {
+	info, ok := a.Info()
+	if !ok {
+		return fmt.Errorf("value: %d is not a valid AssetType", a)
+	}
+	if info.Validate == nil {
+		return nil
+	}
+	if err := info.Validate(target); err != nil {
+		return fmt.Errorf("invalid %s target %q: %w", info.Name, target, err)
+	}
+	return nil
+}
+
+// MarshalText returns string representation of a AssetType instance.
+func (a *AssetType) MarshalText() (text []byte, err error) {
+	s, err := a.String()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText creates a AssetType from its string representation.
+func (a *AssetType) UnmarshalText(text []byte) error {
+	val := string(text)
+	at, ok := LookupAssetType(val)
+	if !ok {
+		return fmt.Errorf("Error value %s is not a valid AssetType value", val)
+	}
+	*a = at
+	return nil
+}
+
+func (a *AssetType) String() (string, error) {
+	info, ok := a.Info()
+	if !ok {
+		return "", fmt.Errorf("value: %d is not a valid string representation of AssetType", *a)
+	}
+	return info.Name, nil
+}
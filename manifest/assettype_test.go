@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package manifest
+
+import (
+	"testing"
+)
+
+func TestRegisterAssetType(t *testing.T) {
+	tests := []struct {
+		name        string
+		assetType   string
+		pattern     string
+		wantErr     bool
+		validTarget string
+		badTarget   string
+	}{
+		{
+			name:        "New",
+			assetType:   "AzureSubscription",
+			pattern:     `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`,
+			validTarget: "0b1f6472-1e2f-4c4a-9d3e-8d4f2a6b1c2d",
+			badTarget:   "subscription",
+		},
+		{
+			name:        "Redefine",
+			assetType:   "AzureSubscription",
+			validTarget: "subscription",
+		},
+		{
+			name:      "BuiltIn",
+			assetType: "Hostname",
+			wantErr:   true,
+		},
+		{
+			name:      "InvalidPattern",
+			assetType: "KubernetesCluster",
+			pattern:   `^(`,
+			wantErr:   true,
+		},
+		{
+			name:    "EmptyName",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := RegisterAssetTypePattern(tt.assetType, "", tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RegisterAssetTypePattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, ok := LookupAssetType(tt.assetType)
+			if !ok || got != a {
+				t.Fatalf("LookupAssetType() got %v, %v, want %v, true", got, ok, a)
+			}
+			var unmarshaled AssetType
+			if err := unmarshaled.UnmarshalText([]byte(tt.assetType)); err != nil || unmarshaled != a {
+				t.Errorf("UnmarshalText() got %v, %v, want %v", unmarshaled, err, a)
+			}
+			if err := a.ValidateTarget(tt.validTarget); err != nil {
+				t.Errorf("ValidateTarget(%q) unexpected error: %v", tt.validTarget, err)
+			}
+			if tt.badTarget != "" {
+				if err := a.ValidateTarget(tt.badTarget); err == nil {
+					t.Errorf("ValidateTarget(%q) expected error", tt.badTarget)
+				}
+			}
+		})
+	}
+}
+
+func TestAssetTypeValidateTarget(t *testing.T) {
+	tests := []struct {
+		assetType AssetType
+		target    string
+		wantErr   bool
+	}{
+		{assetType: IP, target: "192.0.2.1"},
+		{assetType: IP, target: "example.com", wantErr: true},
+		{assetType: Hostname, target: "www.example.com"},
+		{assetType: Hostname, target: "https://www.example.com", wantErr: true},
+		{assetType: AWSAccount, target: "arn:aws:iam::123456789012:root"},
+		{assetType: AWSAccount, target: "123456789012", wantErr: true},
+		{assetType: IPRange, target: "192.0.2.0/24"},
+		{assetType: IPRange, target: "192.0.2.1", wantErr: true},
+		{assetType: DockerImage, target: "registry.example.com/vulcan-checks/vulcan-nessus:3"},
+		{assetType: WebAddress, target: "https://www.example.com/app"},
+		{assetType: WebAddress, target: "www.example.com", wantErr: true},
+		{assetType: GitRepository, target: "git@github.com:adevinta/vulcan-checks.git"},
+		{assetType: GCPProject, target: "my-project-123"},
+		{assetType: GCPProject, target: "My Project", wantErr: true},
+		{assetType: AssetType(1000), target: "anything", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := tt.assetType.ValidateTarget(tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTarget(%q) for asset type %d error = %v, wantErr %v", tt.target, tt.assetType, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Change is a difference between two manifests.
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, quoteEmpty(c.Old), quoteEmpty(c.New))
+}
+
+func quoteEmpty(s string) string {
+	if s == "" {
+		return `""`
+	}
+	return s
+}
+
+// Diff compares two manifests semantically and returns the fields that
+// differ. The Options are compared as json objects and the order of the
+// values of the list fields is ignored.
+func Diff(old, new Data) []Change {
+	var changes []Change
+	str := func(field, o, n string) {
+		if o != n {
+			changes = append(changes, Change{Field: field, Old: o, New: n})
+		}
+	}
+	num := func(field string, o, n int) {
+		if o != n {
+			changes = append(changes, Change{Field: field, Old: strconv.Itoa(o), New: strconv.Itoa(n)})
+		}
+	}
+	set := func(field string, o, n []string) {
+		osorted, nsorted := sortedCopy(o), sortedCopy(n)
+		if !reflect.DeepEqual(dedup(osorted), dedup(nsorted)) {
+			changes = append(changes, Change{Field: field, Old: strings.Join(osorted, ", "), New: strings.Join(nsorted, ", ")})
+		}
+	}
+	options := func(field, o, n string) {
+		if !equalOptions(o, n) {
+			changes = append(changes, Change{Field: field, Old: o, New: n})
+		}
+	}
+
+	str("Description", old.Description, new.Description)
+	num("Timeout", old.Timeout, new.Timeout)
+	options("Options", old.Options, new.Options)
+	set("RequiredVars", old.RequiredVars, new.RequiredVars)
+	str("QueueName", old.QueueName, new.QueueName)
+	oldTypes, _ := old.AssetTypes.Strings()
+	newTypes, _ := new.AssetTypes.Strings()
+	set("AssetTypes", oldTypes, newTypes)
+	str("Owner", old.Owner, new.Owner)
+	str("Contact", old.Contact, new.Contact)
+	set("Tags", old.Tags, new.Tags)
+	str("DocsURL", old.DocsURL, new.DocsURL)
+	str("Version", old.Version, new.Version)
+	str("Deprecated", strconv.FormatBool(old.Deprecated), strconv.FormatBool(new.Deprecated))
+	set("VulnerabilityClasses", old.VulnerabilityClasses, new.VulnerabilityClasses)
+	str("MinSeverity", old.MinSeverity, new.MinSeverity)
+	str("MaxSeverity", old.MaxSeverity, new.MaxSeverity)
+
+	names := appendMissing(old.overriddenAssetTypes(), new.overriddenAssetTypes()...)
+	for _, name := range sortedCopy(names) {
+		o, n := old.AssetTypeOverrides[name], new.AssetTypeOverrides[name]
+		prefix := "AssetTypeOverrides." + name + "."
+		options(prefix+"Options", o.Options, n.Options)
+		set(prefix+"RequiredVars", o.RequiredVars, n.RequiredVars)
+		num(prefix+"Timeout", o.Timeout, n.Timeout)
+	}
+	return changes
+}
+
+func equalOptions(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var av, bv interface{}
+	if json.Unmarshal([]byte(a), &av) != nil || json.Unmarshal([]byte(b), &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// dedup removes the consecutive duplicated values of a sorted slice.
+func dedup(values []string) []string {
+	var res []string
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the manifests supported by Decode and Encode.
+const (
+	FormatTOML = "toml"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// FormatFromPath returns the format of a manifest file according to its
+// extension, toml if the extension is unknown.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatTOML
+}
+
+// Encode writes a manifest in the given format. The toml format is the
+// canonical one, see Format.
+func Encode(w io.Writer, d Data, format string) error {
+	switch format {
+	case FormatTOML:
+		content, err := Format(d)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("invalid manifest format %q", format)
+}
+
+// Format returns the canonical toml representation of a manifest: the fields
+// are written in the order they are defined in Data, the empty ones are
+// omitted, strings are double quoted, except the Options, that are single
+// quoted when possible to not escape the quotes of the json, and the asset
+// type overrides are written as tables sorted by asset type.
+func Format(d Data) ([]byte, error) {
+	assetTypes, err := d.AssetTypes.Strings()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	kv := func(key, value string) {
+		fmt.Fprintf(&b, "%s = %s\n", key, value)
+	}
+	str := func(key, value string) {
+		if value != "" {
+			kv(key, tomlString(value))
+		}
+	}
+	arr := func(key string, values []string) {
+		if len(values) > 0 {
+			kv(key, tomlArray(values))
+		}
+	}
+	kv("Description", tomlString(d.Description))
+	if d.Timeout != 0 {
+		kv("Timeout", strconv.Itoa(d.Timeout))
+	}
+	if d.Options != "" {
+		kv("Options", tomlOptions(d.Options))
+	}
+	arr("RequiredVars", d.RequiredVars)
+	str("QueueName", d.QueueName)
+	arr("AssetTypes", assetTypes)
+	str("Owner", d.Owner)
+	str("Contact", d.Contact)
+	arr("Tags", d.Tags)
+	str("DocsURL", d.DocsURL)
+	str("Version", d.Version)
+	if d.Deprecated {
+		kv("Deprecated", "true")
+	}
+	arr("VulnerabilityClasses", d.VulnerabilityClasses)
+	str("MinSeverity", d.MinSeverity)
+	str("MaxSeverity", d.MaxSeverity)
+	for _, name := range d.overriddenAssetTypes() {
+		o := d.AssetTypeOverrides[name]
+		fmt.Fprintf(&b, "\n[AssetTypeOverrides.%s]\n", tomlKey(name))
+		if o.Options != "" {
+			kv("Options", tomlOptions(o.Options))
+		}
+		arr("RequiredVars", o.RequiredVars)
+		if o.Timeout != 0 {
+			kv("Timeout", strconv.Itoa(o.Timeout))
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// HasComments reports whether a toml manifest has comments, which are lost
+// when it's rewritten by Format.
+func HasComments(content []byte) bool {
+	s := string(content)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '#':
+			return true
+		case '"', '\'':
+			delim := s[i : i+1]
+			if strings.HasPrefix(s[i:], strings.Repeat(delim, 3)) {
+				delim = strings.Repeat(delim, 3)
+			}
+			i = stringEnd(s, i+len(delim), delim)
+		}
+	}
+	return false
+}
+
+// stringEnd returns the index of the last character of the toml string,
+// delimited by delim, whose content starts at i in s.
+func stringEnd(s string, i int, delim string) int {
+	for ; i < len(s); i++ {
+		if s[i] == '\\' && delim[0] == '"' {
+			i++
+			continue
+		}
+		if s[i] == '\n' && len(delim) == 1 {
+			return i
+		}
+		if strings.HasPrefix(s[i:], delim) {
+			// A multi-line string can end with up to two quotes.
+			end := i + len(delim)
+			for len(delim) == 3 && end < len(s) && s[end] == delim[0] && end-i < 5 {
+				end++
+			}
+			return end - 1
+		}
+	}
+	return len(s) - 1
+}
+
+var bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if bareKeyRegexp.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+// tomlString returns a toml basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlOptions returns a toml literal string, if the options can be
+// represented as one, or a basic string.
+func tomlOptions(s string) string {
+	if strings.ContainsAny(s, "'\n\r") || strings.IndexFunc(s, isControl) >= 0 {
+		return tomlString(s)
+	}
+	return "'" + s + "'"
+}
+
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t') || r == 0x7f
+}
+
+func tomlArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = tomlString(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// sortedCopy returns a sorted copy of a slice of strings.
+func sortedCopy(values []string) []string {
+	res := append([]string(nil), values...)
+	sort.Strings(res)
+	return res
+}
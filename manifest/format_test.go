@@ -0,0 +1,189 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []string{"HappyPath", "Metadata", "AssetTypeOverrides", "WebAddressAssetType"}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := Read(fmt.Sprintf("testdata/%s/manifest.toml", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			goldenFilePath := fmt.Sprintf("testdata/%sCanonical.toml", name)
+			if *update {
+				if err := os.WriteFile(goldenFilePath, got, 0644); err != nil {
+					t.Fatalf("Error writing golden file %v", err)
+				}
+			}
+			want, err := os.ReadFile(goldenFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Format() got:\n%s\nwant:\n%s", got, want)
+			}
+
+			// Formatting a canonical manifest must not change it.
+			canonical, err := Decode(got, FormatTOML)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := Format(canonical)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again, got) {
+				t.Errorf("Format() is not idempotent, got:\n%s\nwant:\n%s", again, got)
+			}
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	d, err := Read("testdata/AssetTypeOverrides/manifest.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Owner = "purple-team"
+	d.Deprecated = true
+	for _, format := range []string{FormatTOML, FormatJSON, FormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, d, format); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(buf.Bytes(), format)
+			if err != nil {
+				t.Fatalf("Decode() error: %v, content:\n%s", err, buf.String())
+			}
+			if changes := Diff(d, got); len(changes) > 0 {
+				t.Errorf("Decode(Encode()) changed the manifest: %v", changes)
+			}
+		})
+	}
+}
+
+func TestHasComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "NoComments", content: "Description = \"desc\"\nTimeout = 10\n", want: false},
+		{name: "Line", content: "# The check.\nDescription = \"desc\"\n", want: true},
+		{name: "EndOfLine", content: "Description = \"desc\" # The check.\n", want: true},
+		{name: "BasicString", content: "Description = \"Checks the #1 \\\"issue\\\"\"\n", want: false},
+		{name: "LiteralString", content: "Options = '{\"tag\": \"#\"}'\n", want: false},
+		{name: "MultiLineString", content: "Description = \"\"\"\nChecks\n# not a comment\"\"\"\"\n", want: false},
+		{name: "AfterMultiLineString", content: "Description = '''\ndesc'''\n# comment\n", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasComments([]byte(tt.content)); got != tt.want {
+				t.Errorf("HasComments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeJSONOmitsEmptyFields(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, Data{Description: "desc"}, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"Description\": \"desc\"\n}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Encode() got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+	}{
+		{name: "JSONWithoutDescription", content: `{"Timeout": 10}`, format: FormatJSON},
+		{name: "YAMLInvalidOptions", content: "Description: desc\nOptions: '{'\n", format: FormatYAML},
+		{name: "YAMLInvalidAssetType", content: "Description: desc\nAssetTypes: [Unknown]\n", format: FormatYAML},
+		{name: "UnknownFormat", content: "Description = \"desc\"", format: "xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode([]byte(tt.content), tt.format); err == nil {
+				t.Error("Decode() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	hostname, ip := Hostname, IP
+	base := Data{
+		Description:  "Description",
+		Timeout:      300,
+		Options:      `{"port": 443, "depth": 1}`,
+		RequiredVars: []string{"A", "B"},
+		AssetTypes:   AssetTypes{&hostname, &ip},
+	}
+	tests := []struct {
+		name string
+		new  Data
+		want []Change
+	}{
+		{
+			name: "Equivalent",
+			new: Data{
+				Description:  "Description",
+				Timeout:      300,
+				Options:      `{"depth":1,"port":443}`,
+				RequiredVars: []string{"B", "A"},
+				AssetTypes:   AssetTypes{&ip, &hostname},
+			},
+		},
+		{
+			name: "Changed",
+			new: Data{
+				Description:  "Description",
+				Timeout:      600,
+				Options:      `{"port": 8443, "depth": 1}`,
+				RequiredVars: []string{"A"},
+				AssetTypes:   AssetTypes{&hostname, &ip},
+				Owner:        "purple-team",
+				AssetTypeOverrides: map[string]AssetTypeOverride{
+					"Hostname": {Timeout: 900},
+				},
+			},
+			want: []Change{
+				{Field: "Timeout", Old: "300", New: "600"},
+				{Field: "Options", Old: `{"port": 443, "depth": 1}`, New: `{"port": 8443, "depth": 1}`},
+				{Field: "RequiredVars", Old: "A, B", New: "A"},
+				{Field: "Owner", Old: "", New: "purple-team"},
+				{Field: "AssetTypeOverrides.Hostname.Timeout", Old: "0", New: "900"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(base, tt.new)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,76 +7,17 @@ package manifest
 import (
 	"encoding/json"
 	"errors"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
 
 	"fmt"
 
 	"github.com/manelmontilla/toml"
+	"gopkg.in/yaml.v3"
 )
 
-// AssetType defines the valid types of assets a check can accept.
-type AssetType int
-
-const (
-	// IP represents an IP assettype.
-	IP AssetType = iota
-	// Hostname represents a hostname assettype.
-	Hostname
-	// DomainName represents an domain name assettype.
-	DomainName
-	// AWSAccount represents an AWS account assettype.
-	AWSAccount
-	// IPRange represents an IP range assettype.
-	IPRange
-	// DockerImage represents a DockerImage asset type.
-	DockerImage
-	// WebAddress represents a WebAddress asset type.
-	WebAddress
-	// GitRepository represents a git repo asset type.
-	GitRepository
-	// GCPProject represents a GCP Project asset type.
-	GCPProject
-)
-
-var assetTypeStrings = map[AssetType]string{
-	IP:            "IP",
-	Hostname:      "Hostname",
-	DomainName:    "DomainName",
-	AWSAccount:    "AWSAccount",
-	IPRange:       "IPRange",
-	DockerImage:   "DockerImage",
-	WebAddress:    "WebAddress",
-	GitRepository: "GitRepository",
-	GCPProject:    "GCPProject",
-}
-
-// MarshalText returns string representation of a AssetType instance.
-func (a *AssetType) MarshalText() (text []byte, err error) {
-	s, err := a.String()
-	if err != nil {
-		return nil, err
-	}
-	return []byte(s), nil
-}
-
-// UnmarshalText creates a AssetType from its string representation.
-func (a *AssetType) UnmarshalText(text []byte) error {
-	val := string(text)
-	for k, v := range assetTypeStrings {
-		if v == val {
-			*a = k
-			return nil
-		}
-	}
-	return fmt.Errorf("Error value %s is not a valid AssetType value", val)
-}
-
-func (a *AssetType) String() (string, error) {
-	if _, ok := assetTypeStrings[*a]; !ok {
-		return "", fmt.Errorf("value: %d is not a valid string representation of AssetType", a)
-	}
-	return assetTypeStrings[*a], nil
-}
-
 // AssetTypes represents and array of asset types supported by a concrete
 // checktype.
 type AssetTypes []*AssetType
@@ -94,34 +35,309 @@ func (a AssetTypes) Strings() ([]string, error) {
 	return res, nil
 }
 
+// Severities are the valid values of the MinSeverity and MaxSeverity fields
+// of a manifest, sorted from lowest to highest.
+var Severities = []string{"INFO", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
 // Data contains all the data defined in the manifest.
 type Data struct {
-	Description  string
-	Timeout      int
-	Options      string
-	RequiredVars []string
-	QueueName    string
-	AssetTypes   AssetTypes
+	Description  string     `yaml:"Description"`
+	Timeout      int        `json:",omitempty" yaml:"Timeout,omitempty"`
+	Options      string     `json:",omitempty" yaml:"Options,omitempty"`
+	RequiredVars []string   `json:",omitempty" yaml:"RequiredVars,omitempty"`
+	QueueName    string     `json:",omitempty" yaml:"QueueName,omitempty"`
+	AssetTypes   AssetTypes `json:",omitempty" yaml:"AssetTypes,omitempty"`
+
+	// Metadata of the check used to build check catalogs. All the fields
+	// are optional.
+	Owner                string   `json:",omitempty" yaml:"Owner,omitempty"`
+	Contact              string   `json:",omitempty" yaml:"Contact,omitempty"`
+	Tags                 []string `json:",omitempty" yaml:"Tags,omitempty"`
+	DocsURL              string   `json:",omitempty" yaml:"DocsURL,omitempty"`
+	Version              string   `json:",omitempty" yaml:"Version,omitempty"`
+	Deprecated           bool     `json:",omitempty" yaml:"Deprecated,omitempty"`
+	VulnerabilityClasses []string `json:",omitempty" yaml:"VulnerabilityClasses,omitempty"`
+	MinSeverity          string   `json:",omitempty" yaml:"MinSeverity,omitempty"`
+	MaxSeverity          string   `json:",omitempty" yaml:"MaxSeverity,omitempty"`
+
+	// AssetTypeOverrides overrides the Options, RequiredVars and Timeout
+	// of the check for the targets of the given asset types.
+	AssetTypeOverrides map[string]AssetTypeOverride `json:",omitempty" yaml:"AssetTypeOverrides,omitempty"`
 }
 
-// Read reads a manifest file.
+// AssetTypeOverride contains the fields of the manifest that can be
+// overridden for an asset type. The Options are merged with the Options of
+// the manifest and the RequiredVars are added to its RequiredVars.
+type AssetTypeOverride struct {
+	Options      string   `json:",omitempty" yaml:"Options,omitempty"`
+	RequiredVars []string `json:",omitempty" yaml:"RequiredVars,omitempty"`
+	Timeout      int      `json:",omitempty" yaml:"Timeout,omitempty"`
+}
+
+// Read reads a manifest file. The format of the file, toml, json or yaml, is
+// determined by its extension. Files with other extensions are read as toml.
 func Read(path string) (Data, error) {
-	d := Data{}
-	m, err := toml.DecodeFile(path, &d)
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return d, err
+		return Data{}, err
+	}
+	return Decode(content, FormatFromPath(path))
+}
+
+// Decode decodes and validates a manifest in the given format.
+func Decode(content []byte, format string) (Data, error) {
+	d := Data{}
+	var defined func(key string) bool
+	switch format {
+	case FormatTOML:
+		m, err := toml.Decode(string(content), &d)
+		if err != nil {
+			return d, err
+		}
+		defined = func(key string) bool { return m.IsDefined(key) }
+	case FormatJSON, FormatYAML:
+		unmarshal := json.Unmarshal
+		if format == FormatYAML {
+			unmarshal = yaml.Unmarshal
+		}
+		if err := unmarshal(content, &d); err != nil {
+			return d, err
+		}
+		// The manifest labels of the older images include the empty
+		// fields of the manifest, so they are considered not defined.
+		var fields map[string]interface{}
+		if err := unmarshal(content, &fields); err != nil {
+			return d, err
+		}
+		defined = func(key string) bool {
+			v, ok := fields[key]
+			return ok && v != nil && v != ""
+		}
+	default:
+		return d, fmt.Errorf("invalid manifest format %q", format)
 	}
-	if !m.IsDefined("Description") {
+	if !defined("Description") {
 		return d, errors.New("Description field is mandatory")
 	}
 
-	if m.IsDefined("Options") {
+	if defined("Options") {
 		dummy := make(map[string]interface{})
-		err = json.Unmarshal([]byte(d.Options), &dummy)
+		err := json.Unmarshal([]byte(d.Options), &dummy)
 		if err != nil {
 			err = fmt.Errorf("Error reading manifest file, Options field is not a valid json: %v", err)
 			return d, err
 		}
 	}
+	if err := d.validateMetadata(); err != nil {
+		return d, fmt.Errorf("Error reading manifest file, %v", err)
+	}
+	if err := d.validateOverrides(); err != nil {
+		return d, fmt.Errorf("Error reading manifest file, %v", err)
+	}
 	return d, nil
 }
+
+func (d Data) validateOverrides() error {
+	assetTypes, err := d.AssetTypes.Strings()
+	if err != nil {
+		return err
+	}
+	for name, o := range d.AssetTypeOverrides {
+		if _, ok := LookupAssetType(name); !ok {
+			return fmt.Errorf("AssetTypeOverrides contains the invalid asset type %s", name)
+		}
+		found := false
+		for _, a := range assetTypes {
+			if a == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("AssetTypeOverrides contains the asset type %s, not defined in AssetTypes", name)
+		}
+		if o.Options != "" {
+			dummy := make(map[string]interface{})
+			if err := json.Unmarshal([]byte(o.Options), &dummy); err != nil {
+				return fmt.Errorf("Options field of the %s override is not a valid json: %v", name, err)
+			}
+		}
+		if o.Timeout < 0 {
+			return fmt.Errorf("Timeout field of the %s override is negative", name)
+		}
+	}
+	return nil
+}
+
+// ForAssetType returns the data of the manifest that applies to the targets
+// of the given asset type, that is the data of the manifest with the
+// override of the asset type, if any, applied.
+func (d Data) ForAssetType(assetType string) (Data, error) {
+	res := d
+	res.AssetTypeOverrides = nil
+	o, ok := d.AssetTypeOverrides[assetType]
+	if !ok {
+		return res, nil
+	}
+	options, err := MergeOptions(d.Options, o.Options)
+	if err != nil {
+		return Data{}, err
+	}
+	res.Options = options
+	res.RequiredVars = appendMissing(append([]string(nil), d.RequiredVars...), o.RequiredVars...)
+	if o.Timeout > 0 {
+		res.Timeout = o.Timeout
+	}
+	return res, nil
+}
+
+// AllRequiredVars returns the RequiredVars of the manifest plus the ones of
+// all the asset type overrides.
+func (d Data) AllRequiredVars() []string {
+	vars := append([]string(nil), d.RequiredVars...)
+	for _, name := range d.overriddenAssetTypes() {
+		vars = appendMissing(vars, d.AssetTypeOverrides[name].RequiredVars...)
+	}
+	return vars
+}
+
+// MaxTimeout returns the highest of the Timeout of the manifest and the
+// ones of the asset type overrides.
+func (d Data) MaxTimeout() int {
+	timeout := d.Timeout
+	for _, o := range d.AssetTypeOverrides {
+		if o.Timeout > timeout {
+			timeout = o.Timeout
+		}
+	}
+	return timeout
+}
+
+// AssetTypeOptions returns the Options, merged with the ones of the
+// manifest, of the asset types whose override defines Options.
+func (d Data) AssetTypeOptions() (map[string]string, error) {
+	var res map[string]string
+	for _, name := range d.overriddenAssetTypes() {
+		o := d.AssetTypeOverrides[name]
+		if o.Options == "" {
+			continue
+		}
+		options, err := MergeOptions(d.Options, o.Options)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = make(map[string]string)
+		}
+		res[name] = options
+	}
+	return res, nil
+}
+
+func (d Data) overriddenAssetTypes() []string {
+	var names []string
+	for name := range d.AssetTypeOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MergeOptions returns the json object resulting of setting the fields of
+// the override options in the base options.
+func MergeOptions(base, override string) (string, error) {
+	if override == "" {
+		return base, nil
+	}
+	if base == "" {
+		return override, nil
+	}
+	merged := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(base), &merged); err != nil {
+		return "", err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(override), &fields); err != nil {
+		return "", err
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func appendMissing(values []string, add ...string) []string {
+	for _, v := range add {
+		found := false
+		for _, existing := range values {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (d Data) validateMetadata() error {
+	if d.DocsURL != "" {
+		u, err := url.Parse(d.DocsURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("DocsURL field is not a valid http url: %s", d.DocsURL)
+		}
+	}
+	if err := validateList("Tags", d.Tags); err != nil {
+		return err
+	}
+	if err := validateList("VulnerabilityClasses", d.VulnerabilityClasses); err != nil {
+		return err
+	}
+	min, max := 0, len(Severities)-1
+	var err error
+	if d.MinSeverity != "" {
+		if min, err = severityIndex("MinSeverity", d.MinSeverity); err != nil {
+			return err
+		}
+	}
+	if d.MaxSeverity != "" {
+		if max, err = severityIndex("MaxSeverity", d.MaxSeverity); err != nil {
+			return err
+		}
+	}
+	if min > max {
+		return fmt.Errorf("MinSeverity %s is higher than MaxSeverity %s", d.MinSeverity, d.MaxSeverity)
+	}
+	return nil
+}
+
+// validateList returns an error if a list field contains empty or duplicated
+// values.
+func validateList(field string, values []string) error {
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if strings.TrimSpace(v) == "" {
+			return fmt.Errorf("%s field contains an empty value", field)
+		}
+		if seen[v] {
+			return fmt.Errorf("%s field contains the duplicated value %s", field, v)
+		}
+		seen[v] = true
+	}
+	return nil
+}
+
+func severityIndex(field, severity string) (int, error) {
+	for i, s := range Severities {
+		if s == severity {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%s field must be one of %s, got %s", field, strings.Join(Severities, ", "), severity)
+}
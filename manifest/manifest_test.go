@@ -66,6 +66,44 @@ func TestRead(t *testing.T) {
 				path: "testdata/WebAddressAssetType/manifest.toml",
 			},
 		},
+		{
+			name:           "Metadata",
+			wantGoldenFile: true,
+			args: args{
+				path: "testdata/Metadata/manifest.toml",
+			},
+		},
+		{
+			name:           "AssetTypeOverrides",
+			wantGoldenFile: true,
+			args: args{
+				path: "testdata/AssetTypeOverrides/manifest.toml",
+			},
+		},
+		{
+			name:           "ErrorOverrideUndefinedAssetType",
+			wantGoldenFile: false,
+			args: args{
+				path: "testdata/ErrorOverrideUndefinedAssetType/manifest.toml",
+			},
+			wantErr: true,
+		},
+		{
+			name:           "ErrorInvalidDocsURL",
+			wantGoldenFile: false,
+			args: args{
+				path: "testdata/ErrorInvalidDocsURL/manifest.toml",
+			},
+			wantErr: true,
+		},
+		{
+			name:           "ErrorInvalidSeverityRange",
+			wantGoldenFile: false,
+			args: args{
+				path: "testdata/ErrorInvalidSeverityRange/manifest.toml",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -109,3 +147,67 @@ func TestRead(t *testing.T) {
 		})
 	}
 }
+
+func TestDataAssetTypeOverrides(t *testing.T) {
+	d, err := Read("testdata/AssetTypeOverrides/manifest.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		assetType        string
+		wantOptions      string
+		wantRequiredVars []string
+		wantTimeout      int
+	}{
+		{
+			name:             "Overridden",
+			assetType:        "WebAddress",
+			wantOptions:      `{"depth":3,"port":443}`,
+			wantRequiredVars: []string{"API_KEY", "CRAWLER_TOKEN"},
+			wantTimeout:      900,
+		},
+		{
+			name:             "NotOverridden",
+			assetType:        "Hostname",
+			wantOptions:      `{"port": 443, "depth": 1}`,
+			wantRequiredVars: []string{"API_KEY"},
+			wantTimeout:      300,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.ForAssetType(tt.assetType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Options != tt.wantOptions {
+				t.Errorf("Options got %s, want %s", got.Options, tt.wantOptions)
+			}
+			if !reflect.DeepEqual(got.RequiredVars, tt.wantRequiredVars) {
+				t.Errorf("RequiredVars got %v, want %v", got.RequiredVars, tt.wantRequiredVars)
+			}
+			if got.Timeout != tt.wantTimeout {
+				t.Errorf("Timeout got %d, want %d", got.Timeout, tt.wantTimeout)
+			}
+			if got.AssetTypeOverrides != nil {
+				t.Errorf("AssetTypeOverrides got %v, want nil", got.AssetTypeOverrides)
+			}
+		})
+	}
+
+	if got, want := d.AllRequiredVars(), []string{"API_KEY", "CRAWLER_TOKEN"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllRequiredVars() got %v, want %v", got, want)
+	}
+	if got := d.MaxTimeout(); got != 900 {
+		t.Errorf("MaxTimeout() got %d, want 900", got)
+	}
+	options, err := d.AssetTypeOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"WebAddress": `{"depth":3,"port":443}`}; !reflect.DeepEqual(options, want) {
+		t.Errorf("AssetTypeOptions() got %v, want %v", options, want)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package naming generates the names of the images and checktypes of the
+// checks built from the dev branches, from templates like
+// "{check}-{branch-slug}".
+package naming
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultDevTemplate is the template used when none is configured. All the
+// dev branches share the same name.
+const DefaultDevTemplate = CheckPlaceholder + "-experimental"
+
+// Placeholders of the templates.
+const (
+	// CheckPlaceholder is replaced by the name of the check. It's
+	// mandatory.
+	CheckPlaceholder = "{check}"
+	// BranchPlaceholder is replaced by the slug of the branch.
+	BranchPlaceholder = "{branch-slug}"
+)
+
+// maxSlugLen is the max length of the slug of a branch, so the names fit in
+// the limits of docker and the persistence service.
+const maxSlugLen = 40
+
+// maxNameLen is the max length of a name.
+const maxNameLen = 128
+
+var (
+	// nameRegexp matches the valid names: lowercase alphanumeric components
+	// separated by a dash, a dot or an underscore, as docker requires.
+	nameRegexp   = regexp.MustCompile(`^[a-z0-9]+([._-][a-z0-9]+)*$`)
+	invalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Slug returns the slug of a branch: the name of the branch in lowercase
+// with the runs of characters that are not letters or digits replaced with a
+// dash and truncated to 40 characters.
+func Slug(branch string) string {
+	slug := invalidChars.ReplaceAllString(strings.ToLower(branch), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > maxSlugLen {
+		slug = strings.TrimRight(slug[:maxSlugLen], "-")
+	}
+	return slug
+}
+
+// ValidateTemplate returns an error if the template doesn't contain the check
+// placeholder once, contains the branch placeholder more than once or
+// doesn't generate valid names.
+func ValidateTemplate(template string) error {
+	if strings.Count(template, CheckPlaceholder) != 1 {
+		return fmt.Errorf("the dev name template %q must contain %s once", template, CheckPlaceholder)
+	}
+	if strings.Count(template, BranchPlaceholder) > 1 {
+		return fmt.Errorf("the dev name template %q can contain %s only once", template, BranchPlaceholder)
+	}
+	if _, err := DevName(template, "check", "branch"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// HasBranch returns true if the names generated by the template depend on the
+// branch.
+func HasBranch(template string) bool {
+	return strings.Contains(template, BranchPlaceholder)
+}
+
+// DevName returns the name of the image and checktype of a check built from a
+// dev branch. An empty template is the DefaultDevTemplate, that is also used
+// when the branch is empty, like in the local builds.
+func DevName(template, check, branch string) (string, error) {
+	if template == "" || branch == "" {
+		template = DefaultDevTemplate
+	}
+	var slug string
+	if HasBranch(template) {
+		if slug = Slug(branch); slug == "" {
+			return "", fmt.Errorf("can not generate a dev name for the branch %q", branch)
+		}
+	}
+	name := strings.NewReplacer(CheckPlaceholder, check, BranchPlaceholder, slug).Replace(template)
+	if len(name) > maxNameLen || !nameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid dev name %q generated by the template %q", name, template)
+	}
+	return name, nil
+}
+
+// Parser finds the check and the branch slug of the dev names generated by a
+// template.
+type Parser struct {
+	re     *regexp.Regexp
+	checks map[string]bool
+}
+
+// NewParser returns a parser of the dev names of the given checks generated
+// by the template. The template must contain the branch placeholder.
+func NewParser(template string, checks []string) (*Parser, error) {
+	if template == "" {
+		template = DefaultDevTemplate
+	}
+	if !HasBranch(template) {
+		return nil, errors.New("the dev name template doesn't contain " + BranchPlaceholder)
+	}
+	return newParser(template, checks)
+}
+
+func newParser(template string, checks []string) (*Parser, error) {
+	if len(checks) == 0 {
+		return nil, errors.New("no checks")
+	}
+	// The longest checks go first so, for instance, vulcan-exposed-http-main
+	// is taken as the branch main of vulcan-exposed-http and not as the
+	// branch http-main of vulcan-exposed.
+	sorted := append([]string(nil), checks...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	quoted := make([]string, len(sorted))
+	known := make(map[string]bool)
+	for i, c := range sorted {
+		quoted[i] = regexp.QuoteMeta(c)
+		known[c] = true
+	}
+	expr := regexp.QuoteMeta(template)
+	expr = strings.Replace(expr, regexp.QuoteMeta(CheckPlaceholder), "(?P<check>"+strings.Join(quoted, "|")+")", 1)
+	expr = strings.Replace(expr, regexp.QuoteMeta(BranchPlaceholder), "(?P<slug>[a-z0-9]+(?:-[a-z0-9]+)*)", 1)
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{re: re, checks: known}, nil
+}
+
+// Parse returns the check and the branch slug of a dev name. It returns
+// false if the name is not a dev name or it's the name of a check.
+func (p *Parser) Parse(name string) (check, slug string, ok bool) {
+	if p.checks[name] {
+		return "", "", false
+	}
+	m := p.re.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+	return m[p.re.SubexpIndex("check")], m[p.re.SubexpIndex("slug")], true
+}
+
+// Check returns the check, from the given ones, of a dev name generated by a
+// template, that may not contain the branch placeholder. It returns false if
+// the name is not a dev name of any of the checks.
+func Check(template, name string, checks []string) (string, bool) {
+	if template == "" {
+		template = DefaultDevTemplate
+	}
+	p, err := newParser(template, checks)
+	if err != nil {
+		return "", false
+	}
+	if p.checks[name] {
+		return "", false
+	}
+	m := p.re.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	return m[p.re.SubexpIndex("check")], true
+}
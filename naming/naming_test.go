@@ -0,0 +1,140 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package naming
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{branch: "feature-x", want: "feature-x"},
+		{branch: "Feature/TLS_1.3", want: "feature-tls-1-3"},
+		{branch: "--fix--", want: "fix"},
+		{branch: "/", want: ""},
+		{branch: strings.Repeat("ab-", 20), want: "ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			if got := Slug(tt.branch); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+	}{
+		{template: DefaultDevTemplate},
+		{template: "{check}-{branch-slug}"},
+		{template: "dev.{branch-slug}.{check}"},
+		{template: "{branch-slug}", wantErr: true},
+		{template: "{check}-{check}", wantErr: true},
+		{template: "{check}-{branch-slug}-{branch-slug}", wantErr: true},
+		{template: "{check}/{branch-slug}", wantErr: true},
+		{template: "{check}-Dev", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			err := ValidateTemplate(tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDevName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		check    string
+		branch   string
+		want     string
+		wantErr  bool
+	}{
+		{name: "Default", check: "vulcan-tls", branch: "feature/x", want: "vulcan-tls-experimental"},
+		{name: "Branch", template: "{check}-{branch-slug}", check: "vulcan-tls", branch: "Feature/X", want: "vulcan-tls-feature-x"},
+		{name: "LocalBuild", template: "{check}-{branch-slug}", check: "vulcan-tls", want: "vulcan-tls-experimental"},
+		{name: "InvalidBranch", template: "{check}-{branch-slug}", check: "vulcan-tls", branch: "/", wantErr: true},
+		{name: "InvalidCheck", template: "{check}-{branch-slug}", check: "Vulcan TLS", branch: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DevName(tt.template, tt.check, tt.branch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	checks := []string{"vulcan-exposed", "vulcan-exposed-http", "vulcan-tls"}
+	p, err := NewParser("{check}-{branch-slug}", checks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		wantCheck string
+		wantSlug  string
+		wantOK    bool
+	}{
+		{name: "vulcan-tls-feature-x", wantCheck: "vulcan-tls", wantSlug: "feature-x", wantOK: true},
+		{name: "vulcan-exposed-http-main", wantCheck: "vulcan-exposed-http", wantSlug: "main", wantOK: true},
+		{name: "vulcan-exposed-fix", wantCheck: "vulcan-exposed", wantSlug: "fix", wantOK: true},
+		{name: "vulcan-exposed-http"},
+		{name: "vulcan-tls"},
+		{name: "vulcan-nessus-feature-x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, slug, ok := p.Parse(tt.name)
+			if check != tt.wantCheck || slug != tt.wantSlug || ok != tt.wantOK {
+				t.Errorf("got %q, %q, %v, want %q, %q, %v", check, slug, ok, tt.wantCheck, tt.wantSlug, tt.wantOK)
+			}
+		})
+	}
+
+	if _, err := NewParser(DefaultDevTemplate, checks); err == nil {
+		t.Error("got no error for a template without the branch")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	checks := []string{"vulcan-exposed", "vulcan-exposed-http", "vulcan-tls"}
+	tests := []struct {
+		template  string
+		name      string
+		wantCheck string
+		wantOK    bool
+	}{
+		{template: "", name: "vulcan-tls-experimental", wantCheck: "vulcan-tls", wantOK: true},
+		{template: DefaultDevTemplate, name: "vulcan-exposed-http-experimental", wantCheck: "vulcan-exposed-http", wantOK: true},
+		{template: "{check}-{branch-slug}", name: "vulcan-exposed-http-main", wantCheck: "vulcan-exposed-http", wantOK: true},
+		{template: DefaultDevTemplate, name: "vulcan-tls"},
+		{template: DefaultDevTemplate, name: "vulcan-nessus-experimental"},
+		{template: "{check}-{branch-slug}", name: "vulcan-tls-Main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template+"/"+tt.name, func(t *testing.T) {
+			check, ok := Check(tt.template, tt.name, checks)
+			if check != tt.wantCheck || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", check, ok, tt.wantCheck, tt.wantOK)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ package persistence
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"gopkg.in/resty.v1"
 )
@@ -25,6 +26,21 @@ type Checktype struct {
 	RequiredVars []string `json:"required_vars"`
 	QueueName    string   `json:"queue_name,omitempty"`
 	Assets       []string `json:"assets"`
+	// AssetTypeOptions contains the default options of the check for the
+	// asset types that override the Options.
+	AssetTypeOptions map[string]string `json:"asset_type_options,omitempty"`
+
+	// Metadata of the check, only sent when the build system is configured
+	// to publish it.
+	Owner                string   `json:"owner,omitempty"`
+	Contact              string   `json:"contact,omitempty"`
+	Tags                 []string `json:"tags,omitempty"`
+	DocsURL              string   `json:"docs_url,omitempty"`
+	Version              string   `json:"version,omitempty"`
+	Deprecated           bool     `json:"deprecated,omitempty"`
+	VulnerabilityClasses []string `json:"vulnerability_classes,omitempty"`
+	MinSeverity          string   `json:"min_severity,omitempty"`
+	MaxSeverity          string   `json:"max_severity,omitempty"`
 }
 type checkTypePostRequest struct {
 	Check Checktype `json:"checktype"`
@@ -33,6 +49,8 @@ type checkTypePostRequest struct {
 // Client used to interface with the persistence service.
 type Client interface {
 	PublishChecktype(Checktype) (*PublishChecktypeResult, error)
+	ListChecktypes() ([]PublishChecktypeResult, error)
+	DeleteChecktype(id string) error
 }
 
 type client struct {
@@ -55,6 +73,33 @@ func (c *client) PublishChecktype(check Checktype) (*PublishChecktypeResult, err
 	return &aux.Checktype, nil
 }
 
+// ListChecktypes returns all the checktypes stored in the persistence
+// service, including the ones that are not enabled.
+func (c *client) ListChecktypes() ([]PublishChecktypeResult, error) {
+	p := c.client.R().SetResult(&ListChecktypesResultMsg{})
+	r, err := p.Get(checktypeBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode() != int(http.StatusOK) {
+		return nil, fmt.Errorf("Error listing the checktypes of the persistence service, status:%v", r.StatusCode())
+	}
+	return p.Result.(*ListChecktypesResultMsg).Checktypes, nil
+}
+
+// DeleteChecktype deletes the checktype with the given id.
+func (c *client) DeleteChecktype(id string) error {
+	r, err := c.client.R().Delete(checktypeBaseURL + "/" + url.PathEscape(id))
+	if err != nil {
+		return err
+	}
+	switch r.StatusCode() {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	}
+	return fmt.Errorf("Error deleting the checktype %s of the persistence service, status:%v", id, r.StatusCode())
+}
+
 // CheckTypeLink handy struct for unmarshal the checktype create response from persistence.
 type CheckTypeLink struct {
 	Self string `json:"self"`
@@ -80,6 +125,12 @@ type PublishChecktypeResultMsg struct {
 	Checktype PublishChecktypeResult `json:"checktype"`
 }
 
+// ListChecktypesResultMsg contains the data returned by a call to
+// ListChecktypes.
+type ListChecktypesResultMsg struct {
+	Checktypes []PublishChecktypeResult `json:"checktypes"`
+}
+
 // NewClient creates a new client for a given end point.
 func NewClient(endPointURL string) Client {
 	restyClient := resty.New()
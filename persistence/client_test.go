@@ -119,3 +119,82 @@ func Test_client_PublishChecktype(t *testing.T) {
 		})
 	}
 }
+
+func Test_client_ListChecktypes(t *testing.T) {
+	checktypes := []PublishChecktypeResult{
+		{ID: "1", Name: "vulcan-nessus", Enabled: false, Image: "vulcan-nessus:2", Assets: []string{"IP"}},
+		{ID: "2", Name: "vulcan-nessus", Enabled: true, Image: "vulcan-nessus:3", Assets: []string{"IP"}},
+	}
+	tests := []struct {
+		name        string
+		mockHandler mockHandleRequest
+		want        []PublishChecktypeResult
+		wantErr     bool
+	}{
+		{
+			name: "HappyPath",
+			mockHandler: func(r *http.Request) (int, interface{}) {
+				if r.Method != http.MethodGet || r.URL.Path != "/"+checktypeBaseURL {
+					return http.StatusBadRequest, nil
+				}
+				return http.StatusOK, ListChecktypesResultMsg{Checktypes: checktypes}
+			},
+			want: checktypes,
+		},
+		{
+			name: "ErrorStatus",
+			mockHandler: func(r *http.Request) (int, interface{}) {
+				return http.StatusInternalServerError, nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newHTTPServerMock(tt.mockHandler)
+			defer mock.Close()
+			got, err := NewClient(mock.URL).ListChecktypes()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_DeleteChecktype(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockHandler mockHandleRequest
+		wantErr     bool
+	}{
+		{
+			name: "HappyPath",
+			mockHandler: func(r *http.Request) (int, interface{}) {
+				if r.Method != http.MethodDelete || r.URL.Path != "/"+checktypeBaseURL+"/2" {
+					return http.StatusBadRequest, nil
+				}
+				return http.StatusOK, nil
+			},
+		},
+		{
+			name: "ErrorStatus",
+			mockHandler: func(r *http.Request) (int, interface{}) {
+				return http.StatusNotFound, nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newHTTPServerMock(tt.mockHandler)
+			defer mock.Close()
+			err := NewClient(mock.URL).DeleteChecktype("2")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
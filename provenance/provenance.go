@@ -0,0 +1,222 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package provenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+)
+
+const (
+	// StatementType is the type of the in-toto statements generated.
+	StatementType = "https://in-toto.io/Statement/v1"
+	// PredicateType is the type of the predicate of the statements generated,
+	// SLSA provenance v1.
+	PredicateType = "https://slsa.dev/provenance/v1"
+	// BuildType identifies the builds made by the vulcan checks build system.
+	BuildType = "https://github.com/adevinta/vulcan-checks-bsys/build/v1"
+	// DefaultBuilderID is the ID of the builder used when no other is
+	// specified.
+	DefaultBuilderID = "https://github.com/adevinta/vulcan-checks-bsys"
+)
+
+// Statement is an in-toto statement about a check image.
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject identifies the artifact a statement refers to.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is a SLSA v1 provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition describes the inputs of a build.
+type BuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	InternalParameters   map[string]interface{} `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies,omitempty"`
+}
+
+// ResourceDescriptor describes an artifact used in a build.
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// RunDetails describes the execution of a build.
+type RunDetails struct {
+	Builder  Builder       `json:"builder"`
+	Metadata BuildMetadata `json:"metadata"`
+}
+
+// Builder identifies the entity that executed a build.
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// BuildMetadata contains the metadata of the execution of a build.
+type BuildMetadata struct {
+	InvocationID string     `json:"invocationId,omitempty"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// BuildInfo contains the info about the build of a check image needed to
+// generate its provenance.
+type BuildInfo struct {
+	// Image is the name of the image without tag, e.g.:
+	// docker.example.com/vulcan-checks/vulcan-nessus.
+	Image string
+	// Digest is the digest of the manifest of the image pushed.
+	Digest     string
+	Tag        string
+	Commit     string
+	SDKVersion string
+	// BuildPlan is the line of the images file that defined the build.
+	BuildPlan     string
+	ContextDigest string
+	// BuilderImage is the image used to build the check binary, if it was
+	// built in a multi-stage build.
+	BuilderImage string
+	BuilderID    string
+	Manifest     manifest.Data
+	StartedOn    time.Time
+	FinishedOn   time.Time
+}
+
+// New returns the provenance statement of a check image.
+func New(info BuildInfo) (Statement, error) {
+	subjectDigest, err := digestSet(info.Digest)
+	if err != nil {
+		return Statement{}, err
+	}
+	builderID := info.BuilderID
+	if builderID == "" {
+		builderID = DefaultBuilderID
+	}
+	// The manifest is included as a json object so it can be compared
+	// with the manifest label of the image.
+	man, err := json.Marshal(info.Manifest)
+	if err != nil {
+		return Statement{}, err
+	}
+	var manifestParam map[string]interface{}
+	if err = json.Unmarshal(man, &manifestParam); err != nil {
+		return Statement{}, err
+	}
+	def := BuildDefinition{
+		BuildType: BuildType,
+		ExternalParameters: map[string]interface{}{
+			"buildPlan": info.BuildPlan,
+			"commit":    info.Commit,
+			"tag":       info.Tag,
+			"manifest":  manifestParam,
+		},
+		InternalParameters: map[string]interface{}{
+			"sdkVersion": info.SDKVersion,
+		},
+	}
+	if info.ContextDigest != "" {
+		d, err := digestSet(info.ContextDigest)
+		if err != nil {
+			return Statement{}, err
+		}
+		def.ResolvedDependencies = append(def.ResolvedDependencies, ResourceDescriptor{
+			Name:   "build-context",
+			Digest: d,
+		})
+	}
+	if info.BuilderImage != "" {
+		def.ResolvedDependencies = append(def.ResolvedDependencies, ResourceDescriptor{
+			Name: "go-builder-image",
+			URI:  "docker://" + info.BuilderImage,
+		})
+	}
+	var meta BuildMetadata
+	if !info.StartedOn.IsZero() {
+		t := info.StartedOn.UTC()
+		meta.StartedOn = &t
+	}
+	if !info.FinishedOn.IsZero() {
+		t := info.FinishedOn.UTC()
+		meta.FinishedOn = &t
+	}
+	return Statement{
+		Type: StatementType,
+		Subject: []Subject{{
+			Name:   info.Image,
+			Digest: subjectDigest,
+		}},
+		PredicateType: PredicateType,
+		Predicate: Provenance{
+			BuildDefinition: def,
+			RunDetails: RunDetails{
+				Builder:  Builder{ID: builderID},
+				Metadata: meta,
+			},
+		},
+	}, nil
+}
+
+// Verify checks that the statement is a provenance statement generated by
+// the build system for the image with the given digest, built from the given
+// commit.
+func Verify(s Statement, digest, commit string) error {
+	if s.Type != StatementType {
+		return fmt.Errorf("invalid statement type %q", s.Type)
+	}
+	if s.PredicateType != PredicateType {
+		return fmt.Errorf("invalid predicate type %q", s.PredicateType)
+	}
+	if s.Predicate.BuildDefinition.BuildType != BuildType {
+		return fmt.Errorf("invalid build type %q", s.Predicate.BuildDefinition.BuildType)
+	}
+	want, err := digestSet(digest)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, sub := range s.Subject {
+		if sub.Digest["sha256"] == want["sha256"] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("the statement does not refer to the image %s", digest)
+	}
+	got, _ := s.Predicate.BuildDefinition.ExternalParameters["commit"].(string)
+	if commit != "" && got != commit {
+		return fmt.Errorf("the image was built from the commit %q, want %q", got, commit)
+	}
+	return nil
+}
+
+// digestSet converts a digest in the form sha256:<hex> to an in-toto digest
+// set.
+func digestSet(digest string) (map[string]string, error) {
+	alg, hex, ok := strings.Cut(digest, ":")
+	if !ok || alg != "sha256" || hex == "" {
+		return nil, errors.New("invalid digest " + digest)
+	}
+	return map[string]string{alg: hex}, nil
+}
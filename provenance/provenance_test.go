@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package provenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-checks-bsys/manifest"
+)
+
+func TestNew(t *testing.T) {
+	info := BuildInfo{
+		Image:         "docker.example.com/vulcan-checks/check",
+		Digest:        "sha256:7e2f1a",
+		Tag:           "3",
+		Commit:        "a1b2c3d",
+		SDKVersion:    "v1.4.0",
+		BuildPlan:     "cmd/check:3:a1b2c3d",
+		ContextDigest: "sha256:0b8a4c",
+		Manifest:      manifest.Data{Description: "Check"},
+		StartedOn:     time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+	s, err := New(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Subject[0].Digest["sha256"]; got != "7e2f1a" {
+		t.Errorf("New() subject digest = %s, want 7e2f1a", got)
+	}
+	if got := s.Predicate.RunDetails.Builder.ID; got != DefaultBuilderID {
+		t.Errorf("New() builder = %s, want %s", got, DefaultBuilderID)
+	}
+	man, _ := s.Predicate.BuildDefinition.ExternalParameters["manifest"].(map[string]interface{})
+	if man["Description"] != "Check" {
+		t.Errorf("New() manifest = %v, want description Check", man)
+	}
+
+	tests := []struct {
+		name    string
+		digest  string
+		commit  string
+		wantErr bool
+	}{
+		{
+			name:   "HappyPath",
+			digest: "sha256:7e2f1a",
+			commit: "a1b2c3d",
+		},
+		{
+			name:    "OtherDigest",
+			digest:  "sha256:000000",
+			commit:  "a1b2c3d",
+			wantErr: true,
+		},
+		{
+			name:    "OtherCommit",
+			digest:  "sha256:7e2f1a",
+			commit:  "d4e5f6a",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(s, tt.digest, tt.commit); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInvalidDigest(t *testing.T) {
+	if _, err := New(BuildInfo{Digest: "7e2f1a"}); err == nil {
+		t.Errorf("New() expected error with an invalid digest")
+	}
+}
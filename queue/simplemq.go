@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
@@ -106,7 +107,7 @@ func (s *SimpleMQ) handleGETMessage(w http.ResponseWriter, r *http.Request, ps h
 }
 
 func (s *SimpleMQ) handleNotFoundRoute(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("request received: %+v\n", r.URL)
+	slog.Debug("Request to an unknown route received", "url", r.URL.String())
 	w.WriteHeader(http.StatusForbidden)
 }
 
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package sarif defines the subset of the SARIF 2.1.0 format used to report
+// the results of the build system tools to code scanning services.
+package sarif
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	// Version is the version of the SARIF format.
+	Version = "2.1.0"
+	// Schema is the json schema of the SARIF format.
+	Schema = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Levels of the results.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+	LevelNone    = "none"
+)
+
+// Log is the root object of a SARIF file.
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run contains the results of a run of a tool.
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the tool that generated the results.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver describes the component of the tool that generated the results and
+// the rules it applies.
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules,omitempty"`
+}
+
+// Rule describes a rule applied by a tool.
+type Rule struct {
+	ID                   string         `json:"id"`
+	ShortDescription     *Message       `json:"shortDescription,omitempty"`
+	DefaultConfiguration *Configuration `json:"defaultConfiguration,omitempty"`
+}
+
+// Configuration is the configuration of a rule.
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+// Message is a text message.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is a result generated by a tool.
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+}
+
+// Location is the location of a result.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a location in a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation identifies a file.
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a region of a file.
+type Region struct {
+	StartLine int `json:"startLine"`
+}
+
+// New returns a log with a run of the given tool.
+func New(driver Driver, results []Result) Log {
+	if results == nil {
+		results = []Result{}
+	}
+	return Log{
+		Version: Version,
+		Schema:  Schema,
+		Runs: []Run{
+			{
+				Tool:    Tool{Driver: driver},
+				Results: results,
+			},
+		},
+	}
+}
+
+// NewLocation returns the location of a line of a file. A line lower than 1
+// means the whole file.
+func NewLocation(uri string, line int) Location {
+	l := Location{PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: uri}}}
+	if line > 0 {
+		l.PhysicalLocation.Region = &Region{StartLine: line}
+	}
+	return l
+}
+
+// Write writes the log as indented json.
+func (l Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
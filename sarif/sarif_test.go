@@ -0,0 +1,127 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package sarif
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+		want    string
+	}{
+		{
+			name: "NoResults",
+			want: `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "vulcan-lint"
+        }
+      },
+      "results": []
+    }
+  ]
+}
+`,
+		},
+		{
+			name: "Results",
+			results: []Result{
+				{
+					RuleID:    "dockerfile-cmd",
+					Level:     LevelError,
+					Message:   Message{Text: "wrong binary"},
+					Locations: []Location{NewLocation("cmd/vulcan-bad/Dockerfile", 3)},
+				},
+			},
+			want: `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "vulcan-lint"
+        }
+      },
+      "results": [
+        {
+          "ruleId": "dockerfile-cmd",
+          "level": "error",
+          "message": {
+            "text": "wrong binary"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "cmd/vulcan-bad/Dockerfile"
+                },
+                "region": {
+                  "startLine": 3
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := New(Driver{Name: "vulcan-lint"}, tt.results).Write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("log mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewLocation(t *testing.T) {
+	tests := []struct {
+		name string
+		line int
+		want Location
+	}{
+		{
+			name: "Line",
+			line: 7,
+			want: Location{PhysicalLocation: PhysicalLocation{
+				ArtifactLocation: ArtifactLocation{URI: "manifest.toml"},
+				Region:           &Region{StartLine: 7},
+			}},
+		},
+		{
+			name: "WholeFile",
+			want: Location{PhysicalLocation: PhysicalLocation{
+				ArtifactLocation: ArtifactLocation{URI: "manifest.toml"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewLocation("manifest.toml", tt.line)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("location mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
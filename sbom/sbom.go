@@ -0,0 +1,289 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+const (
+	// Format is the format of the SBOMs generated.
+	Format = "CycloneDX"
+	// SpecVersion is the version of the CycloneDX specification of the
+	// SBOMs generated.
+	SpecVersion = "1.5"
+
+	// ComponentTypeApplication is the type of the component that represents
+	// the check image.
+	ComponentTypeApplication = "application"
+	// ComponentTypeLibrary is the type of the components that represent go
+	// modules and OS packages.
+	ComponentTypeLibrary = "library"
+	// ComponentTypeOS is the type of the component that represents the OS of
+	// the image.
+	ComponentTypeOS = "operating-system"
+)
+
+// BOM is a CycloneDX software bill of materials.
+type BOM struct {
+	BOMFormat   string      `json:"bomFormat"`
+	SpecVersion string      `json:"specVersion"`
+	Version     int         `json:"version"`
+	Metadata    Metadata    `json:"metadata"`
+	Components  []Component `json:"components"`
+}
+
+// Metadata contains the info about the subject of a BOM.
+type Metadata struct {
+	Component *Component `json:"component,omitempty"`
+}
+
+// Component is a piece of software included in the subject of a BOM.
+type Component struct {
+	BOMRef  string `json:"bom-ref,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+// Ecosystem returns the ecosystem of the component, that is the type of its
+// package URL, e.g.: golang, apk or deb.
+func (c Component) Ecosystem() string {
+	typ, _, _ := strings.Cut(strings.TrimPrefix(c.PURL, "pkg:"), "/")
+	return typ
+}
+
+// New returns a BOM for the image with the given name and version that
+// contains the given components. The components are sorted, and no timestamp
+// is included, so the same components always produce the same BOM.
+func New(name, version string, components ...[]Component) BOM {
+	var all []Component
+	for _, c := range components {
+		all = append(all, c...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].PURL != all[j].PURL {
+			return all[i].PURL < all[j].PURL
+		}
+		return all[i].Name < all[j].Name
+	})
+	return BOM{
+		BOMFormat:   Format,
+		SpecVersion: SpecVersion,
+		Version:     1,
+		Metadata: Metadata{
+			Component: &Component{
+				BOMRef:  name,
+				Type:    ComponentTypeApplication,
+				Name:    name,
+				Version: version,
+			},
+		},
+		Components: all,
+	}
+}
+
+// GoModules returns the components corresponding to the go modules and the go
+// standard library used to build a go binary.
+func GoModules(bi *debug.BuildInfo) []Component {
+	comps := []Component{goComponent("stdlib", bi.GoVersion)}
+	if bi.Main.Path != "" {
+		comps = append(comps, goComponent(bi.Main.Path, bi.Main.Version))
+	}
+	for _, dep := range bi.Deps {
+		m := dep
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		comps = append(comps, goComponent(m.Path, m.Version))
+	}
+	return comps
+}
+
+func goComponent(path, version string) Component {
+	purl := "pkg:golang/" + path
+	if version != "" && version != "(devel)" {
+		purl += "@" + version
+	}
+	return Component{
+		BOMRef:  purl,
+		Type:    ComponentTypeLibrary,
+		Name:    path,
+		Version: version,
+		PURL:    purl,
+	}
+}
+
+// OSRelease contains the fields of an os-release file needed to identify the
+// distribution of an image.
+type OSRelease struct {
+	ID        string
+	VersionID string
+}
+
+// Component returns the component that represents the distribution.
+func (o OSRelease) Component() Component {
+	return Component{
+		BOMRef:  "os:" + o.ID,
+		Type:    ComponentTypeOS,
+		Name:    o.ID,
+		Version: o.VersionID,
+	}
+}
+
+// ParseOSRelease parses an os-release file.
+func ParseOSRelease(r io.Reader) (OSRelease, error) {
+	var o OSRelease
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), "=")
+		if !ok {
+			continue
+		}
+		v = strings.Trim(v, `"'`)
+		switch k {
+		case "ID":
+			o.ID = v
+		case "VERSION_ID":
+			o.VersionID = v
+		}
+	}
+	return o, s.Err()
+}
+
+// ParseAPKInstalled returns the packages listed in an apk installed database,
+// /lib/apk/db/installed, of an alpine image.
+func ParseAPKInstalled(r io.Reader, distro OSRelease) ([]Component, error) {
+	var (
+		comps         []Component
+		name, version string
+	)
+	add := func() {
+		if name != "" {
+			comps = append(comps, osPackage("apk", distro, name, version))
+		}
+		name, version = "", ""
+	}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			add()
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "P":
+			name = v
+		case "V":
+			version = v
+		}
+	}
+	add()
+	return comps, s.Err()
+}
+
+// ParseDpkgStatus returns the packages installed according to a dpkg status
+// file, /var/lib/dpkg/status, of a debian based image.
+func ParseDpkgStatus(r io.Reader, distro OSRelease) ([]Component, error) {
+	var (
+		comps                 []Component
+		name, version, status string
+	)
+	add := func() {
+		if name != "" && strings.HasSuffix(status, " installed") {
+			comps = append(comps, osPackage("deb", distro, name, version))
+		}
+		name, version, status = "", "", ""
+	}
+	s := bufio.NewScanner(r)
+	// Some fields of the status file, like the descriptions, can be long.
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			add()
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch k {
+		case "Package":
+			name = v
+		case "Version":
+			version = v
+		case "Status":
+			status = v
+		}
+	}
+	add()
+	return comps, s.Err()
+}
+
+func osPackage(typ string, distro OSRelease, name, version string) Component {
+	purl := fmt.Sprintf("pkg:%s/%s/%s", typ, url.PathEscape(distro.ID), url.PathEscape(name))
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+	if distro.VersionID != "" {
+		purl += "?distro=" + url.QueryEscape(distro.ID+"-"+distro.VersionID)
+	}
+	return Component{
+		BOMRef:  purl,
+		Type:    ComponentTypeLibrary,
+		Name:    name,
+		Version: version,
+		PURL:    purl,
+	}
+}
+
+// Paths of the files of an image used to find its OS packages.
+const (
+	OSReleasePath    = "/etc/os-release"
+	APKInstalledPath = "/lib/apk/db/installed"
+	DpkgStatusPath   = "/var/lib/dpkg/status"
+)
+
+// OSPackages returns the components corresponding to the distribution and the
+// OS packages of an image given the contents of its files. The files map must
+// contain the files of the image, indexed by path, among OSReleasePath,
+// APKInstalledPath and DpkgStatusPath that exist in the image.
+func OSPackages(files map[string][]byte) ([]Component, error) {
+	content, ok := files[OSReleasePath]
+	if !ok {
+		// Images built from scratch don't have a distribution.
+		return nil, nil
+	}
+	distro, err := ParseOSRelease(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	comps := []Component{distro.Component()}
+	var pkgs []Component
+	if content, ok := files[APKInstalledPath]; ok {
+		if pkgs, err = ParseAPKInstalled(bytes.NewReader(content), distro); err != nil {
+			return nil, err
+		}
+	} else if content, ok := files[DpkgStatusPath]; ok {
+		if pkgs, err = ParseDpkgStatus(bytes.NewReader(content), distro); err != nil {
+			return nil, err
+		}
+	}
+	return append(comps, pkgs...), nil
+}
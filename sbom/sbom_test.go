@@ -0,0 +1,167 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package sbom
+
+import (
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+const (
+	alpineOSRelease = `NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.19.1
+PRETTY_NAME="Alpine Linux v3.19"
+`
+	apkInstalled = `C:Q1abc=
+P:musl
+V:1.2.4_git20230717-r4
+A:x86_64
+
+C:Q1def=
+P:ca-certificates-bundle
+V:20240226-r0
+A:x86_64
+`
+	debianOSRelease = `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"
+ID=debian
+VERSION_ID="12"
+`
+	dpkgStatus = `Package: libc6
+Status: install ok installed
+Version: 2.36-9+deb12u4
+Description: GNU C Library
+ Contains the standard libraries.
+
+Package: removed-pkg
+Status: deinstall ok config-files
+Version: 1.0
+`
+)
+
+func TestOSPackages(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Scratch",
+			files: map[string]string{},
+			want:  nil,
+		},
+		{
+			name: "Alpine",
+			files: map[string]string{
+				OSReleasePath:    alpineOSRelease,
+				APKInstalledPath: apkInstalled,
+			},
+			want: []string{
+				"",
+				"pkg:apk/alpine/musl@1.2.4_git20230717-r4?distro=alpine-3.19.1",
+				"pkg:apk/alpine/ca-certificates-bundle@20240226-r0?distro=alpine-3.19.1",
+			},
+		},
+		{
+			name: "Debian",
+			files: map[string]string{
+				OSReleasePath:  debianOSRelease,
+				DpkgStatusPath: dpkgStatus,
+			},
+			want: []string{
+				"",
+				"pkg:deb/debian/libc6@2.36-9+deb12u4?distro=debian-12",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string][]byte)
+			for k, v := range tt.files {
+				files[k] = []byte(v)
+			}
+			comps, err := OSPackages(files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OSPackages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, c := range comps {
+				got = append(got, c.PURL)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OSPackages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoModules(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.22.1",
+		Main:      debug.Module{Path: "github.com/adevinta/vulcan-checks", Version: "(devel)"},
+		Deps: []*debug.Module{
+			{Path: "github.com/adevinta/vulcan-check-sdk", Version: "v1.4.0"},
+			{
+				Path:    "github.com/sirupsen/logrus",
+				Version: "v1.9.0",
+				Replace: &debug.Module{Path: "github.com/example/logrus", Version: "v1.9.1"},
+			},
+		},
+	}
+	var got []string
+	for _, c := range GoModules(bi) {
+		got = append(got, c.PURL)
+	}
+	want := []string{
+		"pkg:golang/stdlib@go1.22.1",
+		"pkg:golang/github.com/adevinta/vulcan-checks",
+		"pkg:golang/github.com/adevinta/vulcan-check-sdk@v1.4.0",
+		"pkg:golang/github.com/example/logrus@v1.9.1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GoModules() = %v, want %v", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	goMods := []Component{goComponent("github.com/b/b", "v1.0.0"), goComponent("github.com/a/a", "v1.0.0")}
+	pkgs := []Component{osPackage("apk", OSRelease{ID: "alpine"}, "musl", "1.2.4")}
+	bom := New("vulcan-check", "3", goMods, pkgs)
+	if bom.BOMFormat != Format || bom.SpecVersion != SpecVersion {
+		t.Errorf("New() format = %s %s, want %s %s", bom.BOMFormat, bom.SpecVersion, Format, SpecVersion)
+	}
+	if c := bom.Metadata.Component; c == nil || c.Name != "vulcan-check" || c.Version != "3" {
+		t.Errorf("New() metadata component = %+v, want vulcan-check 3", c)
+	}
+	var got []string
+	for _, c := range bom.Components {
+		got = append(got, c.PURL)
+	}
+	want := []string{
+		"pkg:apk/alpine/musl@1.2.4",
+		"pkg:golang/github.com/a/a@v1.0.0",
+		"pkg:golang/github.com/b/b@v1.0.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("New() components = %v, want %v", got, want)
+	}
+	if eco := bom.Components[0].Ecosystem(); eco != "apk" {
+		t.Errorf("Ecosystem() = %s, want apk", eco)
+	}
+}
+
+func TestParseOSRelease(t *testing.T) {
+	got, err := ParseOSRelease(strings.NewReader(debianOSRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := OSRelease{ID: "debian", VersionID: "12"}
+	if got != want {
+		t.Errorf("ParseOSRelease() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// SignatureType is the type of the simple signing payloads generated by
+	// cosign for container images.
+	SignatureType = "cosign container image signature"
+	// InTotoPayloadType is the payload type of the DSSE envelopes that
+	// contain in-toto statements.
+	InTotoPayloadType = "application/vnd.in-toto+json"
+
+	// SignatureAnnotation is the annotation of the layer of a signature
+	// pushed to a registry that contains the signature of its payload, as
+	// cosign stores it.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// PasswordEnvVar is the env var, also used by cosign, that contains the
+	// password of an encrypted private key.
+	PasswordEnvVar = "COSIGN_PASSWORD"
+
+	encryptedCosignKeyPEMType = "ENCRYPTED COSIGN PRIVATE KEY"
+	encryptedSigstoreKeyType  = "ENCRYPTED SIGSTORE PRIVATE KEY"
+)
+
+// LoadPrivateKey reads an ECDSA private key from a PEM file. Besides plain
+// PKCS8 and SEC1 keys, it supports the encrypted keys generated by cosign
+// generate-key-pair, which are decrypted with the given password.
+func LoadPrivateKey(path string, password []byte) (*ecdsa.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	der := block.Bytes
+	switch block.Type {
+	case encryptedCosignKeyPEMType, encryptedSigstoreKeyType:
+		if der, err = decrypt(block.Bytes, password); err != nil {
+			return nil, err
+		}
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %q in %s", block.Type, path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("only ECDSA private keys are supported")
+	}
+	return ecKey, nil
+}
+
+// LoadPublicKey reads an ECDSA public key from a PEM file, as the ones
+// generated by cosign generate-key-pair.
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("no PEM public key found in %s", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("only ECDSA public keys are supported")
+	}
+	return ecKey, nil
+}
+
+// encryptedKey is the format of the keys encrypted by cosign.
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func decrypt(data, password []byte) ([]byte, error) {
+	var k encryptedKey
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, err
+	}
+	if k.KDF.Name != "scrypt" || k.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported key encryption %s, %s", k.KDF.Name, k.Cipher.Name)
+	}
+	secret, err := scrypt.Key(password, k.KDF.Salt, k.KDF.Params.N, k.KDF.Params.R, k.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		key   [32]byte
+		nonce [24]byte
+	)
+	copy(key[:], secret)
+	if len(k.Cipher.Nonce) != len(nonce) {
+		return nil, errors.New("invalid nonce size")
+	}
+	copy(nonce[:], k.Cipher.Nonce)
+	der, ok := secretbox.Open(nil, k.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, errors.New("error decrypting the private key, invalid password")
+	}
+	return der, nil
+}
+
+// SimpleSigning is the payload signed to sign a container image, in the
+// format used by cosign.
+type SimpleSigning struct {
+	Critical Critical          `json:"critical"`
+	Optional map[string]string `json:"optional,omitempty"`
+}
+
+// Critical contains the info that identifies the image signed.
+type Critical struct {
+	Identity struct {
+		DockerReference string `json:"docker-reference"`
+	} `json:"identity"`
+	Image struct {
+		DockerManifestDigest string `json:"docker-manifest-digest"`
+	} `json:"image"`
+	Type string `json:"type"`
+}
+
+// Signature contains a signed payload.
+type Signature struct {
+	Payload []byte
+	// Signature is the base64 encoded ASN.1 ECDSA signature of the sha256 of
+	// the payload.
+	Signature string
+}
+
+// SignImage signs the image, identified by its reference, without tag, and
+// the digest of its manifest. The annotations are added to the optional
+// section of the payload.
+func SignImage(key *ecdsa.PrivateKey, ref, digest string, annotations map[string]string) (Signature, error) {
+	p := SimpleSigning{Optional: annotations}
+	p.Critical.Identity.DockerReference = ref
+	p.Critical.Image.DockerManifestDigest = digest
+	p.Critical.Type = SignatureType
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return Signature{}, err
+	}
+	sig, err := sign(key, payload)
+	if err != nil {
+		return Signature{}, err
+	}
+	return Signature{Payload: payload, Signature: base64.StdEncoding.EncodeToString(sig)}, nil
+}
+
+// VerifyImage verifies the signature of the image with the given reference,
+// without tag, and manifest digest.
+func VerifyImage(key *ecdsa.PublicKey, s Signature, ref, digest string) error {
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return err
+	}
+	if !verify(key, s.Payload, sig) {
+		return errors.New("invalid image signature")
+	}
+	var p SimpleSigning
+	if err = json.Unmarshal(s.Payload, &p); err != nil {
+		return err
+	}
+	if p.Critical.Type != SignatureType {
+		return fmt.Errorf("invalid signature type %q", p.Critical.Type)
+	}
+	if p.Critical.Identity.DockerReference != ref {
+		return fmt.Errorf("the signature is for the image %s, want %s", p.Critical.Identity.DockerReference, ref)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("the signature is for the digest %s, want %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+	return nil
+}
+
+// Envelope is a DSSE envelope, the format used by cosign to store
+// attestations.
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+// EnvelopeSignature is a signature of a DSSE envelope.
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// SignEnvelope returns a DSSE envelope that contains the given payload
+// signed with the key.
+func SignEnvelope(key *ecdsa.PrivateKey, payloadType string, payload []byte) (Envelope, error) {
+	sig, err := sign(key, pae(payloadType, payload))
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []EnvelopeSignature{
+			{Sig: base64.StdEncoding.EncodeToString(sig)},
+		},
+	}, nil
+}
+
+// VerifyEnvelope checks that the envelope has a valid signature made with the
+// given key and returns its payload.
+func VerifyEnvelope(key *ecdsa.PublicKey, e Envelope) ([]byte, error) {
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	msg := pae(e.PayloadType, payload)
+	for _, s := range e.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if verify(key, msg, sig) {
+			return payload, nil
+		}
+	}
+	return nil, errors.New("no valid signature found in the envelope")
+}
+
+// pae returns the DSSE pre-authentication encoding of a payload.
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+func sign(key *ecdsa.PrivateKey, msg []byte) ([]byte, error) {
+	h := sha256.Sum256(msg)
+	return key.Sign(rand.Reader, h[:], crypto.SHA256)
+}
+
+func verify(key *ecdsa.PublicKey, msg, sig []byte) bool {
+	h := sha256.Sum256(msg)
+	return ecdsa.VerifyASN1(key, h[:], sig)
+}
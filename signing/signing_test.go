@@ -0,0 +1,232 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+func TestSignImage(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SignImage(key, "docker.example.com/vulcan-checks/check", "sha256:7e2f1a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		key     *ecdsa.PublicKey
+		ref     string
+		digest  string
+		wantErr bool
+	}{
+		{
+			name:   "HappyPath",
+			key:    &key.PublicKey,
+			ref:    "docker.example.com/vulcan-checks/check",
+			digest: "sha256:7e2f1a",
+		},
+		{
+			name:    "OtherKey",
+			key:     &other.PublicKey,
+			ref:     "docker.example.com/vulcan-checks/check",
+			digest:  "sha256:7e2f1a",
+			wantErr: true,
+		},
+		{
+			name:    "OtherImage",
+			key:     &key.PublicKey,
+			ref:     "docker.example.com/vulcan-checks/other",
+			digest:  "sha256:7e2f1a",
+			wantErr: true,
+		},
+		{
+			name:    "OtherDigest",
+			key:     &key.PublicKey,
+			ref:     "docker.example.com/vulcan-checks/check",
+			digest:  "sha256:000000",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyImage(tt.key, sig, tt.ref, tt.digest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignEnvelope(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+	e, err := SignEnvelope(key, InTotoPayloadType, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := VerifyEnvelope(&key.PublicKey, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(payload) {
+		t.Errorf("VerifyEnvelope() = %s, want %s", got, payload)
+	}
+	e.PayloadType = "text/plain"
+	if _, err = VerifyEnvelope(&key.PublicKey, e); err == nil {
+		t.Errorf("VerifyEnvelope() expected error verifying a tampered envelope")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	password := []byte("secret")
+	files := map[string]*pem.Block{
+		"plain.key":     {Type: "PRIVATE KEY", Bytes: der},
+		"encrypted.key": {Type: encryptedCosignKeyPEMType, Bytes: encrypt(t, der, password)},
+		"cosign.pub":    {Type: "PUBLIC KEY", Bytes: pubDER},
+	}
+	for name, b := range files {
+		if err = os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(b), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		password []byte
+		wantErr  bool
+	}{
+		{
+			name: "Plain",
+			file: "plain.key",
+		},
+		{
+			name:     "Encrypted",
+			file:     "encrypted.key",
+			password: password,
+		},
+		{
+			name:     "InvalidPassword",
+			file:     "encrypted.key",
+			password: []byte("invalid"),
+			wantErr:  true,
+		},
+	}
+	pub, err := LoadPublicKey(filepath.Join(dir, "cosign.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadPrivateKey(filepath.Join(dir, tt.file), tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !got.PublicKey.Equal(pub) {
+				t.Errorf("LoadPrivateKey() returned a key that does not match the public key")
+			}
+		})
+	}
+}
+
+// encrypt encrypts a private key as cosign generate-key-pair does.
+func encrypt(t *testing.T, der, password []byte) []byte {
+	var k encryptedKey
+	k.KDF.Name = "scrypt"
+	k.KDF.Params.N = 1024
+	k.KDF.Params.R = 8
+	k.KDF.Params.P = 1
+	k.KDF.Salt = []byte("0123456789abcdef0123456789abcdef")
+	k.Cipher.Name = "nacl/secretbox"
+	k.Cipher.Nonce = []byte("0123456789abcdef01234567")
+	secret, err := scrypt.Key(password, k.KDF.Salt, k.KDF.Params.N, k.KDF.Params.R, k.KDF.Params.P, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		key   [32]byte
+		nonce [24]byte
+	)
+	copy(key[:], secret)
+	copy(nonce[:], k.Cipher.Nonce)
+	k.Ciphertext = secretbox.Seal(nil, der, &nonce, &key)
+	content, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestStore(t *testing.T) {
+	s := Store{Dir: t.TempDir()}
+	sig := Signature{Payload: []byte(`{"critical":{}}`), Signature: "MEUCIQ"}
+	if err := s.WriteSignature("check", "sha256:7e2f1a", sig); err != nil {
+		t.Fatal(err)
+	}
+	e := Envelope{PayloadType: "application/vnd.in-toto+json", Payload: "e30="}
+	if err := s.WriteAttestation("check", "sha256:7e2f1a", e); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(s.Dir, "check", "sha256-7e2f1a")
+	payload, err := os.ReadFile(base + ".payload.json")
+	if err != nil {
+		t.Fatalf("payload not stored with the cosign name: %v", err)
+	}
+	signature, err := os.ReadFile(base + ".sig")
+	if err != nil {
+		t.Fatalf("signature not stored with the cosign name: %v", err)
+	}
+	if string(payload) != string(sig.Payload) || string(signature) != sig.Signature {
+		t.Errorf("WriteSignature() stored %s and %s, want %s and %s", payload, signature, sig.Payload, sig.Signature)
+	}
+	content, err := os.ReadFile(base + ".att.json")
+	if err != nil {
+		t.Fatalf("attestation not stored: %v", err)
+	}
+	var got Envelope
+	if err = json.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.PayloadType != e.PayloadType || got.Payload != e.Payload {
+		t.Errorf("WriteAttestation() stored %+v, want %+v", got, e)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package signing
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store stores the signatures and attestations of the images in a directory,
+// under a subdirectory for each image, using the same names cosign uses for
+// the tags of the signatures, i.e.: sha256-<hex>.sig. The signatures are
+// stored in the files:
+//
+//	<dir>/<image>/sha256-<hex>.payload.json
+//	<dir>/<image>/sha256-<hex>.sig
+//
+// so they can be verified with cosign verify-blob, and the attestations, as
+// DSSE envelopes, in the file:
+//
+//	<dir>/<image>/sha256-<hex>.att.json
+type Store struct {
+	Dir string
+}
+
+// WriteSignature stores the signature of an image.
+func (s Store) WriteSignature(image, digest string, sig Signature) error {
+	base, err := s.base(image, digest)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(base+".payload.json", sig.Payload, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(base+".sig", []byte(sig.Signature), 0644)
+}
+
+// WriteAttestation stores an attestation of an image.
+func (s Store) WriteAttestation(image, digest string, e Envelope) error {
+	base, err := s.base(image, digest)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(base+".att.json", content, 0644)
+}
+
+// base returns the path, without extension, of the files of the given image
+// digest, creating the directory of the image if needed.
+func (s Store) base(image, digest string) (string, error) {
+	p := s.path(image, digest)
+	return p, os.MkdirAll(filepath.Dir(p), 0755)
+}
+
+func (s Store) path(image, digest string) string {
+	return filepath.Join(s.Dir, image, strings.Replace(digest, ":", "-", 1))
+}
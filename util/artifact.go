@@ -0,0 +1,183 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	specsgo "github.com/opencontainers/image-spec/specs-go"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"gopkg.in/resty.v1"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+)
+
+// Media types of the artifacts attached to the images.
+const (
+	// SignatureMediaType is the media type of the signatures, the same
+	// cosign uses for its simple signing payloads.
+	SignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// AttestationMediaType is the media type of the attestations, stored
+	// as DSSE envelopes.
+	AttestationMediaType = "application/vnd.dsse.envelope.v1+json"
+	// SBOMMediaType is the media type of the CycloneDX SBOMs.
+	SBOMMediaType = "application/vnd.cyclonedx+json"
+)
+
+// Suffixes of the tags of the artifacts attached to the images.
+const (
+	SignatureTagSuffix   = "sig"
+	AttestationTagSuffix = "att"
+	SBOMTagSuffix        = "sbom"
+)
+
+// ErrArtifactNotFound is returned when an image has no artifact with the
+// given tag.
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// Artifact is a file attached to an image of the registry. It's stored as the
+// only layer of an OCI manifest, tagged after the digest of the image, like
+// cosign does, e.g.: sha256-<hex>.sig.
+type Artifact struct {
+	MediaType   string
+	Content     []byte
+	Annotations map[string]string
+}
+
+// ArtifactTag returns the tag of the artifact with the given suffix attached
+// to the image with the given digest, e.g.: sha256-<hex>.sig.
+func ArtifactTag(imageDigest, suffix string) string {
+	return strings.Replace(imageDigest, ":", "-", 1) + "." + suffix
+}
+
+// PushArtifact pushes an artifact of an image to the registry with the given
+// tag, replacing the artifact with the same tag, if any.
+func PushArtifact(image, tag string, a Artifact) error {
+	client := resty.New().SetHostURL(config.Cfg.DockerAPIBaseURL)
+	setupAPICred(client)
+
+	empty := specs.DescriptorEmptyJSON
+	if err := pushBlob(client, image, empty.Digest, empty.Data); err != nil {
+		return err
+	}
+	layer := specs.Descriptor{
+		MediaType:   a.MediaType,
+		Digest:      digest.FromBytes(a.Content),
+		Size:        int64(len(a.Content)),
+		Annotations: a.Annotations,
+	}
+	if err := pushBlob(client, image, layer.Digest, a.Content); err != nil {
+		return err
+	}
+	m := specs.Manifest{
+		Versioned: specsgo.Versioned{SchemaVersion: 2},
+		MediaType: specs.MediaTypeImageManifest,
+		Config:    empty,
+		Layers:    []specs.Descriptor{layer},
+	}
+	// The data of the empty descriptor is only needed to push the blob.
+	m.Config.Data = nil
+	content, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	manifestPath := fmt.Sprintf("/%v/%v/manifests/%v", config.Cfg.VulcanChecksRepo, image, tag)
+	response, err := client.R().
+		SetHeader("Content-Type", specs.MediaTypeImageManifest).
+		SetBody(content).
+		Put(manifestPath)
+	if err != nil {
+		return err
+	}
+	if response.RawResponse.StatusCode != http.StatusCreated && response.RawResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	return nil
+}
+
+// pushBlob uploads a blob of an image to the registry, in a single request,
+// unless the registry already has it.
+func pushBlob(client *resty.Client, image string, d digest.Digest, content []byte) error {
+	blobPath := fmt.Sprintf("/%v/%v/blobs/%v", config.Cfg.VulcanChecksRepo, image, d)
+	response, err := client.R().Head(blobPath)
+	if err != nil {
+		return err
+	}
+	if response.RawResponse.StatusCode == http.StatusOK {
+		return nil
+	}
+	uploadPath := fmt.Sprintf("/%v/%v/blobs/uploads/", config.Cfg.VulcanChecksRepo, image)
+	response, err = client.R().Post(uploadPath)
+	if err != nil {
+		return err
+	}
+	if response.RawResponse.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	// The location of the upload can be relative to the URL of the request.
+	location, err := response.RawResponse.Request.URL.Parse(response.Header().Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location returned by query %s: %w", response.Request.URL, err)
+	}
+	q := location.Query()
+	q.Set("digest", d.String())
+	location.RawQuery = q.Encode()
+	response, err = client.R().
+		SetHeader("Content-Type", "application/octet-stream").
+		SetBody(content).
+		Put(location.String())
+	if err != nil {
+		return err
+	}
+	if response.RawResponse.StatusCode != http.StatusCreated {
+		return fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	return nil
+}
+
+// FetchArtifact reads the artifact of an image stored in the registry with
+// the given tag. It returns ErrArtifactNotFound if the tag doesn't exist.
+func FetchArtifact(image, tag string) (Artifact, error) {
+	client := resty.New().SetHostURL(config.Cfg.DockerAPIBaseURL)
+	setupAPICred(client)
+
+	manifestPath := fmt.Sprintf("/%v/%v/manifests/%v", config.Cfg.VulcanChecksRepo, image, tag)
+	response, err := client.R().SetHeader("Accept", specs.MediaTypeImageManifest).Get(manifestPath)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if response.RawResponse.StatusCode == http.StatusNotFound {
+		return Artifact{}, fmt.Errorf("%w: %s:%s", ErrArtifactNotFound, image, tag)
+	}
+	if response.RawResponse.StatusCode != http.StatusOK {
+		return Artifact{}, fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	var m specs.Manifest
+	if err = json.Unmarshal(response.Body(), &m); err != nil {
+		return Artifact{}, err
+	}
+	if len(m.Layers) != 1 {
+		return Artifact{}, fmt.Errorf("the artifact %s:%s has %d layers, want 1", image, tag, len(m.Layers))
+	}
+	layer := m.Layers[0]
+	blobPath := fmt.Sprintf("/%v/%v/blobs/%v", config.Cfg.VulcanChecksRepo, image, layer.Digest)
+	response, err = client.R().Get(blobPath)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if response.RawResponse.StatusCode != http.StatusOK {
+		return Artifact{}, fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	content := response.Body()
+	if d := digest.FromBytes(content); d != layer.Digest {
+		return Artifact{}, fmt.Errorf("the content of the artifact %s:%s has digest %s, want %s", image, tag, d, layer.Digest)
+	}
+	return Artifact{MediaType: layer.MediaType, Content: content, Annotations: layer.Annotations}, nil
+}
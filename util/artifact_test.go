@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package util
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/adevinta/vulcan-checks-bsys/config"
+)
+
+// fakeArtifactRegistry is a registry that stores in memory the blobs and the
+// manifests pushed to it.
+type fakeArtifactRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+}
+
+func (f *fakeArtifactRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p := r.URL.Path
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(p, "/blobs/uploads/"):
+		w.Header().Set("Location", "uploads/f00?state=bar")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && strings.Contains(p, "/blobs/uploads/"):
+		content, _ := io.ReadAll(r.Body)
+		d := r.URL.Query().Get("digest")
+		if r.URL.Query().Get("state") != "bar" || digest.FromBytes(content).String() != d {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.blobs[d] = content
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(p, "/blobs/"):
+		content, ok := f.blobs[path.Base(p)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content) // nolint: errcheck
+	case r.Method == http.MethodPut && strings.Contains(p, "/manifests/"):
+		content, _ := io.ReadAll(r.Body)
+		f.manifests[path.Base(p)] = content
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(p, "/manifests/"):
+		content, ok := f.manifests[path.Base(p)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content) // nolint: errcheck
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestPushArtifact(t *testing.T) {
+	reg := &fakeArtifactRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	config.Cfg.DockerAPIBaseURL = s.URL
+	config.Cfg.VulcanChecksRepo = "vulcan-checks"
+	config.Cfg.DockerRegistryUser = "user"
+	config.Cfg.DockerRegistryPwd = "pwd"
+
+	tag := ArtifactTag("sha256:7e2f1a", SignatureTagSuffix)
+	if tag != "sha256-7e2f1a.sig" {
+		t.Errorf("ArtifactTag() = %s, want sha256-7e2f1a.sig", tag)
+	}
+	want := Artifact{
+		MediaType:   SignatureMediaType,
+		Content:     []byte(`{"critical":{}}`),
+		Annotations: map[string]string{"dev.cosignproject.cosign/signature": "MEUCIQ=="},
+	}
+	if err := PushArtifact("vulcan-tls", tag, want); err != nil {
+		t.Fatal(err)
+	}
+	// The empty config and the layer.
+	if len(reg.blobs) != 2 {
+		t.Errorf("got %d blobs pushed, want 2", len(reg.blobs))
+	}
+	got, err := FetchArtifact("vulcan-tls", tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("artifact mismatch (-want +got):\n%v", diff)
+	}
+
+	_, err = FetchArtifact("vulcan-tls", ArtifactTag("sha256:7e2f1a", AttestationTagSuffix))
+	if !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("FetchArtifact() got error %v, want %v", err, ErrArtifactNotFound)
+	}
+}
+
+func TestFetchArtifactInvalidDigest(t *testing.T) {
+	reg := &fakeArtifactRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	config.Cfg.DockerAPIBaseURL = s.URL
+	config.Cfg.VulcanChecksRepo = "vulcan-checks"
+	config.Cfg.DockerRegistryUser = "user"
+	config.Cfg.DockerRegistryPwd = "pwd"
+
+	tag := ArtifactTag("sha256:7e2f1a", SBOMTagSuffix)
+	if err := PushArtifact("vulcan-tls", tag, Artifact{MediaType: SBOMMediaType, Content: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+	for d := range reg.blobs {
+		reg.blobs[d] = []byte("tampered")
+	}
+	if _, err := FetchArtifact("vulcan-tls", tag); err == nil {
+		t.Error("FetchArtifact() got no error, want an error for the tampered content")
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package util
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+const (
+	dockerignoreName      = ".dockerignore"
+	sourceDateEpochEnvVar = "SOURCE_DATE_EPOCH"
+)
+
+// BuildContext is a docker build context streamed as a tar file. The tar is
+// generated in a goroutine while the context is read, so it's never fully
+// loaded in memory. The context must be closed after reading it.
+//
+// The generated tar is deterministic: the entries are sorted by name and the
+// timestamps, owners and permissions of the files are normalized, so the same
+// sources always produce the same context, and digest.
+type BuildContext struct {
+	r     *io.PipeReader
+	size  atomic.Int64
+	files atomic.Int64
+	hash  hash.Hash
+	done  chan struct{}
+}
+
+// Read reads the next bytes of the tar file.
+func (c *BuildContext) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Close stops the generation of the tar file.
+func (c *BuildContext) Close() error {
+	return c.r.Close()
+}
+
+// Size returns the number of bytes of the context generated so far. After the
+// context has been fully read it's the size of the tar file.
+func (c *BuildContext) Size() int64 {
+	return c.size.Load()
+}
+
+// Files returns the number of entries of the context generated so far.
+func (c *BuildContext) Files() int64 {
+	return c.files.Load()
+}
+
+// ErrContextNotRead is returned by [BuildContext.Digest] when the context has
+// not been fully read.
+var ErrContextNotRead = errors.New("the build context has not been fully read")
+
+// Digest returns the sha256 digest of the tar file, in the form
+// sha256:<hex>. It returns [ErrContextNotRead] if the context has not been
+// fully generated.
+func (c *BuildContext) Digest() (string, error) {
+	select {
+	case <-c.done:
+		return fmt.Sprintf("sha256:%x", c.hash.Sum(nil)), nil
+	default:
+		return "", ErrContextNotRead
+	}
+}
+
+// contextSource defines a directory to add to a build context.
+type contextSource struct {
+	dir    string
+	prefix string
+	// skip, if not nil, is called with the path of each file and directory
+	// relative to dir, and whether it's a directory, the ones for which it
+	// returns true are not added.
+	skip func(name string, dir bool) bool
+	// ignore contains the patterns, in .dockerignore format, of the files and
+	// directories that must not be added.
+	ignore *patternmatcher.PatternMatcher
+}
+
+// contextFile defines a file, whose contents are in memory, to add to a build
+// context.
+type contextFile struct {
+	name    string
+	content []byte
+}
+
+// StreamTarFromDir returns a build context with the contents of the given
+// sourcedir. The files matching the patterns defined in the .dockerignore file
+// of the sourcedir, if it exists, are not included. As the docker cli does,
+// the Dockerfile and the .dockerignore files are always included.
+func StreamTarFromDir(sourcedir string) (*BuildContext, error) {
+	src, err := newContextSource(sourcedir, "")
+	if err != nil {
+		return nil, err
+	}
+	return newBuildContext(nil, src)
+}
+
+func newContextSource(dir, prefix string) (contextSource, error) {
+	dir = filepath.Clean(dir)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return contextSource{}, err
+	}
+	if !info.IsDir() {
+		return contextSource{}, fmt.Errorf("%s is not a directory", dir)
+	}
+	ignore, err := readDockerignore(dir)
+	if err != nil {
+		return contextSource{}, err
+	}
+	return contextSource{dir: dir, prefix: prefix, ignore: ignore}, nil
+}
+
+// readDockerignore returns the pattern matcher defined by the .dockerignore
+// file in the given dir, or nil if the file does not exist.
+func readDockerignore(dir string) (*patternmatcher.PatternMatcher, error) {
+	f, err := os.Open(filepath.Join(dir, dockerignoreName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+	patterns, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dockerignoreName, err)
+	}
+	return patternmatcher.New(patterns)
+}
+
+// newBuildContext starts generating a build context with the given files and
+// the contents of the given sources.
+func newBuildContext(files []contextFile, sources ...contextSource) (*BuildContext, error) {
+	mtime, err := SourceDateEpoch()
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	c := &BuildContext{r: pr, hash: sha256.New(), done: make(chan struct{})}
+	go func() {
+		w := tar.NewWriter(&countingWriter{w: io.MultiWriter(pw, c.hash), n: &c.size})
+		err := writeContext(w, &c.files, mtime, files, sources)
+		if err == nil {
+			err = w.Close()
+		}
+		if err == nil {
+			close(c.done)
+		}
+		pw.CloseWithError(err) // nolint: errcheck
+	}()
+	return c, nil
+}
+
+// SourceDateEpoch returns the time defined by the SOURCE_DATE_EPOCH env var,
+// see https://reproducible-builds.org/specs/source-date-epoch/. If the var is
+// not defined it returns the unix epoch.
+func SourceDateEpoch() (time.Time, error) {
+	v := os.Getenv(sourceDateEpochEnvVar)
+	if v == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s value %q: %w", sourceDateEpochEnvVar, v, err)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// normalizeHeader removes from a tar header the info that depends on the host
+// or on the moment where the files were checked out.
+func normalizeHeader(h *tar.Header, mtime time.Time) {
+	h.ModTime = mtime
+	h.AccessTime = time.Time{}
+	h.ChangeTime = time.Time{}
+	h.Uid, h.Gid = 0, 0
+	h.Uname, h.Gname = "", ""
+	h.Devmajor, h.Devminor = 0, 0
+	h.PAXRecords = nil
+	h.Format = tar.FormatPAX
+	switch {
+	case h.Typeflag == tar.TypeDir:
+		h.Mode = 0755
+	case h.Typeflag == tar.TypeSymlink:
+		h.Mode = 0777
+	case h.Mode&0111 != 0:
+		h.Mode = 0755
+	default:
+		h.Mode = 0644
+	}
+}
+
+func writeContext(w *tar.Writer, count *atomic.Int64, mtime time.Time, files []contextFile, sources []contextSource) error {
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	for _, f := range files {
+		h := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.name,
+			Size:     int64(len(f.content)),
+		}
+		normalizeHeader(h, mtime)
+		if err := w.WriteHeader(h); err != nil {
+			return err
+		}
+		if _, err := w.Write(f.content); err != nil {
+			return err
+		}
+		count.Add(1)
+	}
+	for _, src := range sources {
+		if err := addSource(w, count, mtime, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSource adds to the tar writer the directories, regular files and
+// symlinks contained in the source directory, sorted by name. Symlinks are
+// added as such, without following them.
+func addSource(w *tar.Writer, count *atomic.Int64, mtime time.Time, src contextSource) error {
+	return filepath.WalkDir(src.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src.dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		excluded, err := src.excluded(rel, d.IsDir())
+		if err != nil {
+			return err
+		}
+		if excluded {
+			// When the .dockerignore contains exclusions, files inside an
+			// ignored directory could still be included.
+			if d.IsDir() && (src.ignore == nil || !src.ignore.Exclusions()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		switch {
+		case info.Mode().IsRegular(), info.IsDir():
+		case info.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		default:
+			// Sockets, devices, etc. are not added to the context.
+			return nil
+		}
+		h, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		h.Name = path.Join(src.prefix, rel)
+		if info.IsDir() {
+			h.Name += "/"
+		}
+		normalizeHeader(h, mtime)
+		if err = w.WriteHeader(h); err != nil {
+			return err
+		}
+		count.Add(1)
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint: errcheck
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
+
+func (src contextSource) excluded(name string, dir bool) (bool, error) {
+	if src.skip != nil && src.skip(name, dir) {
+		return true, nil
+	}
+	if src.ignore == nil || name == dockerfileName || name == dockerignoreName {
+		return false, nil
+	}
+	return src.ignore.MatchesOrParentMatches(name)
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package util
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// maxSymlinks is the maximum number of symlinks followed when reading a file
+// from an image.
+const maxSymlinks = 10
+
+// ReadImageFiles returns the contents of the given files of a local image,
+// indexed by path. The files that don't exist in the image are not included
+// in the result. Symlinks are followed.
+func ReadImageFiles(imageName string, paths ...string) (map[string][]byte, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	// The container is never started, it's only used to access the files of
+	// the image, so the entrypoint doesn't need to exist.
+	cfg := &container.Config{
+		Image:      imageName,
+		Entrypoint: []string{"/nonexistent"},
+	}
+	r, err := cli.ContainerCreate(ctx, cfg, nil, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer cli.ContainerRemove(ctx, r.ID, container.RemoveOptions{Force: true}) // nolint: errcheck
+
+	files := make(map[string][]byte)
+	for _, p := range paths {
+		content, err := readContainerFile(ctx, cli, r.ID, p, 0)
+		if errdefs.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from image %s: %w", p, imageName, err)
+		}
+		files[p] = content
+	}
+	return files, nil
+}
+
+func readContainerFile(ctx context.Context, cli *client.Client, id, p string, links int) ([]byte, error) {
+	rc, _, err := cli.CopyFromContainer(ctx, id, p)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() // nolint: errcheck
+
+	tr := tar.NewReader(rc)
+	h, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	switch h.Typeflag {
+	case tar.TypeReg:
+		return io.ReadAll(tr)
+	case tar.TypeSymlink:
+		if links >= maxSymlinks {
+			return nil, errors.New("too many symlinks")
+		}
+		target := h.Linkname
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+		return readContainerFile(ctx, cli, id, target, links+1)
+	default:
+		return nil, fmt.Errorf("%s is not a regular file", p)
+	}
+}
+
+// ImageBinaryPath returns the path of the binary executed by an image, that
+// is the first element of its entrypoint or, if it doesn't have one, of its
+// command.
+func ImageBinaryPath(imageName string) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", err
+	}
+	info, _, err := cli.ImageInspectWithRaw(context.Background(), imageName)
+	if err != nil {
+		return "", err
+	}
+	if info.Config == nil {
+		return "", fmt.Errorf("image %s has no config", imageName)
+	}
+	args := info.Config.Entrypoint
+	if len(args) == 0 {
+		args = info.Config.Cmd
+	}
+	if len(args) == 0 {
+		return "", fmt.Errorf("image %s has no entrypoint nor command", imageName)
+	}
+	bin := args[0]
+	if !path.IsAbs(bin) {
+		dir := info.Config.WorkingDir
+		if dir == "" {
+			dir = "/"
+		}
+		bin = path.Join(dir, bin)
+	}
+	return bin, nil
+}
+
+// AddImageLabels adds the given labels to a local image and tags the
+// resulting image with the given tags. It returns the ID of the new image.
+// The output of the build is logged with the given logger.
+func AddImageLabels(imageName string, tags []string, labels map[string]string, logger *slog.Logger) (string, error) {
+	dockerfile := []byte(fmt.Sprintf("FROM %s\n", imageName))
+	buildCtx, err := newBuildContext([]contextFile{{name: dockerfileName, content: dockerfile}})
+	if err != nil {
+		return "", err
+	}
+	defer buildCtx.Close() // nolint: errcheck
+	resp, id, err := BuildImage(buildCtx, tags, labels, logger)
+	if err != nil {
+		return "", fmt.Errorf("error adding labels to the image %s: %w, %s", imageName, err, resp)
+	}
+	return id, nil
+}
+
+// TagImage adds a tag to a local image.
+func TagImage(imageName, target string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	return cli.ImageTag(context.Background(), imageName, target)
+}
+
+// DockerfileAddedFiles returns the paths, in the image, of the files added by
+// the ADD and COPY instructions of the last stage of a Dockerfile, in the
+// order they are added. The relative destinations are resolved against the
+// WORKDIR of the stage.
+func DockerfileAddedFiles(dockerfile []byte) []string {
+	var (
+		files   []string
+		workdir = "/"
+	)
+	for _, line := range dockerfileInstructions(dockerfile) {
+		cmd, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+		switch strings.ToUpper(cmd) {
+		case "FROM":
+			files, workdir = nil, "/"
+		case "WORKDIR":
+			workdir = resolveImagePath(workdir, args)
+		case "ADD", "COPY":
+			srcs, dest, ok := addArgs(args)
+			if !ok {
+				continue
+			}
+			dest = resolveImagePath(workdir, dest)
+			if len(srcs) == 1 && !strings.HasSuffix(dest, "/") {
+				files = append(files, path.Clean(dest))
+				continue
+			}
+			for _, src := range srcs {
+				files = append(files, path.Join(dest, path.Base(src)))
+			}
+		}
+	}
+	return files
+}
+
+// dockerfileInstructions returns the instructions of a Dockerfile, joining
+// the lines continued with a backslash and ignoring the comments.
+func dockerfileInstructions(dockerfile []byte) []string {
+	var (
+		insts []string
+		cur   strings.Builder
+	)
+	s := bufio.NewScanner(bytes.NewReader(dockerfile))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") || (line == "" && cur.Len() == 0) {
+			continue
+		}
+		if l, ok := strings.CutSuffix(line, `\`); ok {
+			cur.WriteString(l + " ")
+			continue
+		}
+		cur.WriteString(line)
+		insts = append(insts, strings.TrimSpace(cur.String()))
+		cur.Reset()
+	}
+	return insts
+}
+
+// addArgs returns the sources and the destination of the arguments of an ADD
+// or COPY instruction, in exec or shell form, ignoring its flags.
+func addArgs(args string) ([]string, string, bool) {
+	var fields []string
+	for _, f := range strings.Fields(args) {
+		if len(fields) == 0 && strings.HasPrefix(f, "--") {
+			args = strings.TrimSpace(strings.TrimPrefix(args, f))
+			continue
+		}
+		fields = append(fields, f)
+	}
+	if strings.HasPrefix(args, "[") {
+		fields = nil
+		if err := json.Unmarshal([]byte(args), &fields); err != nil {
+			return nil, "", false
+		}
+	}
+	if len(fields) < 2 {
+		return nil, "", false
+	}
+	return fields[:len(fields)-1], fields[len(fields)-1], true
+}
+
+// resolveImagePath returns the path p of an image relative to the directory
+// dir, keeping the trailing slash, if any.
+func resolveImagePath(dir, p string) string {
+	if path.IsAbs(p) {
+		return p
+	}
+	r := path.Join(dir, p)
+	if (strings.HasSuffix(p, "/") || p == ".") && r != "/" {
+		r += "/"
+	}
+	return r
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDockerfileAddedFiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		want       []string
+	}{
+		{
+			name:       "Add",
+			dockerfile: "FROM alpine\nADD vulcan-tls /vulcan-tls\nCMD [\"/vulcan-tls\"]\n",
+			want:       []string{"/vulcan-tls"},
+		},
+		{
+			name:       "WorkdirAndDirDestination",
+			dockerfile: "FROM alpine\nWORKDIR /app\nCOPY --chown=1000 check config.toml ./\nCOPY [\"run.sh\", \"bin/run\"]\n",
+			want:       []string{"/app/check", "/app/config.toml", "/app/bin/run"},
+		},
+		{
+			name: "MultiStage",
+			dockerfile: "FROM golang:1.22-alpine AS builder\nCOPY . /src\n" +
+				"FROM alpine\n# The binary.\nCOPY --from=builder \\\n    /out/check /usr/local/bin/\n",
+			want: []string{"/usr/local/bin/check"},
+		},
+		{
+			name:       "NoFiles",
+			dockerfile: "FROM alpine\nRUN apk add nmap\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DockerfileAddedFiles([]byte(tt.dockerfile))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("files mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultGoBuilderImage is the image used to compile the checks in
+	// multi-stage builds when no other image is configured.
+	DefaultGoBuilderImage = "golang:1.22-alpine"
+
+	// multiStageSrcDir is the directory of the build context that contains the
+	// go module the check belongs to.
+	multiStageSrcDir = ".vulcan-src"
+	// multiStageBuilderStage is the name of the stage that builds the check
+	// binary.
+	multiStageBuilderStage = "vulcan-builder"
+	dockerfileName         = "Dockerfile"
+)
+
+// IsMultiStageDockerfile returns true if the given Dockerfile contains more
+// than one FROM instruction.
+func IsMultiStageDockerfile(dockerfile []byte) bool {
+	froms := 0
+	s := bufio.NewScanner(bytes.NewReader(dockerfile))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) > 0 && strings.EqualFold(fields[0], "FROM") {
+			froms++
+		}
+	}
+	return froms > 1
+}
+
+// MultiStageDockerfile generates a multi-stage Dockerfile from the Dockerfile
+// of a check. The generated Dockerfile builds the binary of the check, from
+// the go package pkg, using the builderImage and replaces the ADD or COPY
+// instructions that add the binary to the image with a COPY from the builder
+// stage.
+func MultiStageDockerfile(dockerfile []byte, builderImage, binary, pkg string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Generated by vulcan-checks-bsys, the check binary is built in the %s stage.\n", multiStageBuilderStage)
+	fmt.Fprintf(&out, "FROM %s AS %s\n", builderImage, multiStageBuilderStage)
+	fmt.Fprintf(&out, "WORKDIR /src\n")
+	fmt.Fprintf(&out, "COPY %s/ ./\n", multiStageSrcDir)
+	fmt.Fprintf(&out, "ENV CGO_ENABLED=0 GOOS=linux\n")
+	fmt.Fprintf(&out, "RUN go build -a -ldflags '-extldflags -static' -o /out/%s %s\n\n", binary, pkg)
+
+	replaced := false
+	s := bufio.NewScanner(bytes.NewReader(dockerfile))
+	for s.Scan() {
+		line := s.Text()
+		if l, ok := copyFromBuilder(line, binary); ok {
+			line = l
+			replaced = true
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if !replaced {
+		return nil, fmt.Errorf("the Dockerfile does not add the check binary %s to the image", binary)
+	}
+	return out.Bytes(), nil
+}
+
+// copyFromBuilder returns the instruction that copies the binary from the
+// builder stage if the given line is an ADD or COPY instruction that adds the
+// binary from the build context.
+func copyFromBuilder(line, binary string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return "", false
+	}
+	if !strings.EqualFold(fields[0], "ADD") && !strings.EqualFold(fields[0], "COPY") {
+		return "", false
+	}
+	var flags, args []string
+	for _, f := range fields[1:] {
+		if strings.HasPrefix(f, "--") && len(args) == 0 {
+			flags = append(flags, f)
+			continue
+		}
+		args = append(args, f)
+	}
+	if len(args) != 2 || path.Clean(args[0]) != binary {
+		return "", false
+	}
+	instr := []string{"COPY", "--from=" + multiStageBuilderStage}
+	instr = append(instr, flags...)
+	instr = append(instr, "/out/"+binary, args[1])
+	return strings.Join(instr, " "), true
+}
+
+// BuildMultiStageTarFromDir returns the context of a multi-stage docker build
+// for the check in checkDir. The context contains the files of the check
+// directory, the parts of the go module the check belongs to needed to build
+// it under the .vulcan-src directory, and a Dockerfile that builds the check
+// binary with the given builderImage. If the Dockerfile of the check is
+// already a multi-stage one it's used as is. The .dockerignore files of the
+// check directory and of the root of the module are honoured.
+func BuildMultiStageTarFromDir(checkDir, builderImage string) (*BuildContext, error) {
+	checkDir, err := filepath.Abs(checkDir)
+	if err != nil {
+		return nil, err
+	}
+	modRoot, err := findModuleRoot(checkDir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(modRoot, checkDir)
+	if err != nil {
+		return nil, err
+	}
+	dockerfile, err := os.ReadFile(filepath.Join(checkDir, dockerfileName))
+	if err != nil {
+		return nil, err
+	}
+	binary := filepath.Base(checkDir)
+	if !IsMultiStageDockerfile(dockerfile) {
+		pkg := "./" + filepath.ToSlash(rel)
+		dockerfile, err = MultiStageDockerfile(dockerfile, builderImage, binary, pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	check, err := newContextSource(checkDir, "")
+	if err != nil {
+		return nil, err
+	}
+	// A binary built previously in the host must not end up in the image.
+	check.skip = func(name string, _ bool) bool {
+		return name == dockerfileName || name == binary
+	}
+	module, err := newContextSource(modRoot, multiStageSrcDir)
+	if err != nil {
+		return nil, err
+	}
+	deps, err := moduleDeps(modRoot, checkDir)
+	if err != nil {
+		return nil, err
+	}
+	module.skip = func(name string, dir bool) bool {
+		return !deps.needed(name, dir)
+	}
+	files := []contextFile{{name: dockerfileName, content: dockerfile}}
+	return newBuildContext(files, check, module)
+}
+
+// moduleFiles contains the parts of a go module needed to build a package.
+type moduleFiles struct {
+	// pkgs contains the directories of the packages of the module the
+	// package depends on, including itself, relative to the module root.
+	pkgs map[string]bool
+	// embeds contains the files and directories, relative to the module
+	// root, embedded by those packages.
+	embeds []string
+}
+
+// needed reports whether the file or directory name, relative to the module
+// root, is needed to build the package. The go.mod, go.sum and the vendor
+// directory are always needed. Of the directories of the packages only the
+// files are needed, not the subdirectories.
+func (m moduleFiles) needed(name string, dir bool) bool {
+	if name == "go.mod" || name == "go.sum" || name == "vendor" || strings.HasPrefix(name, "vendor/") {
+		return true
+	}
+	if !dir && m.pkgs[path.Dir(name)] {
+		return true
+	}
+	for _, e := range m.embeds {
+		if name == e || strings.HasPrefix(name, e+"/") {
+			return true
+		}
+	}
+	if !dir {
+		return false
+	}
+	// The parents of the needed directories.
+	for p := range m.pkgs {
+		if p == name || strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+	for _, e := range m.embeds {
+		if strings.HasPrefix(e, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleDeps returns the parts of the go module in modRoot needed to build
+// the package in pkgDir, following its imports of other packages of the
+// module, as they are resolved when building for linux without cgo.
+func moduleDeps(modRoot, pkgDir string) (moduleFiles, error) {
+	gomod, err := os.ReadFile(filepath.Join(modRoot, "go.mod"))
+	if err != nil {
+		return moduleFiles{}, err
+	}
+	modPath := modulePath(gomod)
+	if modPath == "" {
+		return moduleFiles{}, fmt.Errorf("module path not found in %s", filepath.Join(modRoot, "go.mod"))
+	}
+	ctx := build.Default
+	ctx.GOOS = "linux"
+	ctx.CgoEnabled = false
+	m := moduleFiles{pkgs: map[string]bool{}}
+	pending := []string{pkgDir}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		rel, err := filepath.Rel(modRoot, dir)
+		if err != nil {
+			return moduleFiles{}, err
+		}
+		rel = filepath.ToSlash(rel)
+		if m.pkgs[rel] {
+			continue
+		}
+		m.pkgs[rel] = true
+		pkg, err := ctx.ImportDir(dir, 0)
+		if err != nil {
+			return moduleFiles{}, fmt.Errorf("error reading the go package in %s: %w", dir, err)
+		}
+		for _, pattern := range pkg.EmbedPatterns {
+			matches, err := filepath.Glob(filepath.Join(dir, strings.TrimPrefix(pattern, "all:")))
+			if err != nil {
+				return moduleFiles{}, err
+			}
+			for _, match := range matches {
+				e, err := filepath.Rel(modRoot, match)
+				if err != nil {
+					return moduleFiles{}, err
+				}
+				m.embeds = append(m.embeds, filepath.ToSlash(e))
+			}
+		}
+		for _, imp := range pkg.Imports {
+			if imp != modPath && !strings.HasPrefix(imp, modPath+"/") {
+				continue
+			}
+			pending = append(pending, filepath.Join(modRoot, filepath.FromSlash(strings.TrimPrefix(imp, modPath))))
+		}
+	}
+	return m, nil
+}
+
+// modulePath returns the path of a go module defined in its go.mod file.
+func modulePath(gomod []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(gomod))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+	return ""
+}
+
+// findModuleRoot returns the first directory, starting from dir and walking
+// up, that contains a go.mod file.
+func findModuleRoot(dir string) (string, error) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("go.mod file not found")
+		}
+		dir = parent
+	}
+}
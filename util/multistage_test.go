@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package util
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMultiStageDockerfile(t *testing.T) {
+	type args struct {
+		dockerfile string
+		binary     string
+		pkg        string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "HappyPath",
+			args: args{
+				dockerfile: "FROM alpine\nADD check /check\nCMD [\"/check\"]\n",
+				binary:     "check",
+				pkg:        "./cmd/check",
+			},
+			want: "# Generated by vulcan-checks-bsys, the check binary is built in the vulcan-builder stage.\n" +
+				"FROM golang AS vulcan-builder\n" +
+				"WORKDIR /src\n" +
+				"COPY .vulcan-src/ ./\n" +
+				"ENV CGO_ENABLED=0 GOOS=linux\n" +
+				"RUN go build -a -ldflags '-extldflags -static' -o /out/check ./cmd/check\n\n" +
+				"FROM alpine\n" +
+				"COPY --from=vulcan-builder /out/check /check\n" +
+				"CMD [\"/check\"]\n",
+		},
+		{
+			name: "KeepsFlags",
+			args: args{
+				dockerfile: "FROM alpine\nCOPY --chown=1000 ./check /usr/bin/check\n",
+				binary:     "check",
+				pkg:        "./cmd/check",
+			},
+			want: "# Generated by vulcan-checks-bsys, the check binary is built in the vulcan-builder stage.\n" +
+				"FROM golang AS vulcan-builder\n" +
+				"WORKDIR /src\n" +
+				"COPY .vulcan-src/ ./\n" +
+				"ENV CGO_ENABLED=0 GOOS=linux\n" +
+				"RUN go build -a -ldflags '-extldflags -static' -o /out/check ./cmd/check\n\n" +
+				"FROM alpine\n" +
+				"COPY --from=vulcan-builder --chown=1000 /out/check /usr/bin/check\n",
+		},
+		{
+			name: "BinaryNotAdded",
+			args: args{
+				dockerfile: "FROM alpine\nADD other /other\n",
+				binary:     "check",
+				pkg:        "./cmd/check",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MultiStageDockerfile([]byte(tt.args.dockerfile), "golang", tt.args.binary, tt.args.pkg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MultiStageDockerfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("MultiStageDockerfile() want != got. Diffs:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildMultiStageTarFromDir(t *testing.T) {
+	contents, err := BuildMultiStageTarFromDir("testdata/multistage/cmd/check", "golang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer contents.Close()
+	var (
+		names      []string
+		dockerfile string
+	)
+	r := tar.NewReader(contents)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+		if h.Name == "Dockerfile" {
+			var b bytes.Buffer
+			if _, err = b.ReadFrom(r); err != nil {
+				t.Fatal(err)
+			}
+			dockerfile = b.String()
+		}
+	}
+	sort.Strings(names)
+	want := []string{
+		".vulcan-src/cmd/",
+		".vulcan-src/cmd/check/",
+		".vulcan-src/cmd/check/Dockerfile",
+		".vulcan-src/cmd/check/main.go",
+		".vulcan-src/go.mod",
+		".vulcan-src/pkg/",
+		".vulcan-src/pkg/report/",
+		".vulcan-src/pkg/report/report.go",
+		".vulcan-src/pkg/report/templates/",
+		".vulcan-src/pkg/report/templates/report.tmpl",
+		"Dockerfile",
+		"main.go",
+	}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("BuildMultiStageTarFromDir() want != got. Diffs:\n%s", diff)
+	}
+	if !strings.Contains(dockerfile, "-o /out/check ./cmd/check\n") {
+		t.Errorf("BuildMultiStageTarFromDir() unexpected Dockerfile:\n%s", dockerfile)
+	}
+}
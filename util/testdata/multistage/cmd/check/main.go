@@ -0,0 +1,5 @@
+package main
+
+import "example.com/checks/pkg/report"
+
+func main() { report.Print() }
@@ -0,0 +1,11 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+)
+
+//go:embed templates
+var templates embed.FS
+
+func Print() { fmt.Println(templates.ReadFile("templates/report.tmpl")) }
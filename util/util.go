@@ -5,22 +5,21 @@ Copyright 2019 Adevinta
 package util
 
 import (
-	"archive/tar"
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
-	"path"
-	"strconv"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -42,11 +41,15 @@ type RegistryConfig struct {
 	RegistryPass   string
 }
 
-// BuildImage builds and image given a tar, a list of tags and labels.
-func BuildImage(tarFile io.Reader, tags []string, labels map[string]string) (response string, err error) {
+// BuildImage builds and image given a tar, a list of tags and labels. It
+// returns the output of the build and the ID of the image built. If the
+// SOURCE_DATE_EPOCH env var is defined, it's passed to the build as a build
+// arg so the builders supporting it produce reproducible images. The output
+// of the build is logged with the given logger.
+func BuildImage(tarFile io.Reader, tags []string, labels map[string]string, logger *slog.Logger) (response string, imageID string, err error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	ctx := context.Background()
@@ -54,15 +57,71 @@ func BuildImage(tarFile io.Reader, tags []string, labels map[string]string) (res
 		Tags:   tags,
 		Labels: labels,
 	}
+	if epoch := os.Getenv(sourceDateEpochEnvVar); epoch != "" {
+		buildOptions.BuildArgs = map[string]*string{sourceDateEpochEnvVar: &epoch}
+	}
 
 	re, err := cli.ImageBuild(ctx, tarFile, buildOptions)
+	if err != nil {
+		return "", "", err
+	}
+	defer re.Body.Close() // nolint: errcheck
+
+	lines, err := readDockerOutput(re.Body, logger)
+	if err != nil {
+		return strings.Join(lines, "\n"), "", err
+	}
+	imageID, err = builtImageID(lines)
+	return strings.Join(lines, "\n"), imageID, err
+}
+
+// builtImageID returns the ID of the image built from the aux message sent by
+// docker at the end of a build.
+func builtImageID(lines []string) (string, error) {
+	id, err := auxField(lines, "ID")
 	if err != nil {
 		return "", err
 	}
+	if id == "" {
+		return "", errors.New("image ID not found in the output of the build")
+	}
+	return id, nil
+}
 
-	// NOTE: shouldn't we be passing a log.Logger like in PushImage func?
-	lines, err := readDockerOutput(re.Body, nil)
-	return strings.Join(lines, "\n"), err
+// pushedImageDigest returns the digest of the manifest pushed from the aux
+// message sent by docker at the end of a push.
+func pushedImageDigest(lines []string) (string, error) {
+	digest, err := auxField(lines, "Digest")
+	if err != nil {
+		return "", err
+	}
+	if digest == "" {
+		return "", errors.New("image digest not found in the output of the push")
+	}
+	return digest, nil
+}
+
+// auxField returns the value of the first non empty field with the given name
+// in the aux messages contained in the output of a docker operation.
+func auxField(lines []string, field string) (string, error) {
+	for _, line := range lines {
+		msg, err := parsePushImageResultLine(line)
+		if err != nil {
+			return "", err
+		}
+		if msg.Aux == nil {
+			continue
+		}
+		aux := map[string]interface{}{}
+		if err = json.Unmarshal(*msg.Aux, &aux); err != nil {
+			// Not all the aux messages are json objects.
+			continue
+		}
+		if v, ok := aux[field].(string); ok && v != "" {
+			return v, nil
+		}
+	}
+	return "", nil
 }
 
 // RunCheckImage creates an runs a check in a container.
@@ -128,7 +187,9 @@ func RunCheckImage(imgName string, env []string) error {
 }
 
 // RunCheckReportImage creates an runs a check in a container using json output.
-func RunCheckReportImage(imgName string, env []string, target string, host bool) error {
+// If timeout is greater than zero the container is killed, and an error
+// returned, if the check doesn't finish in that time.
+func RunCheckReportImage(imgName string, env []string, target string, host bool, timeout time.Duration) error {
 	envCli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
 		return err
@@ -180,8 +241,18 @@ func RunCheckReportImage(imgName string, env []string, target string, host bool)
 	if err = cli.ContainerStart(ctx, r.ID, container.StartOptions{}); err != nil {
 		return err
 	}
+	var timedOut atomic.Bool
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			cli.ContainerKill(ctx, r.ID, "KILL") // nolint: errcheck
+		})
+		defer timer.Stop()
+	}
 
-	_, err = io.Copy(os.Stdout, attResp.Reader)
+	// The output of the check goes to stderr, so it is not mixed with the
+	// report written to stdout.
+	_, err = io.Copy(os.Stderr, attResp.Reader)
 	if err != nil {
 		return err
 	}
@@ -191,47 +262,97 @@ func RunCheckReportImage(imgName string, env []string, target string, host bool)
 	case <-wait:
 	case err = <-waitErr:
 	}
+	if timedOut.Load() {
+		return fmt.Errorf("the check did not finish in %s", timeout)
+	}
 
 	return err
 }
 
 // PushImage pushes a image to a given repository using provided credentials.
-func PushImage(imageName string, logger *log.Logger) (response string, err error) {
+// It returns the output of the push and the digest of the manifest pushed.
+func PushImage(imageName string, logger *slog.Logger) (response string, digest string, err error) {
 	envCli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	cli := envCli
 	ctx := context.Background()
 
+	encodedAuth, err := registryAuth()
+	if err != nil {
+		return "", "", err
+	}
+	pushOpts := types.ImagePushOptions{
+		RegistryAuth: encodedAuth,
+	}
+
+	r, err := cli.ImagePush(ctx, imageName, pushOpts)
+	if err != nil {
+		return "", "", err
+	}
+	defer r.Close() // nolint: errcheck
+
+	lines, err := readDockerOutput(r, logger)
+	if err != nil {
+		return strings.Join(lines, "\n"), "", err
+	}
+	digest, err = pushedImageDigest(lines)
+	return strings.Join(lines, "\n"), digest, err
+}
+
+// PullImage pulls an image from the registry using the same credentials used
+// to push the images.
+func PullImage(imageName string, logger *slog.Logger) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	encodedAuth, err := registryAuth()
+	if err != nil {
+		return err
+	}
+	r, err := cli.ImagePull(context.Background(), imageName, types.ImagePullOptions{RegistryAuth: encodedAuth})
+	if err != nil {
+		return err
+	}
+	defer r.Close() // nolint: errcheck
+	_, err = readDockerOutput(r, logger)
+	return err
+}
+
+// registryAuth returns the encoded credentials of the docker registry.
+func registryAuth() (string, error) {
 	username, password := getDockerCredentials()
 	cfg := registry.AuthConfig{
 		Username:      username,
 		Password:      password,
 		ServerAddress: config.Cfg.DockerRegistry,
 	}
-
 	buf, err := json.Marshal(cfg)
 	if err != nil {
 		return "", err
 	}
+	return base64.URLEncoding.EncodeToString(buf), nil
+}
 
-	encodedAuth := base64.URLEncoding.EncodeToString(buf)
-	pushOpts := types.ImagePushOptions{
-		RegistryAuth: encodedAuth,
+// PinnedImageName returns the reference to an image that includes, along with
+// its tag, the digest of its manifest, e.g.:
+// registry.example.com/vulcan-checks/vulcan-nessus:3@sha256:0b8a4c...
+// If the digest is empty the imageName is returned unchanged.
+func PinnedImageName(imageName, digest string) string {
+	if digest == "" {
+		return imageName
 	}
-
-	r, err := cli.ImagePush(ctx, imageName, pushOpts)
-	if err != nil {
-		return "", err
-	}
-
-	lines, err := readDockerOutput(r, logger)
-	return strings.Join(lines, "\n"), err
+	return imageName + "@" + digest
 }
 
-func readDockerOutput(r io.Reader, logger *log.Logger) (lines []string, err error) {
+// readDockerOutput reads the json messages sent by docker during an
+// operation and returns them. If a logger is provided the messages are
+// rendered as text and logged with debug level. It returns an error if
+// docker sends an error message.
+func readDockerOutput(r io.Reader, logger *slog.Logger) (lines []string, err error) {
 	reader := bufio.NewReader(r)
 
 	for {
@@ -252,22 +373,61 @@ func readDockerOutput(r io.Reader, logger *log.Logger) (lines []string, err erro
 		}
 
 		if msg.ErrorDetail != nil {
-			err = errors.New(msg.ErrorDetail.Message)
-			if logger != nil {
-				logger.Printf(line)
-			}
-			return nil, err
+			return nil, errors.New(msg.ErrorDetail.Message)
 		}
 
-		if logger != nil {
-			logger.Printf(line)
+		if text := msg.render(); logger != nil && text != "" {
+			logger.Debug(text)
 		}
 	}
 }
 
+// RenderDockerOutput renders as text the json messages sent by docker during
+// an operation, one per line, omitting the progress messages.
+func RenderDockerOutput(output string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		msg, err := parsePushImageResultLine(line)
+		if err != nil {
+			// Not a json message, e.g. an error of the daemon.
+			b.WriteString(strings.TrimSpace(line) + "\n")
+			continue
+		}
+		if text := msg.render(); text != "" {
+			b.WriteString(text + "\n")
+		}
+	}
+	return b.String()
+}
+
 type pushImgRespResp struct {
-	Status      string               `json:"status,omitempty"`
-	ErrorDetail *types.ErrorResponse `json:"errorDetail,omitempty"`
+	Stream         string               `json:"stream,omitempty"`
+	Status         string               `json:"status,omitempty"`
+	ID             string               `json:"id,omitempty"`
+	ProgressDetail *json.RawMessage     `json:"progressDetail,omitempty"`
+	ErrorDetail    *types.ErrorResponse `json:"errorDetail,omitempty"`
+	Aux            *json.RawMessage     `json:"aux,omitempty"`
+}
+
+// render returns a message as text. It returns an empty string for the
+// messages that only report the progress of a layer and the aux ones.
+func (m pushImgRespResp) render() string {
+	switch {
+	case m.ErrorDetail != nil:
+		return "ERROR: " + m.ErrorDetail.Message
+	case m.Stream != "":
+		return strings.TrimRight(m.Stream, "\n")
+	case m.Status == "":
+		return ""
+	case m.ProgressDetail != nil && string(*m.ProgressDetail) != "{}":
+		return ""
+	case m.ID != "":
+		return m.ID + ": " + m.Status
+	}
+	return m.Status
 }
 
 func parsePushImageResultLine(line string) (imgResp *pushImgRespResp, err error) {
@@ -276,78 +436,6 @@ func parsePushImageResultLine(line string) (imgResp *pushImgRespResp, err error)
 	return
 }
 
-// BuildTarFromDir builds  a tar file in memory given a sourcedir.
-func BuildTarFromDir(sourcedir string) (tarContents *bytes.Buffer, err error) {
-	dir, err := os.Open(path.Clean(sourcedir))
-	if err != nil {
-		return nil, err
-	}
-	defer dir.Close() // nolint: errcheck
-
-	files, err := dir.Readdir(0)
-	if err != nil {
-		return nil, err
-	}
-
-	var output bytes.Buffer
-	tarfileWriter := tar.NewWriter(&output)
-	defer tarfileWriter.Close() // nolint: errcheck
-
-	err = addDir(sourcedir, "", tarfileWriter, files)
-	return &output, err
-}
-
-// NOTE: consider split into functions.
-func addDir(sourceDir string, currentPath string, writer *tar.Writer, finfo []os.FileInfo) error {
-	for _, file := range finfo {
-		tarPath := path.Join(currentPath, file.Name())
-
-		// If file is a dir recursion.
-		if file.IsDir() {
-			absPath := path.Join(sourceDir, tarPath)
-			dir, err := os.Open(absPath)
-			if err != nil {
-				return err
-			}
-
-			files, err := dir.Readdir(0)
-			if err != nil {
-				return err
-			}
-
-			err = addDir(sourceDir, tarPath, writer, files)
-			if err != nil {
-				return err
-			}
-		} else {
-			// File is not a dir, add to tar.
-			h, err := tar.FileInfoHeader(file, tarPath)
-			if err != nil {
-				return err
-			}
-
-			h.Name = tarPath
-			if err = writer.WriteHeader(h); err != nil {
-				return err
-			}
-
-			absFilePath := path.Join(sourceDir, tarPath)
-
-			var content []byte
-			content, err = os.ReadFile(absFilePath)
-			if err != nil {
-				return err
-			}
-
-			if _, err = writer.Write(content); err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
 // ImageTagsInfo represents the info returned by
 // "/[reponame]/[imagename]/tags/list" rest query.
 type ImageTagsInfo struct {
@@ -363,6 +451,8 @@ type ImageVersionInfo struct {
 	Commit       string
 	SDKVersion   string
 	Manifest     manifest.Data
+	// Labels contains all the labels of the image.
+	Labels map[string]string
 }
 
 func setupAPICred(client *resty.Client) {
@@ -382,28 +472,27 @@ func getDockerCredentials() (string, string) {
 func askForCredentials() (username, password string) {
 	tty, err := os.Open("/dev/tty")
 	if err != nil {
-		// NOTE: consider using a logger.
-		fmt.Printf("Can not get artifactory credentials")
+		slog.Error("Can not get artifactory credentials", "error", err)
 		// NOTE: consider raising an error instead of panic.
 		panic(err)
 	}
 
 	reader := bufio.NewReader(tty)
 
-	fmt.Print("Enter Username for artifactory: ")
+	// The prompts are written to stderr so they are not mixed with the
+	// records of the logger.
+	fmt.Fprint(os.Stderr, "Enter Username for artifactory: ")
 	username, err = reader.ReadString('\n')
 	if err != nil {
-		// NOTE: consider using a logger.
-		fmt.Printf("Can not get artifactory credentials")
+		slog.Error("Can not get artifactory credentials", "error", err)
 		// NOTE: consider raising an error instead of panic.
 		panic(err)
 	}
 
-	fmt.Print("Enter Password for artifactory: ")
+	fmt.Fprint(os.Stderr, "Enter Password for artifactory: ")
 	bytePassword, err := term.ReadPassword(int(tty.Fd()))
 	if err != nil {
-		// NOTE: consider using a logger.
-		fmt.Printf("Can not get artifactory credentials")
+		slog.Error("Can not get artifactory credentials", "error", err)
 		// NOTE: consider raising an error instead of panic.
 		os.Exit(1)
 	}
@@ -442,6 +531,104 @@ func FetchImagesInfo(image string) (result ImageTagsInfo, err error) {
 	return
 }
 
+// manifestMediaTypes are the media types of the manifests of the images
+// accepted from the registry.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	specs.MediaTypeImageManifest,
+	specs.MediaTypeImageIndex,
+}
+
+// FetchImageDigest returns the digest of the manifest of the given image tag
+// stored in the registry.
+func FetchImageDigest(image string, tag string) (string, error) {
+	restyClient := resty.New()
+	client := restyClient.SetHostURL(config.Cfg.DockerAPIBaseURL)
+	setupAPICred(client)
+
+	manifestPath := fmt.Sprintf("/%v/%v/manifests/%v", config.Cfg.VulcanChecksRepo, image, tag)
+	r := client.R().SetHeader("Accept", strings.Join(manifestMediaTypes, ", "))
+	response, err := r.Head(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	if response.RawResponse.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	digest := response.Header().Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("no digest returned by query %s", response.Request.URL)
+	}
+	return digest, nil
+}
+
+// TagRemoteImage adds a tag to an image stored in the registry, without
+// pulling it, by pushing its manifest again with the new tag. Both tags point
+// to the same manifest digest. An existing tag with the same name is moved to
+// the image.
+func TagRemoteImage(image, tag, newTag string) error {
+	restyClient := resty.New()
+	client := restyClient.SetHostURL(config.Cfg.DockerAPIBaseURL)
+	setupAPICred(client)
+
+	manifestPath := fmt.Sprintf("/%v/%v/manifests/%v", config.Cfg.VulcanChecksRepo, image, tag)
+	response, err := client.R().SetHeader("Accept", strings.Join(manifestMediaTypes, ", ")).Get(manifestPath)
+	if err != nil {
+		return err
+	}
+	if response.RawResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	manifestPath = fmt.Sprintf("/%v/%v/manifests/%v", config.Cfg.VulcanChecksRepo, image, newTag)
+	response, err = client.R().
+		SetHeader("Content-Type", response.Header().Get("Content-Type")).
+		SetBody(response.Body()).
+		Put(manifestPath)
+	if err != nil {
+		return err
+	}
+	if response.RawResponse.StatusCode != http.StatusCreated && response.RawResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+	}
+	return nil
+}
+
+// DeleteImage deletes all the tags of an image from the registry. The tags
+// that point to the same manifest are deleted at once.
+func DeleteImage(image string) error {
+	info, err := FetchImagesInfo(image)
+	if err != nil {
+		return err
+	}
+	restyClient := resty.New()
+	client := restyClient.SetHostURL(config.Cfg.DockerAPIBaseURL)
+	setupAPICred(client)
+
+	deleted := make(map[string]bool)
+	for _, tag := range info.Tags {
+		digest, err := FetchImageDigest(image, tag)
+		if err != nil {
+			return err
+		}
+		if deleted[digest] {
+			continue
+		}
+		manifestPath := fmt.Sprintf("/%v/%v/manifests/%v", config.Cfg.VulcanChecksRepo, image, digest)
+		response, err := client.R().Delete(manifestPath)
+		if err != nil {
+			return err
+		}
+		switch response.RawResponse.StatusCode {
+		case http.StatusOK, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound:
+		default:
+			return fmt.Errorf("error returned by query %s, status: %s", response.Request.URL, response.RawResponse.Status)
+		}
+		deleted[digest] = true
+	}
+	return nil
+}
+
 // FetchRepositories gets all docker repositories in artifactory. this can
 // potentially return a lot of values but, unfortunately by now, we didn't found
 // any way for querying artifactory only for the vulcan-checks folder.
@@ -455,7 +642,7 @@ func FetchRepositories() ([]string, error) {
 	r := client.R()
 	response, err := r.Get("/_catalog")
 	// NOTE: consider using Logger.
-	fmt.Printf("\nrequest path: %s\n", response.Request.URL)
+	slog.Debug("Repositories fetched", "url", response.Request.URL)
 	if err != nil {
 		return nil, err
 	}
@@ -511,10 +698,18 @@ func FetchImageTagInfo(image string, tag string) (ImageVersionInfo, error) {
 	if err != nil {
 		return result, err
 	}
+	for k, v := range payload.Properties {
+		if name, ok := strings.CutPrefix(k, "docker.label."); ok && len(v) > 0 {
+			if result.Labels == nil {
+				result.Labels = make(map[string]string)
+			}
+			result.Labels[name] = v[0]
+		}
+	}
+
 	commits, exists := payload.Properties["docker.label.commit"]
 	if !exists {
-		// NOTE: consider using Logger.
-		fmt.Printf("Label docker.label.commit doesn't exist in image %s:%s", image, tag)
+		slog.Warn("Label not found in the image", "label", "commit", "image", image, "tag", tag)
 		result.Commit = ""
 	} else {
 		// Only the first value should be meaningful.
@@ -523,8 +718,7 @@ func FetchImageTagInfo(image string, tag string) (ImageVersionInfo, error) {
 
 	sdkVersions, exists := payload.Properties["docker.label.sdk-version"]
 	if !exists {
-		// NOTE: consider using Logger.
-		fmt.Printf("Label docker.label.sdk-version doesn't exist in image %s:%s", image, tag)
+		slog.Warn("Label not found in the image", "label", "sdk-version", "image", image, "tag", tag)
 		result.SDKVersion = ""
 	} else {
 		// Only the first value should be meaningful.
@@ -533,7 +727,7 @@ func FetchImageTagInfo(image string, tag string) (ImageVersionInfo, error) {
 
 	rawManifest, exists := payload.Properties["docker.label.manifest"]
 	if !exists {
-		fmt.Printf("Label docker.label.manifest doesn't exist in image %s:%s", image, tag)
+		slog.Warn("Label not found in the image", "label", "manifest", "image", image, "tag", tag)
 	} else {
 		// Only the first value should be meaningful.
 		err = json.Unmarshal([]byte(rawManifest[0]), &result.Manifest)
@@ -587,6 +781,119 @@ func GetLastCommitForDirInRepo(dir, repoPath string) (DirLastCommmit, error) {
 	return DirLastCommmit{Commit: commit, Path: dir}, nil
 }
 
+// GetCommitMessagesForDirInRepo returns the messages of the commits that
+// changed the dir after the since commit, from the newest to the oldest.
+func GetCommitMessagesForDirInRepo(dir, since, repoPath string) ([]string, error) {
+	// The messages are separated by a NUL byte, as they can contain new
+	// lines.
+	cmd := exec.Command("git", "log", "--format=%B%x00", since+"..HEAD", "--", dir)
+	if repoPath != "" {
+		cmd.Dir = repoPath
+	}
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error getting the commits of %s since %s: %w", dir, since, err)
+	}
+	var messages []string
+	for _, msg := range strings.Split(string(out), "\x00") {
+		if msg = strings.TrimSpace(msg); msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+// ListRemoteBranches returns the names of the branches of a remote of the
+// git repo.
+func ListRemoteBranches(remote, repoPath string) ([]string, error) {
+	cmd := exec.Command("git", "ls-remote", "--heads", remote)
+	if repoPath != "" {
+		cmd.Dir = repoPath
+	}
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listing the branches of the remote %s: %w", remote, err)
+	}
+	var branches []string
+	for _, line := range strings.Split(string(out), "\n") {
+		// Example: "137559c6c0...	refs/heads/master"
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if b, ok := strings.CutPrefix(fields[1], "refs/heads/"); ok {
+			branches = append(branches, b)
+		}
+	}
+	return branches, nil
+}
+
+// mergeSubjectRegexps match the subjects of the merge commits created by git
+// and the most common git hosting services. The first group is the name of
+// the branch merged.
+var mergeSubjectRegexps = []*regexp.Regexp{
+	// Example: "Merge pull request #12 from adevinta/feature/x"
+	regexp.MustCompile(`^Merge pull request #[0-9]+ from [^/\s]+/(\S+)`),
+	// Example: "Merge branch 'feature/x' into 'master'"
+	regexp.MustCompile(`^Merge branch '([^']+)'`),
+	// Example: "Merge remote-tracking branch 'origin/feature/x'"
+	regexp.MustCompile(`^Merge remote-tracking branch '[^/']+/([^']+)'`),
+}
+
+// ListPastBranches returns the names of the branches that existed in a remote
+// of the git repo: the remote-tracking branches, that are kept until they are
+// pruned, and the branches merged according to the subjects of the merge
+// commits of the repo. The branches squashed or rebased when merged are only
+// found while their remote-tracking branch exists.
+func ListPastBranches(remote, repoPath string) ([]string, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		if repoPath != "" {
+			cmd.Dir = repoPath
+		}
+		cmd.Env = os.Environ()
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("error listing the past branches of the remote %s: %w", remote, err)
+		}
+		return string(out), nil
+	}
+	prefix := "refs/remotes/" + remote + "/"
+	refs, err := git("for-each-ref", "--format=%(refname)", strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, ref := range strings.Fields(refs) {
+		if b, ok := strings.CutPrefix(ref, prefix); ok && b != "HEAD" {
+			branches = append(branches, b)
+		}
+	}
+	subjects, err := git("log", "--all", "--merges", "--format=%s")
+	if err != nil {
+		return nil, err
+	}
+	for _, subject := range strings.Split(subjects, "\n") {
+		if b, ok := mergedBranch(subject); ok {
+			branches = append(branches, b)
+		}
+	}
+	return branches, nil
+}
+
+// mergedBranch returns the name of the branch merged by a merge commit with
+// the given subject.
+func mergedBranch(subject string) (string, bool) {
+	for _, re := range mergeSubjectRegexps {
+		if m := re.FindStringSubmatch(subject); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
 func parseGitLogLine(gitLogOutput string) (commit string, err error) {
 	// Example:  "137559c Fix error in  vulcan-is-exposed. (#5)"
 	gitLines := strings.Split(gitLogOutput, "\n")
@@ -620,39 +927,38 @@ func GoBuildDir(checkDir string) error {
 	return cmd.Run()
 }
 
-// GoTestDir execute `go test .` in a process setting the Dir of the process to checkDir param.
-func GoTestDir(checkDir string) error {
-	args := []string{"test"}
-	cmd := exec.Command("go", args...)
-	cmd.Env = os.Environ()
-	cmd.Dir = checkDir
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// GoTestOptions contains the optional flags passed to go test.
+type GoTestOptions struct {
+	// Race enables the race detector.
+	Race bool
+	// Cover enables the coverage analysis. If CoverProfile is not empty the
+	// coverage profile is written to that file.
+	Cover        bool
+	CoverProfile string
 }
 
-// GetLatestTag given an array of tags returns
-// the last tag without considering alfanumerics values, for instance
-// in for this input ["10","7","something"] will return 10.
-// will return false if len of input is zero or no numeric values are present in the array.
-func GetLatestTag(tags []string) (latestTag string, found bool) {
-	var last int64
-	for _, tag := range tags {
-		// Take into account only tags that are valid integers.
-		ver, err := strconv.ParseInt(tag, 0, 64)
+// GoTestDir execute `go test -json ./...` in a process setting the Dir of the process to checkDir param.
+// It returns the output of the tests, in the format generated by go test -json, even if the tests fail.
+func GoTestDir(checkDir string, opts GoTestOptions) ([]byte, error) {
+	args := []string{"test", "-json"}
+	if opts.Race {
+		args = append(args, "-race")
+	}
+	if opts.Cover {
+		args = append(args, "-cover")
+	}
+	if opts.CoverProfile != "" {
+		profile, err := filepath.Abs(opts.CoverProfile)
 		if err != nil {
-			continue
-		}
-
-		// If we are here at least exists one tag that is a valid integer.
-		found = true
-
-		if ver > last {
-			last = ver
+			return nil, err
 		}
+		args = append(args, "-coverprofile", profile)
 	}
-
-	latestTag = strconv.FormatInt(last, 10)
-	return
+	args = append(args, "./...")
+	cmd := exec.Command("go", args...)
+	cmd.Env = os.Environ()
+	cmd.Dir = checkDir
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
 }
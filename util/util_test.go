@@ -7,16 +7,21 @@ package util
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -114,6 +119,7 @@ func TestFetchImageTagInfo(t *testing.T) {
 				Commit:       "01234a",
 				LastModified: time.Date(2017, time.May, 25, 14, 25, 3, 0, time.UTC),
 				SDKVersion:   "8e938a5",
+				Labels:       map[string]string{"commit": "01234a", "sdk-version": "8e938a5"},
 			},
 			wantErr: false,
 		},
@@ -138,34 +144,35 @@ func TestFetchImageTagInfo(t *testing.T) {
 	}
 }
 
-func TestBuildTarFromDir(t *testing.T) {
-	type args struct {
-		sourcedir string
-	}
+func TestStreamTarFromDirContents(t *testing.T) {
 	tests := []struct {
-		goldenPath      string
 		name            string
-		args            args
+		goldenPath      string
+		sourcedir       string
 		wantTarContents string
-		wantErr         bool
 	}{
 		{
 			name:       "HappyPath",
 			goldenPath: fmt.Sprintf("testdata/%s", "HappyPath"),
-			args: args{
-				sourcedir: "testdata/tarTestdir",
-			},
+			sourcedir:  "testdata/tarTestdir",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotTarContents, err := BuildTarFromDir(tt.args.sourcedir)
+			c, err := StreamTarFromDir(tt.sourcedir)
 			if err != nil {
 				t.Fatal(err)
 			}
-			gotContents, err := buildStringFromTar(gotTarContents)
+			defer c.Close()
+			h := sha256.New()
+			r := io.TeeReader(c, h)
+			gotContents, err := buildStringFromTar(r)
 			if err != nil {
-				t.Error(err)
+				t.Fatal(err)
+			}
+			// Read the padding at the end of the tar file.
+			if _, err = io.Copy(io.Discard, r); err != nil {
+				t.Fatal(err)
 			}
 			if tt.goldenPath != "" {
 				if *update {
@@ -174,28 +181,27 @@ func TestBuildTarFromDir(t *testing.T) {
 						t.Fatalf("Error writing golden file %v", err)
 					}
 				}
-				var aux []byte
-				aux, err = os.ReadFile(tt.goldenPath)
+				aux, err := os.ReadFile(tt.goldenPath)
 				if err != nil {
-					t.Error(err)
+					t.Fatal(err)
 				}
 				tt.wantTarContents = string(aux)
 			}
-
-			if (err != nil) != tt.wantErr {
-				t.Errorf("BuildTarFromDir() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-
 			if tt.wantTarContents != gotContents {
 				t.Errorf("Error want diffrent from got, want\n:%s\ngot:\n%s\n", tt.wantTarContents, gotContents)
-
+			}
+			digest, err := c.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := fmt.Sprintf("sha256:%x", h.Sum(nil)); digest != want {
+				t.Errorf("StreamTarFromDir() digest = %s, want %s", digest, want)
 			}
 		})
 	}
 }
 
-func buildStringFromTar(tarContents *bytes.Buffer) (string, error) {
+func buildStringFromTar(tarContents io.Reader) (string, error) {
 	var err error
 	st := make(map[string]string)
 	var h *tar.Header
@@ -259,6 +265,171 @@ func Test_parseGitLogLine(t *testing.T) {
 	}
 }
 
+func TestGetCommitMessagesForDirInRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v, %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(file, msg string) {
+		t.Helper()
+		p := filepath.Join(repo, file)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(msg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-m", msg)
+	}
+	git("init", "-q")
+	commit("check/main.go", "feat: first")
+	since := git("rev-parse", "--short", "HEAD")
+	commit("check/main.go", "fix: second\n\nBREAKING CHANGE: options")
+	commit("other/main.go", "feat: other check")
+	commit("check/manifest.toml", "docs: third")
+
+	got, err := GetCommitMessagesForDirInRepo("check", since, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docs: third", "fix: second\n\nBREAKING CHANGE: options"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("messages mismatch (-want +got):\n%v", diff)
+	}
+	if _, err := GetCommitMessagesForDirInRepo("check", "0000000", repo); err == nil {
+		t.Error("got no error for an unknown commit")
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	digests := map[string]string{"1": "sha256:aaa", "2": "sha256:bbb", "latest": "sha256:bbb"}
+	var deleted []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/tags/list"):
+			writeJSONResponse(w, http.StatusOK, `{"name":"vulcan-checks/vulcan-tls-feature-x","tags":["1","2","latest"]}`, nil)
+		case r.Method == http.MethodHead:
+			w.Header().Set("Docker-Content-Digest", digests[filepath.Base(r.URL.Path)])
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, filepath.Base(r.URL.Path))
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer s.Close()
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	config.Cfg.DockerAPIBaseURL = s.URL
+	config.Cfg.VulcanChecksRepo = "vulcan-checks"
+	config.Cfg.DockerRegistryUser = "user"
+	config.Cfg.DockerRegistryPwd = "pwd"
+
+	if err := DeleteImage("vulcan-tls-feature-x"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sha256:aaa", "sha256:bbb"}
+	if diff := cmp.Diff(want, deleted); diff != "" {
+		t.Errorf("deleted manifests mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestListRemoteBranches(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	remote := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = remote
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v, %s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "master")
+	git("commit", "-q", "--allow-empty", "-m", "first")
+	git("branch", "feature/x")
+
+	got, err := ListRemoteBranches(remote, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"feature/x", "master"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("branches mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestListPastBranches(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	remote, repo := t.TempDir(), t.TempDir()
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v, %s", args, err, out)
+		}
+	}
+	git(remote, "init", "-q", "-b", "master")
+	git(remote, "commit", "-q", "--allow-empty", "-m", "first")
+	git(remote, "branch", "feature/tracked")
+	git(remote, "checkout", "-q", "-b", "feature/merged")
+	git(remote, "commit", "-q", "--allow-empty", "-m", "feature")
+	git(remote, "checkout", "-q", "master")
+	git(remote, "merge", "-q", "--no-ff", "-m", "Merge pull request #1 from adevinta/feature/merged", "feature/merged")
+	git(remote, "branch", "-D", "feature/merged")
+	git(repo, "clone", "-q", remote, ".")
+	// The branch is deleted in the remote, but the remote-tracking branch
+	// is kept until it's pruned.
+	git(remote, "branch", "-D", "feature/tracked")
+
+	got, err := ListPastBranches("origin", repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"feature/merged", "feature/tracked", "master"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("branches mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func Test_mergedBranch(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+		found   bool
+	}{
+		{subject: "Merge pull request #12 from adevinta/feature/x", want: "feature/x", found: true},
+		{subject: "Merge branch 'feature/x' into 'master'", want: "feature/x", found: true},
+		{subject: "Merge remote-tracking branch 'origin/feature/x'", want: "feature/x", found: true},
+		{subject: "Add the check vulcan-tls-old (#12)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			got, found := mergedBranch(tt.subject)
+			if got != tt.want || found != tt.found {
+				t.Errorf("mergedBranch() = %q, %v, want %q, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
 func TestGetCurrentSDKVersion(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -322,66 +493,18 @@ func TestFetchRepositories(t *testing.T) {
 	}
 }
 
-func Test_getLatestTag(t *testing.T) {
-	type args struct {
-		tags []string
-	}
-	tests := []struct {
-		name          string
-		args          args
-		wantLatestTag string
-		wantFound     bool
-	}{
-		{
-			name: "Return false when no integer tags passed",
-			args: args{
-				tags: []string{"dkdkdkd", "dsdds"},
-			},
-			wantLatestTag: "0",
-			wantFound:     false,
-		},
-		{
-			name: "Return false and 0 when empty array of tags",
-			args: args{
-				tags: []string{},
-			},
-			wantLatestTag: "0",
-			wantFound:     false,
-		},
-		{
-			name: "Return true and 7",
-			args: args{
-				tags: []string{"sddsdsd", "5", "7", "dsds", "3"},
-			},
-			wantLatestTag: "7",
-			wantFound:     true,
-		},
-	}
-	for _, tt := range tests {
-		tt := tt
-
-		t.Run(tt.name, func(t *testing.T) {
-			gotLatestTag, gotFound := GetLatestTag(tt.args.tags)
-			if gotLatestTag != tt.wantLatestTag {
-				t.Errorf("getLatestTag() gotLatestTag = %v, want %v", gotLatestTag, tt.wantLatestTag)
-			}
-			if gotFound != tt.wantFound {
-				t.Errorf("getLatestTag() gotFound = %v, want %v", gotFound, tt.wantFound)
-			}
-		})
-	}
-}
-
 func Test_readDockerOutput(t *testing.T) {
-	l := log.New(os.Stdout, "Test_readDockerOutput", log.LstdFlags)
+	var logs bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	type args struct {
 		r      io.Reader
-		logger *log.Logger
+		logger *slog.Logger
 	}
 	tests := []struct {
 		name      string
 		args      args
 		wantLines []string
+		wantLogs  []string
 		wantErr   bool
 	}{
 		{
@@ -391,9 +514,34 @@ func Test_readDockerOutput(t *testing.T) {
 				r:      bytes.NewBufferString(""),
 			},
 		},
+		{
+			name: "Push",
+			args: args{
+				logger: l,
+				r: bytes.NewBufferString(`{"status":"Preparing","progressDetail":{},"id":"a1b2c3d4e5f6"}
+{"status":"Pushing","progressDetail":{"current":512,"total":1024},"progress":"[====>  ]","id":"a1b2c3d4e5f6"}
+{"status":"Pushed","progressDetail":{},"id":"a1b2c3d4e5f6"}
+`),
+			},
+			wantLines: []string{
+				`{"status":"Preparing","progressDetail":{},"id":"a1b2c3d4e5f6"}` + "\n",
+				`{"status":"Pushing","progressDetail":{"current":512,"total":1024},"progress":"[====>  ]","id":"a1b2c3d4e5f6"}` + "\n",
+				`{"status":"Pushed","progressDetail":{},"id":"a1b2c3d4e5f6"}` + "\n",
+			},
+			wantLogs: []string{`msg="a1b2c3d4e5f6: Preparing"`, `msg="a1b2c3d4e5f6: Pushed"`},
+		},
+		{
+			name: "Error",
+			args: args{
+				r: bytes.NewBufferString(`{"errorDetail":{"message":"denied"},"error":"denied"}
+`),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
 			gotLines, err := readDockerOutput(tt.args.r, tt.args.logger)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("readDockerOutput() error = %v, wantErr %v", err, tt.wantErr)
@@ -405,10 +553,33 @@ func Test_readDockerOutput(t *testing.T) {
 			if d != "" {
 				t.Errorf("readDockerOutput() = ! got. Diffs:\n %s", d)
 			}
+			var gotLogs []string
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				if _, msg, ok := strings.Cut(line, " msg="); ok {
+					gotLogs = append(gotLogs, "msg="+msg)
+				}
+			}
+			if d := cmp.Diff(tt.wantLogs, gotLogs); d != "" {
+				t.Errorf("logs mismatch (-want +got):\n%s", d)
+			}
 		})
 	}
 }
 
+func TestRenderDockerOutput(t *testing.T) {
+	output := `{"stream":"Step 1/2 : FROM alpine\n"}
+{"stream":"\n"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"f00"}
+{"status":"Downloading","progressDetail":{"current":1,"total":2},"id":"f00"}
+{"aux":{"ID":"sha256:43ca6f"}}
+{"errorDetail":{"message":"COPY failed"},"error":"COPY failed"}
+`
+	want := "Step 1/2 : FROM alpine\nf00: Pulling fs layer\nERROR: COPY failed\n"
+	if got := RenderDockerOutput(output); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
 func TestGoBuildDir(t *testing.T) {
 	type args struct {
 		checkDir string
@@ -440,3 +611,303 @@ func TestGoBuildDir(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamTarFromDir(t *testing.T) {
+	c, err := StreamTarFromDir("testdata/ignoreTestdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var (
+		got  []string
+		read int64
+	)
+	r := tar.NewReader(&countingReader{r: c, n: &read})
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s %c %s", h.Name, h.Typeflag, h.Linkname))
+	}
+	// Read the padding at the end of the tar file.
+	if _, err = io.Copy(io.Discard, &countingReader{r: c, n: &read}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		".dockerignore 0 ",
+		"Dockerfile 0 ",
+		"data.txt 0 ",
+		"db/ 5 ",
+		"link 2 data.txt",
+		// The wordlists dir is ignored but one of its files is not.
+		"wordlists/keep.txt 0 ",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("StreamTarFromDir() want != got. Diffs:\n%s", diff)
+	}
+	if c.Size() != read {
+		t.Errorf("StreamTarFromDir() size = %d, want %d", c.Size(), read)
+	}
+	if c.Files() != int64(len(want)) {
+		t.Errorf("StreamTarFromDir() files = %d, want %d", c.Files(), len(want))
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+func TestStreamTarFromDirIsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(dir+"/file", []byte("content"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := contextDigest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changing the permissions and times of the file must not change the
+	// context.
+	if err = os.Chmod(dir+"/file", 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err = os.Chtimes(dir+"/file", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	second, err := contextDigest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == "" || first != second {
+		t.Errorf("StreamTarFromDir() digests differ, first %s, second %s", first, second)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1577836800")
+	third, err := contextDigest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first {
+		t.Errorf("StreamTarFromDir() digest does not depend on SOURCE_DATE_EPOCH")
+	}
+}
+
+func TestBuildContextDigestNotRead(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/file", []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := StreamTarFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Digest(); !errors.Is(err, ErrContextNotRead) {
+		t.Errorf("Digest() error = %v, want %v", err, ErrContextNotRead)
+	}
+}
+
+func contextDigest(dir string) (string, error) {
+	c, err := StreamTarFromDir(dir)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	if _, err = io.Copy(io.Discard, c); err != nil {
+		return "", err
+	}
+	return c.Digest()
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "NotDefined",
+			want: time.Unix(0, 0).UTC(),
+		},
+		{
+			name: "Defined",
+			env:  "1577836800",
+			want: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Invalid",
+			env:     "yesterday",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SOURCE_DATE_EPOCH", tt.env)
+			got, err := SourceDateEpoch()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SourceDateEpoch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("SourceDateEpoch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_builtImageID(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "HappyPath",
+			lines: []string{
+				`{"stream":"Step 1/2 : FROM alpine\n"}`,
+				`{"aux":{"ID":"sha256:43ca6f"}}`,
+				`{"stream":"Successfully built 43ca6f\n"}`,
+			},
+			want: "sha256:43ca6f",
+		},
+		{
+			name:    "NoAuxMessage",
+			lines:   []string{`{"stream":"Step 1/2 : FROM alpine\n"}`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := builtImageID(tt.lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("builtImageID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("builtImageID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pushedImageDigest(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "HappyPath",
+			lines: []string{
+				`{"status":"The push refers to repository [docker.example.com/vulcan-checks/check]"}`,
+				`{"status":"1: digest: sha256:7e2f1a size: 527"}`,
+				`{"progressDetail":{},"aux":{"Tag":"1","Digest":"sha256:7e2f1a","Size":527}}`,
+			},
+			want: "sha256:7e2f1a",
+		},
+		{
+			name:    "NoAuxMessage",
+			lines:   []string{`{"status":"Preparing"}`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pushedImageDigest(tt.lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pushedImageDigest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pushedImageDigest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinnedImageName(t *testing.T) {
+	tests := []struct {
+		name   string
+		image  string
+		digest string
+		want   string
+	}{
+		{
+			name:   "HappyPath",
+			image:  "docker.example.com/vulcan-checks/check:1",
+			digest: "sha256:7e2f1a",
+			want:   "docker.example.com/vulcan-checks/check:1@sha256:7e2f1a",
+		},
+		{
+			name:  "NoDigest",
+			image: "docker.example.com/vulcan-checks/check:1",
+			want:  "docker.example.com/vulcan-checks/check:1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PinnedImageName(tt.image, tt.digest); got != tt.want {
+				t.Errorf("PinnedImageName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchImageDigest(t *testing.T) {
+	s := buildFakeDockerRegistryWithHeaders("", map[string]string{"Docker-Content-Digest": "sha256:7e2f1a"})
+	defer s.Close()
+	config.Cfg.DockerAPIBaseURL = s.URL
+	config.Cfg.DockerRegistryUser = "user"
+	config.Cfg.DockerRegistryPwd = "pwd"
+	got, err := FetchImageDigest("check", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "sha256:7e2f1a" {
+		t.Errorf("FetchImageDigest() = %v, want %v", got, "sha256:7e2f1a")
+	}
+}
+
+func TestTagRemoteImage(t *testing.T) {
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json"}`
+	var gotPath, gotType, gotBody string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			fmt.Fprint(w, manifest)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			gotPath, gotType, gotBody = r.URL.Path, r.Header.Get("Content-Type"), string(body)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer s.Close()
+	defer func(c config.Config) { config.Cfg = c }(config.Cfg)
+	config.Cfg.DockerAPIBaseURL = s.URL
+	config.Cfg.VulcanChecksRepo = "vulcan-checks"
+	config.Cfg.DockerRegistryUser = "user"
+	config.Cfg.DockerRegistryPwd = "pwd"
+
+	if err := TagRemoteImage("vulcan-tls", "9", "11"); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/vulcan-checks/vulcan-tls/manifests/11" || gotType != "application/vnd.docker.distribution.manifest.v2+json" || gotBody != manifest {
+		t.Errorf("got manifest %s pushed to %s with type %s, want %s", gotBody, gotPath, gotType, manifest)
+	}
+}
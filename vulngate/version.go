@@ -0,0 +1,133 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package vulngate
+
+import (
+	"strings"
+)
+
+// CompareVersions compares two package versions and returns -1, 0 or 1 if a
+// is lower, equal or higher than b. The versions are compared splitting them
+// in numeric and non numeric segments, which works for semantic versions and
+// for most of the versions of the OS packages, e.g.: 1.2.4_git20230717-r4 or
+// 2.36-9+deb12u4. The prefix v is ignored. As in debian versions, the epoch,
+// 0 if not present, is compared first, and a ~ sorts before anything, so
+// 1:1.0 > 2.0 and 1.0~rc1 < 1.0.
+func CompareVersions(a, b string) int {
+	ea, a := versionEpoch(a)
+	eb, b := versionEpoch(b)
+	if c := compareSegments(ea, eb); c != 0 {
+		return c
+	}
+	sa, sb := versionSegments(a), versionSegments(b)
+	for i := 0; i < len(sa) || i < len(sb); i++ {
+		var x, y string
+		if i < len(sa) {
+			x = sa[i]
+		}
+		if i < len(sb) {
+			y = sb[i]
+		}
+		if c := compareSegments(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// versionEpoch returns the epoch of a version, or 0 if it has none, and the
+// rest of the version.
+func versionEpoch(v string) (string, string) {
+	v = strings.TrimPrefix(v, "v")
+	epoch, rest, ok := strings.Cut(v, ":")
+	if !ok || epoch == "" || strings.Trim(epoch, "0123456789") != "" {
+		return "0", v
+	}
+	return epoch, rest
+}
+
+func versionSegments(v string) []string {
+	var (
+		segs []string
+		cur  strings.Builder
+	)
+	flush := func() {
+		if cur.Len() > 0 {
+			segs = append(segs, cur.String())
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == '~' {
+			flush()
+			segs = append(segs, "~")
+			continue
+		}
+		if !isAlnum(c) {
+			flush()
+			continue
+		}
+		if cur.Len() > 0 && isDigit(c) != isDigit(cur.String()[cur.Len()-1]) {
+			flush()
+		}
+		cur.WriteByte(c)
+	}
+	flush()
+	return segs
+}
+
+// compareSegments compares two segments of a version. Numeric segments are
+// compared by value and are higher than non numeric ones, so 1.0.1 > 1.0-rc1.
+// A missing segment is lower than a numeric one and higher than a non numeric
+// one, so 1.0 < 1.0.1 and 1.0 > 1.0-rc1. A ~ segment is lower than any other,
+// even a missing one.
+func compareSegments(x, y string) int {
+	switch {
+	case x == y:
+		return 0
+	case x == "~":
+		return -1
+	case y == "~":
+		return 1
+	case x == "":
+		if isDigit(y[0]) {
+			return -1
+		}
+		return 1
+	case y == "":
+		return -compareSegments(y, x)
+	}
+	dx, dy := isDigit(x[0]), isDigit(y[0])
+	switch {
+	case dx && !dy:
+		return 1
+	case !dx && dy:
+		return -1
+	case dx && dy:
+		x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+	}
+	if x < y {
+		return -1
+	}
+	if x > y {
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package vulngate checks the components of an SBOM against an offline
+// vulnerability database to decide if an image can be published.
+package vulngate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/adevinta/vulcan-checks-bsys/sbom"
+)
+
+// Severity is the severity of a vulnerability.
+type Severity int
+
+// Severities of the vulnerabilities, from the lowest to the highest.
+const (
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityUnknown:  "UNKNOWN",
+	SeverityLow:      "LOW",
+	SeverityMedium:   "MEDIUM",
+	SeverityHigh:     "HIGH",
+	SeverityCritical: "CRITICAL",
+}
+
+// ParseSeverity returns the severity with the given name, case insensitive.
+func ParseSeverity(s string) (Severity, error) {
+	for sev, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return sev, nil
+		}
+	}
+	return SeverityUnknown, fmt.Errorf("invalid severity %q", s)
+}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *Severity) UnmarshalText(text []byte) error {
+	sev, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = sev
+	return nil
+}
+
+// Advisory is a vulnerability that affects a package. A version of the
+// package is affected if it's listed in Versions or if it's in the range
+// [Introduced, Fixed). An empty Introduced means all the versions before
+// Fixed, and an empty Fixed means all the versions since Introduced.
+type Advisory struct {
+	ID         string   `json:"id"`
+	Ecosystem  string   `json:"ecosystem"` // e.g.: golang, apk or deb.
+	Package    string   `json:"package"`
+	Severity   Severity `json:"severity"`
+	Introduced string   `json:"introduced,omitempty"`
+	Fixed      string   `json:"fixed,omitempty"`
+	Versions   []string `json:"versions,omitempty"`
+}
+
+// Affects returns true if the advisory affects the given version of the
+// package.
+func (a Advisory) Affects(version string) bool {
+	for _, v := range a.Versions {
+		if CompareVersions(v, version) == 0 {
+			return true
+		}
+	}
+	if a.Introduced == "" && a.Fixed == "" {
+		return len(a.Versions) == 0
+	}
+	if a.Introduced != "" && CompareVersions(version, a.Introduced) < 0 {
+		return false
+	}
+	return a.Fixed == "" || CompareVersions(version, a.Fixed) < 0
+}
+
+// DB is an offline vulnerability database.
+type DB struct {
+	advisories map[string][]Advisory
+}
+
+// LoadDB reads a vulnerability database from a json file that contains an
+// array of advisories.
+func LoadDB(path string) (*DB, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var advs []Advisory
+	if err = json.Unmarshal(content, &advs); err != nil {
+		return nil, fmt.Errorf("error parsing the vulnerability database %s: %w", path, err)
+	}
+	return NewDB(advs), nil
+}
+
+// NewDB returns a database that contains the given advisories.
+func NewDB(advs []Advisory) *DB {
+	db := &DB{advisories: make(map[string][]Advisory)}
+	for _, a := range advs {
+		k := dbKey(a.Ecosystem, a.Package)
+		db.advisories[k] = append(db.advisories[k], a)
+	}
+	return db
+}
+
+func dbKey(ecosystem, pkg string) string {
+	return ecosystem + "/" + pkg
+}
+
+// Finding is a vulnerability found in a component.
+type Finding struct {
+	ID        string   `json:"id"`
+	Severity  Severity `json:"severity"`
+	Component string   `json:"component"`
+	Version   string   `json:"version"`
+	Fixed     string   `json:"fixed,omitempty"`
+	// Accepted contains the reason of the accepted risk when the finding is
+	// in the allowlist.
+	Accepted string `json:"accepted,omitempty"`
+}
+
+// Scan returns the findings of the components of a BOM, sorted by severity
+// and ID.
+func (db *DB) Scan(bom sbom.BOM) []Finding {
+	var findings []Finding
+	for _, c := range bom.Components {
+		for _, a := range db.advisories[dbKey(c.Ecosystem(), c.Name)] {
+			if !a.Affects(c.Version) {
+				continue
+			}
+			findings = append(findings, Finding{
+				ID:        a.ID,
+				Severity:  a.Severity,
+				Component: c.Name,
+				Version:   c.Version,
+				Fixed:     a.Fixed,
+			})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		return findings[i].ID < findings[j].ID
+	})
+	return findings
+}
+
+// Allowlist contains the vulnerabilities accepted as risks.
+type Allowlist struct {
+	Accepted []AcceptedRisk `toml:"accepted"`
+}
+
+// AcceptedRisk is a vulnerability accepted for a checktype, or for all of
+// them if Checktype is empty, until it expires.
+type AcceptedRisk struct {
+	ID        string    `toml:"id"`
+	Checktype string    `toml:"checktype"`
+	Reason    string    `toml:"reason"`
+	Expires   time.Time `toml:"expires"`
+}
+
+// LoadAllowlist reads an allowlist from a toml file.
+func LoadAllowlist(path string) (Allowlist, error) {
+	var l Allowlist
+	_, err := toml.DecodeFile(path, &l)
+	return l, err
+}
+
+// accepted returns the accepted risk that matches a vulnerability of a
+// checktype at the given time.
+func (l Allowlist) accepted(id, checktype string, now time.Time) (AcceptedRisk, bool) {
+	for _, r := range l.Accepted {
+		if r.ID != id || (r.Checktype != "" && r.Checktype != checktype) {
+			continue
+		}
+		if !r.Expires.IsZero() && now.After(r.Expires) {
+			continue
+		}
+		return r, true
+	}
+	return AcceptedRisk{}, false
+}
+
+// Gate decides if an image can be published given its vulnerabilities.
+type Gate struct {
+	DB        *DB
+	Allowlist Allowlist
+	// Threshold is the minimum severity of the findings that block an image.
+	Threshold Severity
+	// Now returns the current time, used to check if the accepted risks have
+	// expired. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Result is the result of checking an image.
+type Result struct {
+	Findings []Finding `json:"findings"`
+	// Blocking contains the IDs of the findings, not accepted, with a
+	// severity equal or higher than the threshold.
+	Blocking []string `json:"blocking,omitempty"`
+}
+
+// Passed returns true if the image can be published.
+func (r Result) Passed() bool {
+	return len(r.Blocking) == 0
+}
+
+// Check scans the SBOM of the image of a checktype.
+func (g Gate) Check(checktype string, bom sbom.BOM) Result {
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+	r := Result{Findings: g.DB.Scan(bom)}
+	for n, f := range r.Findings {
+		if risk, ok := g.Allowlist.accepted(f.ID, checktype, now()); ok {
+			r.Findings[n].Accepted = risk.Reason
+			if r.Findings[n].Accepted == "" {
+				r.Findings[n].Accepted = "accepted"
+			}
+			continue
+		}
+		if f.Severity >= g.Threshold {
+			r.Blocking = append(r.Blocking, f.ID)
+		}
+	}
+	return r
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package vulngate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-checks-bsys/sbom"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.10.0", -1},
+		{"v1.0.1", "v1.0.1-rc1", 1},
+		{"v1.0.0-rc1", "v1.0.0", -1},
+		{"1.0", "1.0.1", -1},
+		{"2.36-9+deb12u4", "2.36-9+deb12u10", -1},
+		{"1:2.36-9", "2.36-9", 1},
+		{"1:1.0", "2.0", 1},
+		{"0:2.36-9", "2.36-9", 0},
+		{"10:1.0", "9:2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0~rc1", "1.0-rc1", -1},
+		{"2.36-9+deb12u4~bpo", "2.36-9+deb12u4", -1},
+		{"1.2.4_git20230717-r4", "1.2.4_git20230717-r3", 1},
+		{"go1.22.1", "go1.21.10", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAdvisoryAffects(t *testing.T) {
+	tests := []struct {
+		name    string
+		adv     Advisory
+		version string
+		want    bool
+	}{
+		{
+			name:    "InRange",
+			adv:     Advisory{Introduced: "v1.0.0", Fixed: "v1.2.0"},
+			version: "v1.1.5",
+			want:    true,
+		},
+		{
+			name:    "Fixed",
+			adv:     Advisory{Introduced: "v1.0.0", Fixed: "v1.2.0"},
+			version: "v1.2.0",
+			want:    false,
+		},
+		{
+			name:    "BeforeIntroduced",
+			adv:     Advisory{Introduced: "v1.0.0", Fixed: "v1.2.0"},
+			version: "v0.9.0",
+			want:    false,
+		},
+		{
+			name:    "NoFix",
+			adv:     Advisory{Introduced: "v1.0.0"},
+			version: "v9.0.0",
+			want:    true,
+		},
+		{
+			name:    "ListedVersion",
+			adv:     Advisory{Versions: []string{"1.2.4-r1"}},
+			version: "1.2.4-r1",
+			want:    true,
+		},
+		{
+			name:    "NotListedVersion",
+			adv:     Advisory{Versions: []string{"1.2.4-r1"}},
+			version: "1.2.4-r2",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.adv.Affects(tt.version); got != tt.want {
+				t.Errorf("Affects(%q) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGateCheck(t *testing.T) {
+	db, err := LoadDB("testdata/db.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowlist, err := LoadAllowlist("testdata/allowlist.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := sbom.New("vulcan-check", "3", []sbom.Component{
+		{Name: "golang.org/x/net", Version: "v0.10.0", PURL: "pkg:golang/golang.org/x/net@v0.10.0"},
+		{Name: "github.com/example/lib", Version: "v1.0.0", PURL: "pkg:golang/github.com/example/lib@v1.0.0"},
+		{Name: "musl", Version: "1.2.4-r1", PURL: "pkg:apk/alpine/musl@1.2.4-r1"},
+	})
+	now := func() time.Time { return time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name         string
+		checktype    string
+		threshold    Severity
+		wantBlocking []string
+		wantPassed   bool
+	}{
+		{
+			name:         "BlockedHigh",
+			checktype:    "vulcan-check",
+			threshold:    SeverityHigh,
+			wantBlocking: []string{"CVE-2023-0002"},
+		},
+		{
+			name:       "AcceptedForChecktype",
+			checktype:  "vulcan-accepted",
+			threshold:  SeverityHigh,
+			wantPassed: true,
+		},
+		{
+			name:         "BlockedLow",
+			checktype:    "vulcan-accepted",
+			threshold:    SeverityLow,
+			wantBlocking: []string{"CVE-2023-0004", "GHSA-0003"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Gate{DB: db, Allowlist: allowlist, Threshold: tt.threshold, Now: now}
+			r := g.Check(tt.checktype, bom)
+			if len(r.Findings) != 4 {
+				t.Errorf("Check() findings = %+v, want 4", r.Findings)
+			}
+			if !reflect.DeepEqual(r.Blocking, tt.wantBlocking) {
+				t.Errorf("Check() blocking = %v, want %v", r.Blocking, tt.wantBlocking)
+			}
+			if r.Passed() != tt.wantPassed {
+				t.Errorf("Passed() = %v, want %v", r.Passed(), tt.wantPassed)
+			}
+		})
+	}
+}